@@ -0,0 +1,302 @@
+// Package anki writes flashcard decks as .apkg packages which can be
+// imported into Anki for offline study
+package anki
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Card is a note with a front and back. Fields are HTML.
+type Card struct {
+	// Key uniquely identifies the card within the deck so that
+	// importing a newer export updates rather than duplicates it
+	Key   string
+	Front string
+	Back  string
+	Tags  []string
+}
+
+// Deck is a named set of cards
+type Deck struct {
+	Name  string
+	Cards []*Card
+}
+
+// fields of the note model
+const separator = "\x1f"
+
+// styling for the card templates
+const css = `.card {
+  font-family: sans-serif;
+  font-size: 20px;
+  text-align: center;
+  color: black;
+  background-color: white;
+}
+.arabic {
+  font-size: 32px;
+  direction: rtl;
+}
+.meta {
+  font-size: 14px;
+  color: #666;
+}`
+
+const schema = `
+CREATE TABLE col (
+  id integer primary key, crt integer not null, mod integer not null,
+  scm integer not null, ver integer not null, dty integer not null,
+  usn integer not null, ls integer not null, conf text not null,
+  models text not null, decks text not null, dconf text not null,
+  tags text not null
+);
+CREATE TABLE notes (
+  id integer primary key, guid text not null, mid integer not null,
+  mod integer not null, usn integer not null, tags text not null,
+  flds text not null, sfld integer not null, csum integer not null,
+  flags integer not null, data text not null
+);
+CREATE TABLE cards (
+  id integer primary key, nid integer not null, did integer not null,
+  ord integer not null, mod integer not null, usn integer not null,
+  type integer not null, queue integer not null, due integer not null,
+  ivl integer not null, factor integer not null, reps integer not null,
+  lapses integer not null, left integer not null, odue integer not null,
+  odid integer not null, flags integer not null, data text not null
+);
+CREATE TABLE revlog (
+  id integer primary key, cid integer not null, usn integer not null,
+  ease integer not null, ivl integer not null, lastIvl integer not null,
+  factor integer not null, time integer not null, type integer not null
+);
+CREATE TABLE graves (
+  usn integer not null, oid integer not null, type integer not null
+);
+CREATE INDEX ix_notes_usn on notes (usn);
+CREATE INDEX ix_cards_usn on cards (usn);
+CREATE INDEX ix_revlog_usn on revlog (usn);
+CREATE INDEX ix_cards_nid on cards (nid);
+CREATE INDEX ix_cards_sched on cards (did, queue, due);
+CREATE INDEX ix_revlog_cid on revlog (cid);
+CREATE INDEX ix_notes_csum on notes (csum);
+`
+
+var htmlTags = regexp.MustCompile(`<[^>]*>`)
+
+// id derives a stable positive id from the parts
+func id(parts ...string) int64 {
+	h := sha1.Sum([]byte(strings.Join(parts, separator)))
+	return int64(binary.BigEndian.Uint64(h[:8]) >> 12)
+}
+
+// checksum of the sort field as used by Anki to find duplicates
+func checksum(field string) int64 {
+	h := sha1.Sum([]byte(htmlTags.ReplaceAllString(field, "")))
+	return int64(binary.BigEndian.Uint32(h[:4]))
+}
+
+// collection returns the col table config as JSON for the deck and model
+func (d *Deck) collection(deckID, modelID, now int64) (conf, models, decks, dconf string) {
+	encode := func(v interface{}) string {
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+
+	field := func(name string, ord int) map[string]interface{} {
+		return map[string]interface{}{
+			"name": name, "ord": ord, "sticky": false, "rtl": false,
+			"font": "Arial", "size": 20, "media": []string{},
+		}
+	}
+
+	conf = encode(map[string]interface{}{
+		"activeDecks":   []int64{1},
+		"curDeck":       1,
+		"newSpread":     0,
+		"collapseTime":  1200,
+		"timeLim":       0,
+		"estTimes":      true,
+		"dueCounts":     true,
+		"curModel":      nil,
+		"nextPos":       1,
+		"sortType":      "noteFld",
+		"sortBackwards": false,
+		"addToCur":      true,
+	})
+
+	models = encode(map[string]interface{}{
+		fmt.Sprint(modelID): map[string]interface{}{
+			"id":    modelID,
+			"name":  "Reminder",
+			"type":  0,
+			"mod":   now,
+			"usn":   -1,
+			"sortf": 0,
+			"did":   deckID,
+			"tmpls": []map[string]interface{}{{
+				"name":  "Card 1",
+				"ord":   0,
+				"qfmt":  "{{Front}}",
+				"afmt":  "{{FrontSide}}\n\n<hr id=answer>\n\n{{Back}}",
+				"did":   nil,
+				"bqfmt": "",
+				"bafmt": "",
+			}},
+			"flds":      []map[string]interface{}{field("Front", 0), field("Back", 1)},
+			"css":       css,
+			"latexPre":  "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage[utf8]{inputenc}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n",
+			"latexPost": "\\end{document}",
+			"tags":      []string{},
+			"vers":      []string{},
+			"req":       []interface{}{[]interface{}{0, "all", []int{0}}},
+		},
+	})
+
+	deck := func(id int64, name string) map[string]interface{} {
+		return map[string]interface{}{
+			"id": id, "name": name, "mod": now, "usn": -1, "desc": "",
+			"dyn": 0, "conf": 1, "collapsed": false, "extendNew": 10, "extendRev": 50,
+			"newToday": []int{0, 0}, "revToday": []int{0, 0}, "lrnToday": []int{0, 0}, "timeToday": []int{0, 0},
+		}
+	}
+
+	decks = encode(map[string]interface{}{
+		"1":                deck(1, "Default"),
+		fmt.Sprint(deckID): deck(deckID, d.Name),
+	})
+
+	dconf = encode(map[string]interface{}{
+		"1": map[string]interface{}{
+			"id": 1, "name": "Default", "mod": 0, "usn": 0, "maxTaken": 60,
+			"autoplay": true, "timer": 0, "replayq": true, "dyn": false,
+			"new": map[string]interface{}{
+				"delays": []int{1, 10}, "ints": []int{1, 4, 7}, "initialFactor": 2500,
+				"separate": true, "order": 1, "perDay": 20, "bury": true,
+			},
+			"rev": map[string]interface{}{
+				"perDay": 100, "ease4": 1.3, "fuzz": 0.05, "maxIvl": 36500,
+				"minSpace": 1, "bury": true,
+			},
+			"lapse": map[string]interface{}{
+				"delays": []int{10}, "mult": 0, "minInt": 1, "leechFails": 8,
+				"leechAction": 0,
+			},
+		},
+	})
+
+	return conf, models, decks, dconf
+}
+
+// build writes the deck to a new collection database at path
+func (d *Deck) build(path string) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	deckID := id("deck", d.Name)
+	modelID := id("model", "Reminder")
+
+	conf, models, decks, dconf := d.collection(deckID, modelID, now.Unix())
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO col VALUES (1, ?, ?, ?, 11, 0, 0, 0, ?, ?, ?, ?, '{}')`,
+		now.Unix(), now.UnixMilli(), now.UnixMilli(), conf, models, decks, dconf); err != nil {
+		return err
+	}
+
+	// note and card ids are creation times in milliseconds
+	base := now.UnixMilli()
+
+	for i, c := range d.Cards {
+		noteID := base + int64(i)
+		guid := fmt.Sprintf("%x", id(d.Name, c.Key))
+		tags := ""
+		if len(c.Tags) > 0 {
+			tags = " " + strings.Join(c.Tags, " ") + " "
+		}
+
+		if _, err := tx.Exec(`INSERT INTO notes VALUES (?, ?, ?, ?, -1, ?, ?, ?, ?, 0, '')`,
+			noteID, guid, modelID, now.Unix(), tags, c.Front+separator+c.Back,
+			c.Front, checksum(c.Front)); err != nil {
+			return err
+		}
+
+		// new cards in order of the deck
+		if _, err := tx.Exec(`INSERT INTO cards VALUES (?, ?, ?, 0, ?, -1, 0, 0, ?, 0, 0, 0, 0, 0, 0, 0, 0, '')`,
+			noteID, noteID, deckID, now.Unix(), i+1); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Write writes the deck as an .apkg package
+func (d *Deck) Write(w io.Writer) error {
+	if len(d.Cards) == 0 {
+		return fmt.Errorf("deck %q has no cards", d.Name)
+	}
+
+	dir, err := os.MkdirTemp("", "anki")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "collection.anki2")
+	if err := d.build(path); err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	z := zip.NewWriter(w)
+
+	cw, err := z.Create("collection.anki2")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(cw, f); err != nil {
+		return err
+	}
+
+	// no media files are included
+	mw, err := z.Create("media")
+	if err != nil {
+		return err
+	}
+	if _, err := mw.Write([]byte("{}")); err != nil {
+		return err
+	}
+
+	return z.Close()
+}
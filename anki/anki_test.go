@@ -0,0 +1,144 @@
+package anki
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/asim/reminder/names"
+	"github.com/asim/reminder/quran"
+)
+
+func TestParseRange(t *testing.T) {
+	for s, expected := range map[string]Range{
+		"":          {},
+		"2":         {Chapter: 2},
+		"2:255":     {Chapter: 2, Start: 255, End: 255},
+		"2:255-257": {Chapter: 2, Start: 255, End: 257},
+	} {
+		r, err := ParseRange(s)
+		if err != nil || r != expected {
+			t.Fatalf("%q: expected %+v, got %+v %v", s, expected, r, err)
+		}
+		if r.String() != s {
+			t.Fatalf("expected %q, got %q", s, r.String())
+		}
+	}
+
+	for _, s := range []string{"x", "0", "2:", "2:0", "2:257-255"} {
+		if _, err := ParseRange(s); err == nil {
+			t.Fatalf("%q: expected error", s)
+		}
+	}
+}
+
+func TestDecks(t *testing.T) {
+	q := quran.Load()
+
+	d := Names(names.Load())
+	if len(d.Cards) != 99 {
+		t.Fatalf("expected 99 names, got %d", len(d.Cards))
+	}
+
+	d, err := Verses(q, Range{Chapter: 2, Start: 255, End: 257})
+	if err != nil || len(d.Cards) != 3 || d.Name != "Reminder::Quran 2:255-257" {
+		t.Fatalf("expected 3 verses, got %+v %v", d, err)
+	}
+	if _, err := Verses(q, Range{}); err == nil {
+		t.Fatal("expected verses to require a range")
+	}
+
+	// the bismillah prepended to chapters isn't a card
+	d, err = Verses(q, Range{Chapter: 2})
+	if err != nil || len(d.Cards) != 286 || d.Cards[0].Key != "2:1" {
+		t.Fatalf("expected 286 verses from 2:1, got %d %v", len(d.Cards), err)
+	}
+
+	d, err = Words(q, Range{Chapter: 1})
+	if err != nil || len(d.Cards) == 0 {
+		t.Fatalf("expected words, got %+v %v", d, err)
+	}
+	seen := map[string]bool{}
+	for _, c := range d.Cards {
+		if seen[c.Key] {
+			t.Fatalf("duplicate word %s", c.Key)
+		}
+		seen[c.Key] = true
+	}
+}
+
+func TestWrite(t *testing.T) {
+	d := &Deck{Name: "Test", Cards: []*Card{
+		{Key: "1", Front: "الرحمن", Back: "The Most Merciful", Tags: []string{"names"}},
+		{Key: "2", Front: "الرحيم", Back: "The Most Compassionate"},
+	}}
+
+	var buf bytes.Buffer
+	if err := d.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "collection.anki2")
+	for _, f := range z.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+
+		switch f.Name {
+		case "media":
+			if string(b) != "{}" {
+				t.Fatalf("expected empty media, got %s", b)
+			}
+		case "collection.anki2":
+			os.WriteFile(path, b, 0644)
+		default:
+			t.Fatalf("unexpected file %s", f.Name)
+		}
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var ver int
+	var decks string
+	if err := db.QueryRow(`SELECT ver, decks FROM col`).Scan(&ver, &decks); err != nil {
+		t.Fatal(err)
+	}
+	if ver != 11 || !strings.Contains(decks, `"Test"`) {
+		t.Fatalf("unexpected collection %d %s", ver, decks)
+	}
+
+	for _, table := range []string{"notes", "cards"} {
+		var count int
+		db.QueryRow(`SELECT count(*) FROM ` + table).Scan(&count)
+		if count != 2 {
+			t.Fatalf("expected 2 %s, got %d", table, count)
+		}
+	}
+
+	var flds, tags string
+	db.QueryRow(`SELECT flds, tags FROM notes ORDER BY id LIMIT 1`).Scan(&flds, &tags)
+	if flds != "الرحمن\x1fThe Most Merciful" || tags != " names " {
+		t.Fatalf("unexpected note %q %q", flds, tags)
+	}
+
+	if err := (&Deck{Name: "Empty"}).Write(io.Discard); err == nil {
+		t.Fatal("expected error for an empty deck")
+	}
+}
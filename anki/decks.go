@@ -0,0 +1,210 @@
+package anki
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/asim/reminder/names"
+	"github.com/asim/reminder/quran"
+)
+
+// Types of deck
+var Types = []string{"names", "words", "verses"}
+
+// Range of verses, an End of 0 is the end of the chapter and a Chapter
+// of 0 is the whole Quran
+type Range struct {
+	Chapter int
+	Start   int
+	End     int
+}
+
+// ParseRange parses a chapter such as 2 or verses such as 2:255 or 2:255-257
+func ParseRange(s string) (Range, error) {
+	var r Range
+	if len(s) == 0 {
+		return r, nil
+	}
+
+	ch, verses, hasVerses := strings.Cut(s, ":")
+	if _, err := fmt.Sscanf(ch, "%d", &r.Chapter); err != nil || r.Chapter < 1 {
+		return r, fmt.Errorf("invalid range %q", s)
+	}
+	if !hasVerses {
+		return r, nil
+	}
+
+	from, to, isRange := strings.Cut(verses, "-")
+	if _, err := fmt.Sscanf(from, "%d", &r.Start); err != nil || r.Start < 1 {
+		return r, fmt.Errorf("invalid range %q", s)
+	}
+	r.End = r.Start
+	if isRange {
+		if _, err := fmt.Sscanf(to, "%d", &r.End); err != nil || r.End < r.Start {
+			return r, fmt.Errorf("invalid range %q", s)
+		}
+	}
+	return r, nil
+}
+
+// String formats the range for deck names e.g 2:255-257
+func (r Range) String() string {
+	switch {
+	case r.Chapter == 0:
+		return ""
+	case r.Start == 0:
+		return fmt.Sprint(r.Chapter)
+	case r.Start == r.End:
+		return fmt.Sprintf("%d:%d", r.Chapter, r.Start)
+	default:
+		return fmt.Sprintf("%d:%d-%d", r.Chapter, r.Start, r.End)
+	}
+}
+
+// verses returns the verses in the range, skipping the bismillah
+// prepended to chapters
+func (r Range) verses(q *quran.Quran) ([]*quran.Verse, error) {
+	numbered := func(verses []*quran.Verse) []*quran.Verse {
+		var result []*quran.Verse
+		for _, v := range verses {
+			if v.Number > 0 {
+				result = append(result, v)
+			}
+		}
+		return result
+	}
+
+	if r.Chapter == 0 {
+		var all []*quran.Verse
+		for _, ch := range q.Chapters {
+			all = append(all, numbered(ch.Verses)...)
+		}
+		return all, nil
+	}
+	if r.Chapter > len(q.Chapters) {
+		return nil, fmt.Errorf("invalid chapter %d", r.Chapter)
+	}
+	ch := q.Get(r.Chapter)
+	if r.Start == 0 {
+		return numbered(ch.Verses), nil
+	}
+	if ch.Verse(r.End) == nil {
+		return nil, fmt.Errorf("invalid verses %d-%d of chapter %d", r.Start, r.End, r.Chapter)
+	}
+	var result []*quran.Verse
+	for v := r.Start; v <= r.End; v++ {
+		result = append(result, ch.Verse(v))
+	}
+	return result, nil
+}
+
+func arabic(s string) string {
+	return `<div class="arabic" dir="rtl">` + html.EscapeString(s) + `</div>`
+}
+
+func meta(s string) string {
+	return `<div class="meta">` + html.EscapeString(s) + `</div>`
+}
+
+// Names is a deck of the names of Allah with the Arabic on the front and
+// the meaning on the back
+func Names(n *names.Names) *Deck {
+	d := &Deck{Name: "Reminder::Names of Allah"}
+	for _, name := range *n {
+		back := fmt.Sprintf("<div>%s</div><div><b>%s</b></div>",
+			html.EscapeString(name.English), html.EscapeString(name.Meaning))
+		if len(name.Summary) > 0 {
+			back += "<p>" + html.EscapeString(name.Summary) + "</p>"
+		}
+		back += meta(fmt.Sprintf("Name %d", name.Number))
+
+		tags := []string{"names"}
+		if len(name.Category) > 0 {
+			tags = append(tags, name.Category)
+		}
+
+		d.Cards = append(d.Cards, &Card{
+			Key:   fmt.Sprint(name.Number),
+			Front: arabic(name.Arabic),
+			Back:  back,
+			Tags:  tags,
+		})
+	}
+	return d
+}
+
+// Words is a deck of the unique words in the range with the Arabic on the
+// front and the transliteration and meaning on the back. Each word links
+// to the first verse it appears in.
+func Words(q *quran.Quran, r Range) (*Deck, error) {
+	verses, err := r.verses(q)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Deck{Name: "Reminder::Quran Words"}
+	if s := r.String(); len(s) > 0 {
+		d.Name += " " + s
+	}
+
+	seen := map[string]bool{}
+	for _, v := range verses {
+		for _, w := range v.Words {
+			word := strings.TrimSpace(w.Arabic)
+			if len(word) == 0 || len(w.English) == 0 || seen[word] {
+				continue
+			}
+			seen[word] = true
+
+			d.Cards = append(d.Cards, &Card{
+				Key:   word,
+				Front: arabic(word),
+				Back: fmt.Sprintf("<div><i>%s</i></div><div><b>%s</b></div>%s",
+					html.EscapeString(w.Transliteration), html.EscapeString(w.English),
+					meta(fmt.Sprintf("Quran %d:%d", v.Chapter, v.Number))),
+				Tags: []string{"quran", "words", fmt.Sprintf("surah-%d", v.Chapter)},
+			})
+		}
+	}
+
+	return d, nil
+}
+
+// Verses is a deck of the verses in the range with the Arabic on the front
+// and the translation on the back
+func Verses(q *quran.Quran, r Range) (*Deck, error) {
+	if r.Chapter == 0 {
+		return nil, fmt.Errorf("range is required")
+	}
+	verses, err := r.verses(q)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Deck{Name: "Reminder::Quran " + r.String()}
+	for _, v := range verses {
+		key := fmt.Sprintf("%d:%d", v.Chapter, v.Number)
+		d.Cards = append(d.Cards, &Card{
+			Key:   key,
+			Front: arabic(v.Arabic),
+			Back:  "<div>" + html.EscapeString(v.Text) + "</div>" + meta("Quran "+key),
+			Tags:  []string{"quran", "verses", fmt.Sprintf("surah-%d", v.Chapter)},
+		})
+	}
+
+	return d, nil
+}
+
+// New builds a deck of the type, the range applies to words and verses
+func New(typ string, n *names.Names, q *quran.Quran, r Range) (*Deck, error) {
+	switch typ {
+	case "names":
+		return Names(n), nil
+	case "words":
+		return Words(q, r)
+	case "verses":
+		return Verses(q, r)
+	}
+	return nil, fmt.Errorf("deck must be one of %s", strings.Join(Types, ", "))
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// IsAdmin reports whether the request carries the admin token set by
+// REMINDER_ADMIN_TOKEN as a bearer token. Admin access is disabled when
+// the token isn't set.
+func IsAdmin(r *http.Request) bool {
+	token := os.Getenv("REMINDER_ADMIN_TOKEN")
+	if len(token) == 0 {
+		return false
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+
+	given := strings.TrimPrefix(auth, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// RequireAdmin only calls the handler for admin requests
+func RequireAdmin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !IsAdmin(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
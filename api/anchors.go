@@ -0,0 +1,245 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/asim/reminder/prayer"
+)
+
+// Content of a notification anchored to a prayer
+const (
+	// The current reminder with the content of the preferences
+	AnchorReminder = "reminder"
+	// A short dhikr for the time of day
+	AnchorDhikr = "dhikr"
+)
+
+var AnchorContent = []string{AnchorReminder, AnchorDhikr}
+
+// Limits on anchored notifications
+const (
+	MaxAnchors      = 10
+	MaxAnchorOffset = 180
+)
+
+// PushLocation is where a subscriber's prayer times are calculated for
+type PushLocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// Calculation method e.g mwl, see prayer.Methods
+	Method string `json:"method,omitempty"`
+	// shafii or hanafi
+	Asr string `json:"asr,omitempty"`
+}
+
+// PrayerAnchor is a notification sent relative to a prayer time
+// e.g a reminder 10 minutes after Fajr
+type PrayerAnchor struct {
+	// One of fajr, sunrise, dhuhr, asr, maghrib or isha
+	Prayer string `json:"prayer"`
+	// Minutes after the prayer, negative for before
+	Offset int `json:"offset"`
+	// reminder or dhikr
+	Content string `json:"content,omitempty"`
+}
+
+// PrayerPush is an anchored notification due for a subscription
+type PrayerPush struct {
+	Subscription PushSubscription
+	Anchor       PrayerAnchor
+	At           time.Time
+}
+
+// ValidateAnchors checks the location and anchors of a subscription
+func ValidateAnchors(loc *PushLocation, anchors []PrayerAnchor) error {
+	if len(anchors) > MaxAnchors {
+		return fmt.Errorf("at most %d anchors are allowed", MaxAnchors)
+	}
+	if len(anchors) > 0 && loc == nil {
+		return fmt.Errorf("a location is required for anchors")
+	}
+	if loc != nil {
+		if loc.Latitude < -90 || loc.Latitude > 90 || loc.Longitude < -180 || loc.Longitude > 180 {
+			return fmt.Errorf("invalid location %v,%v", loc.Latitude, loc.Longitude)
+		}
+		if _, ok := prayer.Methods[loc.Method]; len(loc.Method) > 0 && !ok {
+			return fmt.Errorf("invalid method %q", loc.Method)
+		}
+		switch loc.Asr {
+		case "", prayer.Shafii, prayer.Hanafi:
+		default:
+			return fmt.Errorf("invalid asr %q", loc.Asr)
+		}
+	}
+	for _, a := range anchors {
+		if !contains(prayer.Prayers, a.Prayer) {
+			return fmt.Errorf("invalid prayer %q", a.Prayer)
+		}
+		if a.Offset < -MaxAnchorOffset || a.Offset > MaxAnchorOffset {
+			return fmt.Errorf("offset must be between -%d and %d minutes", MaxAnchorOffset, MaxAnchorOffset)
+		}
+		if len(a.Content) > 0 && !contains(AnchorContent, a.Content) {
+			return fmt.Errorf("invalid anchor content %q", a.Content)
+		}
+	}
+	return nil
+}
+
+// NextAnchor returns the time of the first anchored delivery after the
+// given time and every anchor due then. The prayer times are those of the
+// local days in the subscriber's time zone. It returns false if there are
+// no anchors or no times can be calculated.
+func (s PushSubscription) NextAnchor(after time.Time) (time.Time, []PrayerAnchor, bool) {
+	var next time.Time
+	var anchors []PrayerAnchor
+	if s.Location == nil || len(s.Anchors) == 0 {
+		return next, anchors, false
+	}
+
+	loc := s.Preferences.WithDefaults().location()
+	opts := prayer.Options{Method: s.Location.Method, Asr: s.Location.Asr, Location: loc}
+	local := after.In(loc)
+
+	// the days either side for anchors which fall past midnight
+	for d := -1; d <= 1; d++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+d, 0, 0, 0, 0, time.UTC)
+		times, err := prayer.Calculate(s.Location.Latitude, s.Location.Longitude, day, opts)
+		if err != nil {
+			continue
+		}
+		for _, a := range s.Anchors {
+			t, ok := times.Get(a.Prayer)
+			if !ok {
+				continue
+			}
+			t = t.Add(time.Duration(a.Offset) * time.Minute)
+			if !t.After(after) || (!next.IsZero() && t.After(next)) {
+				continue
+			}
+			if !t.Equal(next) {
+				next, anchors = t, nil
+			}
+			if len(a.Content) == 0 {
+				a.Content = AnchorReminder
+			}
+			anchors = append(anchors, a)
+		}
+	}
+
+	return next, anchors, !next.IsZero()
+}
+
+// anchorRetry is how often a subscription with no prayer times in reach,
+// e.g during midnight sun, looks again from the current time. It's less
+// than the day ahead NextAnchor covers so no anchor is missed.
+const anchorRetry = 12 * time.Hour
+
+// nextAnchor is a cached calculation of the next anchored delivery
+type nextAnchor struct {
+	key     string
+	next    time.Time
+	anchors []PrayerAnchor
+	ok      bool
+}
+
+// nextAnchors caches the next anchored delivery of each subscription, as
+// it only changes with delivery or the subscription
+var nextAnchorsMtx sync.Mutex
+var nextAnchors = map[string]nextAnchor{}
+
+// anchorKey identifies what the next anchored delivery depends on
+func (s PushSubscription) anchorKey() string {
+	var loc PushLocation
+	if s.Location != nil {
+		loc = *s.Location
+	}
+	return fmt.Sprintf("%d|%+v|%+v|%s", s.AnchorDelivered.UnixNano(), loc, s.Anchors, s.Preferences.TimeZone)
+}
+
+// UpdatePushAnchors replaces the location and anchors of a subscription.
+// Anchored deliveries start from now.
+func UpdatePushAnchors(endpoint string, loc *PushLocation, anchors []PrayerAnchor) error {
+	pushMtx.Lock()
+	sub, ok := pushSubscriptions[endpoint]
+	if !ok {
+		pushMtx.Unlock()
+		return fmt.Errorf("subscription not found")
+	}
+	sub.Location = loc
+	sub.Anchors = anchors
+	sub.AnchorDelivered = time.Now()
+	pushSubscriptions[endpoint] = sub
+	pushMtx.Unlock()
+	return SavePushSubscriptions()
+}
+
+// SetAnchorDelivered records the time up to which each subscription has
+// been delivered its anchored notifications
+func SetAnchorDelivered(delivered map[string]time.Time) error {
+	if len(delivered) == 0 {
+		return nil
+	}
+	pushMtx.Lock()
+	for endpoint, t := range delivered {
+		if sub, ok := pushSubscriptions[endpoint]; ok {
+			sub.AnchorDelivered = t
+			pushSubscriptions[endpoint] = sub
+		}
+	}
+	pushMtx.Unlock()
+	return SavePushSubscriptions()
+}
+
+// DuePrayerPushes returns the anchored deliveries due at or before now.
+// Like scheduled deliveries, those missed by more than PushMaxLateness are
+// skipped rather than sent late.
+func DuePrayerPushes(now time.Time) []PrayerPush {
+	var due []PrayerPush
+	skipped := make(map[string]time.Time)
+
+	nextAnchorsMtx.Lock()
+	cache := make(map[string]nextAnchor)
+	for _, sub := range ListPushSubscriptions() {
+		if sub.Location == nil || len(sub.Anchors) == 0 {
+			continue
+		}
+		key := sub.anchorKey()
+		n, ok := nextAnchors[sub.Endpoint]
+		if !ok || n.key != key {
+			n = nextAnchor{key: key}
+			n.next, n.anchors, n.ok = sub.NextAnchor(sub.AnchorDelivered)
+		}
+		cache[sub.Endpoint] = n
+
+		if !n.ok {
+			// look again from now once the days searched have passed
+			if now.Sub(sub.AnchorDelivered) >= anchorRetry {
+				skipped[sub.Endpoint] = now
+			}
+			continue
+		}
+		if n.next.After(now) {
+			continue
+		}
+		if now.Sub(n.next) > PushMaxLateness {
+			skipped[sub.Endpoint] = now
+			continue
+		}
+		// every anchor at the same time is delivered
+		for _, a := range n.anchors {
+			due = append(due, PrayerPush{Subscription: sub, Anchor: a, At: n.next})
+		}
+	}
+	// drop the subscriptions which have gone
+	nextAnchors = cache
+	nextAnchorsMtx.Unlock()
+
+	if err := SetAnchorDelivered(skipped); err != nil {
+		log.Printf("Failed to save push subscriptions: %v", err)
+	}
+
+	return due
+}
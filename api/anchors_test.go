@@ -0,0 +1,150 @@
+package api
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asim/reminder/prayer"
+)
+
+func TestValidateAnchors(t *testing.T) {
+	london := &PushLocation{Latitude: 51.5074, Longitude: -0.1278}
+
+	if err := ValidateAnchors(london, []PrayerAnchor{{Prayer: "fajr", Offset: 10}, {Prayer: "asr", Content: AnchorDhikr}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateAnchors(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		loc     *PushLocation
+		anchors []PrayerAnchor
+	}{
+		{nil, []PrayerAnchor{{Prayer: "fajr"}}},
+		{&PushLocation{Latitude: 91}, nil},
+		{&PushLocation{Method: "unknown"}, nil},
+		{london, []PrayerAnchor{{Prayer: "tahajjud"}}},
+		{london, []PrayerAnchor{{Prayer: "fajr", Offset: 300}}},
+		{london, []PrayerAnchor{{Prayer: "fajr", Content: "quran"}}},
+	} {
+		if err := ValidateAnchors(c.loc, c.anchors); err == nil {
+			t.Fatalf("expected %+v %+v to be invalid", c.loc, c.anchors)
+		}
+	}
+}
+
+func TestNextAnchor(t *testing.T) {
+	sub := PushSubscription{
+		Preferences: PushPreferences{TimeZone: "Europe/London"},
+		Location:    &PushLocation{Latitude: 51.5074, Longitude: -0.1278},
+		Anchors: []PrayerAnchor{
+			{Prayer: "fajr", Offset: 10},
+			{Prayer: "asr", Content: AnchorDhikr},
+		},
+	}
+	london, _ := time.LoadLocation("Europe/London")
+	times, err := prayer.Calculate(51.5074, -0.1278, time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), prayer.Options{Location: london})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the evening before is followed by fajr
+	next, anchors, ok := sub.NextAnchor(time.Date(2024, 6, 20, 22, 0, 0, 0, london))
+	if !ok || !next.Equal(times.Fajr.Add(10*time.Minute)) || len(anchors) != 1 || anchors[0].Prayer != "fajr" || anchors[0].Content != AnchorReminder {
+		t.Fatalf("expected fajr at %s, got %s %+v", times.Fajr.Add(10*time.Minute), next, anchors)
+	}
+
+	next, anchors, _ = sub.NextAnchor(next)
+	if !next.Equal(times.Asr) || len(anchors) != 1 || anchors[0].Content != AnchorDhikr {
+		t.Fatalf("expected asr at %s, got %s %+v", times.Asr, next, anchors)
+	}
+
+	// anchors at the same time are all returned
+	sub.Anchors = append(sub.Anchors, PrayerAnchor{Prayer: "asr"})
+	next, anchors, _ = sub.NextAnchor(times.Asr.Add(-time.Minute))
+	if !next.Equal(times.Asr) || len(anchors) != 2 {
+		t.Fatalf("expected both asr anchors at %s, got %s %+v", times.Asr, next, anchors)
+	}
+
+	if _, _, ok := (PushSubscription{}).NextAnchor(next); ok {
+		t.Fatal("expected no anchors")
+	}
+}
+
+func TestDuePrayerPushes(t *testing.T) {
+	pushFile = filepath.Join(t.TempDir(), "push_subscriptions.json")
+	pushSubscriptions = make(map[string]PushSubscription)
+
+	now := time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC)
+	sub := PushSubscription{
+		Endpoint: "https://push.example.com/1",
+		Location: &PushLocation{Latitude: 51.5074, Longitude: -0.1278},
+		Anchors:  []PrayerAnchor{{Prayer: "dhuhr", Offset: -30}},
+	}
+	sub.AnchorDelivered = now.Add(-30 * time.Minute)
+	pushSubscriptions[sub.Endpoint] = sub
+
+	due := DuePrayerPushes(now)
+	if len(due) != 1 || due[0].Anchor.Prayer != "dhuhr" {
+		t.Fatalf("expected dhuhr to be due, got %+v", due)
+	}
+	if n, ok := nextAnchors[sub.Endpoint]; !ok || !n.next.Equal(due[0].At) {
+		t.Fatalf("expected the next anchor to be cached, got %+v", n)
+	}
+	if err := SetAnchorDelivered(map[string]time.Time{sub.Endpoint: due[0].At}); err != nil {
+		t.Fatal(err)
+	}
+	if due := DuePrayerPushes(now); len(due) != 0 {
+		t.Fatalf("expected nothing due after delivery, got %+v", due)
+	}
+
+	// every anchor at the same minute is delivered
+	sub.Anchors = append(sub.Anchors, PrayerAnchor{Prayer: "dhuhr", Offset: -30, Content: AnchorDhikr})
+	pushSubscriptions[sub.Endpoint] = sub
+	if due := DuePrayerPushes(now); len(due) != 2 || due[1].Anchor.Content != AnchorDhikr {
+		t.Fatalf("expected both dhuhr anchors to be due, got %+v", due)
+	}
+	sub.Anchors = sub.Anchors[:1]
+
+	// missed by more than PushMaxLateness
+	now = now.Add(3 * time.Hour)
+	sub.AnchorDelivered = now.Add(-6 * time.Hour)
+	pushSubscriptions[sub.Endpoint] = sub
+	if due := DuePrayerPushes(now); len(due) != 0 {
+		t.Fatalf("expected late deliveries to be skipped, got %+v", due)
+	}
+	if got, _ := GetPushSubscription(sub.Endpoint); !got.AnchorDelivered.Equal(now) {
+		t.Fatalf("expected skipped deliveries to be marked delivered, got %s", got.AnchorDelivered)
+	}
+}
+
+func TestDuePrayerPushesPolar(t *testing.T) {
+	pushFile = filepath.Join(t.TempDir(), "push_subscriptions.json")
+	pushSubscriptions = make(map[string]PushSubscription)
+
+	// Tromsø has no times for weeks around the summer solstice
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	sub := PushSubscription{
+		Endpoint:        "https://push.example.com/polar",
+		Location:        &PushLocation{Latitude: 69.6492, Longitude: 18.9553},
+		Anchors:         []PrayerAnchor{{Prayer: "fajr"}},
+		AnchorDelivered: now,
+	}
+	pushSubscriptions[sub.Endpoint] = sub
+	if _, _, ok := sub.NextAnchor(now); ok {
+		t.Fatal("expected no times during midnight sun")
+	}
+
+	// the search moves forward with time rather than stopping
+	for ; now.Before(time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)); now = now.Add(time.Hour) {
+		if due := DuePrayerPushes(now); len(due) > 0 {
+			if due[0].Anchor.Prayer != "fajr" || due[0].At.Before(time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC)) {
+				t.Fatalf("unexpected delivery %+v", due[0])
+			}
+			return
+		}
+	}
+	t.Fatalf("expected fajr to resume after midnight sun, last delivered %s", pushSubscriptions[sub.Endpoint].AnchorDelivered)
+}
@@ -131,6 +131,25 @@ var Endpoints = []*Endpoint{
 				{Name: "text", Value: "string", Description: "Text of the verse"},
 				{Name: "arabic", Value: "string", Description: "Arabic text of the verse"},
 				{Name: "words", Value: "array", Description: "Word by word translation"},
+				{Name: "hadith", Value: "array", Description: "Hadith citing the verse e.g 65:12"},
+				{Name: "names", Value: "array", Description: "Names of Allah mentioned in the verse"},
+			},
+		}},
+	},
+	{
+		Name:        "Hadith citing a Verse",
+		Path:        "/api/quran/{chapter}/{verse}/hadith",
+		Params:      nil,
+		Description: "Returns hadith which quote or reference the verse",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "book", Value: "int", Description: "Book of the hadith"},
+				{Name: "book_name", Value: "string", Description: "Name of the book"},
+				{Name: "number", Value: "int", Description: "Number of the hadith"},
+				{Name: "narrator", Value: "string", Description: "Narrator of the hadith"},
+				{Name: "english", Value: "string", Description: "English text of the hadith"},
+				{Name: "arabic", Value: "string", Description: "Arabic text of the hadith"},
 			},
 		}},
 	},
@@ -155,12 +174,96 @@ var Endpoints = []*Endpoint{
 		}},
 	},
 	{
-		Name:        "Names",
-		Path:        "/api/names",
+		Name:        "Hadith by Number",
+		Path:        "/api/hadith/{book}/{number}",
+		Params:      nil,
+		Description: "Returns a single hadith from a book",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "number", Value: "int", Description: "Number of the hadith"},
+				{Name: "narrator", Value: "string", Description: "Narrator of the hadith"},
+				{Name: "english", Value: "string", Description: "English text of the hadith"},
+				{Name: "arabic", Value: "string", Description: "Arabic text of the hadith"},
+				{Name: "parallels", Value: "array", Description: "Parallel narrations of the hadith"},
+				{Name: "verses", Value: "array", Description: "Quran verses cited by the hadith e.g 2:255"},
+			},
+		}},
+	},
+	{
+		Name:        "Hadith Parallels",
+		Path:        "/api/hadith/{book}/{number}/parallels",
 		Params:      nil,
+		Description: "Returns narrations of the same hadith found elsewhere in the collection, ordered by similarity",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "collection", Value: "string", Description: "Collection of the parallel narration"},
+				{Name: "book", Value: "int", Description: "Book of the parallel narration"},
+				{Name: "number", Value: "int", Description: "Number of the parallel narration"},
+				{Name: "score", Value: "float", Description: "Similarity score between 0 and 1"},
+				{Name: "narrator", Value: "string", Description: "Narrator of the parallel narration"},
+				{Name: "english", Value: "string", Description: "English text of the parallel narration"},
+			},
+		}},
+	},
+	{
+		Name: "Names",
+		Path: "/api/names",
+		Params: []*Param{
+			{Name: "category", Value: "string", Description: "(GET query) Optional attribute category to filter by e.g mercy, an unknown category is not found"},
+		},
 		Response:    []*Value{{Type: "JSON"}},
 		Description: "Returns the names of Allah",
 	},
+	{
+		Name:        "Categories of Names",
+		Path:        "/api/names/categories",
+		Params:      nil,
+		Description: "Returns the attribute categories the names of Allah are grouped by",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "name", Value: "string", Description: "Category used for filtering e.g mercy"},
+				{Name: "title", Value: "string", Description: "Display title of the category"},
+				{Name: "names", Value: "array", Description: "Numbers of the names in the category"},
+			},
+		}},
+	},
+	{
+		Name:        "Name of Allah",
+		Path:        "/api/names/{id}",
+		Params:      nil,
+		Description: "Returns a name of Allah with the verses of the Quran it appears in",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "number", Value: "int", Description: "Number of the name"},
+				{Name: "english", Value: "string", Description: "Transliteration of the name"},
+				{Name: "arabic", Value: "string", Description: "Arabic name"},
+				{Name: "meaning", Value: "string", Description: "Meaning of the name"},
+				{Name: "location", Value: "array", Description: "Verse references e.g 2:255"},
+				{Name: "verses", Value: "array", Description: "Verses with key, chapter, verse, arabic and text"},
+				{Name: "category", Value: "string", Description: "Attribute category of the name"},
+				{Name: "audio", Value: "string", Description: "URL of the pronunciation audio if available"},
+			},
+		}},
+	},
+	{
+		Name:        "Names in a Verse",
+		Path:        "/api/quran/{chapter}/{verse}/names",
+		Params:      nil,
+		Description: "Returns the names of Allah mentioned in the verse",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "number", Value: "int", Description: "Number of the name"},
+				{Name: "english", Value: "string", Description: "Transliteration of the name"},
+				{Name: "arabic", Value: "string", Description: "Arabic name"},
+				{Name: "meaning", Value: "string", Description: "Meaning of the name"},
+			},
+		}},
+	},
 	{
 		Name:        "Search",
 		Path:        "/api/search",
@@ -181,6 +284,565 @@ var Endpoints = []*Endpoint{
 			},
 		}},
 	},
+	{
+		Name: "Push Subscribe",
+		Path: "/api/push/subscribe",
+		Params: []*Param{
+			{Name: "endpoint", Value: "string", Description: "Push subscription endpoint"},
+			{Name: "keys", Value: "map", Description: "Push subscription keys (p256dh and auth)"},
+			{Name: "preferences", Value: "map", Description: "Optional delivery preferences, see /api/push/preferences"},
+			{Name: "topics", Value: "array", Description: "Optional topics, see /api/push/topics. Defaults to daily"},
+			{Name: "location", Value: "map", Description: "Optional location for anchors, see /api/push/anchors"},
+			{Name: "anchors", Value: "array", Description: "Optional notifications anchored to prayer times, see /api/push/anchors"},
+		},
+		Description: "Subscribe to push notifications (POST). Defaults to the daily verse at midnight UTC.",
+		Response:    nil,
+	},
+	{
+		Name: "Push Preferences",
+		Path: "/api/push/preferences",
+		Params: []*Param{
+			{Name: "endpoint", Value: "string", Description: "Push subscription endpoint (GET query or POST body)"},
+			{Name: "preferences.timezone", Value: "string", Description: "(POST only) IANA time zone e.g Europe/London. Defaults to UTC"},
+			{Name: "preferences.time", Value: "string", Description: "(POST only) Local delivery time as HH:MM. Defaults to 00:00"},
+			{Name: "preferences.content", Value: "array", Description: "(POST only) Any of verse, hadith, name, message. Defaults to verse"},
+			{Name: "preferences.frequency", Value: "string", Description: "(POST only) daily, hourly or weekly. Defaults to daily"},
+			{Name: "preferences.weekday", Value: "string", Description: "(POST only) Day for weekly delivery. Defaults to friday"},
+		},
+		Description: "Get (GET) or update (POST) the delivery preferences of a push subscription",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "timezone", Value: "string", Description: "IANA time zone"},
+				{Name: "time", Value: "string", Description: "Local delivery time"},
+				{Name: "content", Value: "array", Description: "Content included in notifications"},
+				{Name: "frequency", Value: "string", Description: "Delivery frequency"},
+				{Name: "weekday", Value: "string", Description: "Day for weekly delivery"},
+			},
+		}},
+	},
+	{
+		Name: "Push Topics",
+		Path: "/api/push/topics",
+		Params: []*Param{
+			{Name: "endpoint", Value: "string", Description: "Push subscription endpoint (GET query or POST body). Optional for GET to list all topics"},
+			{Name: "topics", Value: "array", Description: "(POST only) Any of daily, jumuah, ramadan, hourly"},
+		},
+		Description: "List topics or get (GET) and update (POST) those of a push subscription. The daily topic is the scheduled reminder, jumuah a Friday Surah Al-Kahf reminder, ramadan daily messages in Ramadan and hourly every refresh of the reminder",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "topics", Value: "array", Description: "The topics"},
+			},
+		}},
+	},
+	{
+		Name: "Push Test",
+		Path: "/api/push/test",
+		Params: []*Param{
+			{Name: "endpoint", Value: "string", Description: "Push subscription endpoint"},
+		},
+		Description: "Send a test notification to the subscription immediately (POST). Limited to 3 every 10 minutes per subscription",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "status", Value: "int", Description: "Status returned by the push service"},
+				{Name: "attempts", Value: "int", Description: "Number of attempts made"},
+				{Name: "error", Value: "string", Description: "Error if the delivery failed"},
+				{Name: "latency_ms", Value: "int", Description: "Time taken to deliver"},
+			},
+		}},
+	},
+	{
+		Name: "Push Preview",
+		Path: "/api/push/preview",
+		Params: []*Param{
+			{Name: "endpoint", Value: "string", Description: "Push subscription endpoint"},
+		},
+		Description: "Preview the next scheduled notification for a subscription",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "next", Value: "string", Description: "Time of the next scheduled delivery"},
+				{Name: "topics", Value: "array", Description: "Topics subscribed to"},
+				{Name: "payload", Value: "map", Description: "Notification payload as sent, null until today's reminder is ready"},
+			},
+		}},
+	},
+	{
+		Name: "Push Anchors",
+		Path: "/api/push/anchors",
+		Params: []*Param{
+			{Name: "endpoint", Value: "string", Description: "Push subscription endpoint (GET query or POST body)"},
+			{Name: "location", Value: "map", Description: "(POST only) latitude, longitude and optional method and asr for the prayer times, see /api/prayer"},
+			{Name: "anchors", Value: "array", Description: "(POST only) Notifications as prayer (fajr, sunrise, dhuhr, asr, maghrib or isha), offset in minutes after it and content (reminder or dhikr)"},
+		},
+		Description: "Get (GET) or update (POST) notifications anchored to prayer times e.g 10 minutes after fajr, calculated in the time zone of the preferences",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "location", Value: "map", Description: "Location of the prayer times"},
+				{Name: "anchors", Value: "array", Description: "The anchors"},
+				{Name: "next", Value: "map", Description: "(GET only) Time and anchors of the next notifications"},
+			},
+		}},
+	},
+	{
+		Name: "Push Send",
+		Path: "/api/push/send",
+		Params: []*Param{
+			{Name: "topic", Value: "string", Description: "Topic to broadcast to. Defaults to daily"},
+			{Name: "title", Value: "string", Description: "Notification title. Defaults to Reminder"},
+			{Name: "body", Value: "string", Description: "Notification body"},
+			{Name: "url", Value: "string", Description: "URL opened on click. Defaults to /home"},
+		},
+		Description: "Broadcast a message to a topic (POST). Requires the admin bearer token",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "topic", Value: "string", Description: "The topic"},
+				{Name: "sent", Value: "int", Description: "Number of notifications delivered"},
+				{Name: "failed", Value: "int", Description: "Number of failed deliveries"},
+			},
+		}},
+	},
+	{
+		Name: "Email Subscribe",
+		Path: "/api/email/subscribe",
+		Params: []*Param{
+			{Name: "email", Value: "string", Description: "Email address to send the daily reminder to"},
+		},
+		Description: "Subscribe to the daily reminder by email (POST). The digest starts once the emailed confirmation link is followed. The link is sent at most once every 5 minutes. Emails are rate limited per address and client",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "email", Value: "string", Description: "The email address"},
+			},
+		}},
+	},
+	{
+		Name: "Email Unsubscribe",
+		Path: "/api/email/unsubscribe",
+		Params: []*Param{
+			{Name: "token", Value: "string", Description: "Token from the unsubscribe link in each email"},
+		},
+		Description: "Unsubscribe from the daily reminder email with a POST. A GET shows a form to confirm.",
+		Response:    nil,
+	},
+	{
+		Name: "Signup",
+		Path: "/api/signup",
+		Params: []*Param{
+			{Name: "email", Value: "string", Description: "Email address"},
+			{Name: "password", Value: "string", Description: "Password of at least 8 characters"},
+		},
+		Description: "Create an account with a password (POST). A link is emailed to confirm the address, which creates the account, sets the password and logs in. Addresses with an account are emailed a login link instead. Returns 202 either way",
+		Response:    nil,
+	},
+	{
+		Name: "Login",
+		Path: "/api/login",
+		Params: []*Param{
+			{Name: "email", Value: "string", Description: "Email address"},
+			{Name: "password", Value: "string", Description: "Password"},
+		},
+		Description: "Log in with a password (POST). Sets an HttpOnly session cookie",
+		Response:    nil,
+	},
+	{
+		Name: "Login by Email",
+		Path: "/api/login/email",
+		Params: []*Param{
+			{Name: "email", Value: "string", Description: "Email address"},
+		},
+		Description: "Email a single use login link valid for 15 minutes (POST). The account is created when the link is followed. A link is sent at most once every 5 minutes and emails are rate limited per address and client",
+		Response:    nil,
+	},
+	{
+		Name:        "Logout",
+		Path:        "/api/logout",
+		Params:      nil,
+		Description: "Log out, clearing the session cookie (POST)",
+		Response:    nil,
+	},
+	{
+		Name:        "Me",
+		Path:        "/api/me",
+		Params:      nil,
+		Description: "Get the logged in user. Returns 401 if not logged in",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "email", Value: "string", Description: "Email address"},
+				{Name: "confirmed", Value: "bool", Description: "Whether the email address is confirmed"},
+				{Name: "digest", Value: "bool", Description: "Whether the daily email digest is sent"},
+				{Name: "password", Value: "bool", Description: "Whether a password is set"},
+				{Name: "created", Value: "string", Description: "Time the account was created"},
+			},
+		}},
+	},
+	{
+		Name: "Bookmarks",
+		Path: "/api/bookmarks",
+		Params: []*Param{
+			{Name: "type", Value: "string", Description: "(POST and DELETE) One of quran, hadith or names"},
+			{Name: "key", Value: "string", Description: "(POST and DELETE) Key of the bookmark e.g 2:255 for a verse, 1:1 for a hadith, 1 for a name"},
+			{Name: "label", Value: "string", Description: "(POST only) Label of the bookmark"},
+			{Name: "url", Value: "string", Description: "(POST only) URL of the bookmark"},
+		},
+		Description: "Get (GET), add (POST) or remove (DELETE) bookmarks for the logged in user or the sync code in the X-Sync-Code header",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "quran", Value: "map", Description: "Verse bookmarks by key with label, url and timestamp"},
+				{Name: "hadith", Value: "map", Description: "Hadith bookmarks by key"},
+				{Name: "names", Value: "map", Description: "Name bookmarks by key"},
+				{Name: "deleted", Value: "map", Description: "Timestamps of removed bookmarks by type:key, kept for 90 days"},
+			},
+		}},
+	},
+	{
+		Name:        "Bookmarks Import",
+		Path:        "/api/bookmarks/import",
+		Params:      nil,
+		Description: "Merge a reminder_bookmarks blob from localStorage (POST), keeping the most recent of duplicate keys. Removals in the deleted map win over older bookmarks. Returns the merged bookmarks",
+		Response:    nil,
+	},
+	{
+		Name:        "Bookmarks Sync Code",
+		Path:        "/api/bookmarks/sync",
+		Params:      nil,
+		Description: "Create a sync code (POST) to use in the X-Sync-Code header to share bookmarks across devices without an account. A code unused for a day expires and codes are rate limited per client",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "code", Value: "string", Description: "The sync code e.g 7KQ2-M9XD-4TPA"},
+			},
+		}},
+	},
+	{
+		Name: "Notes",
+		Path: "/api/notes",
+		Params: []*Param{
+			{Name: "type", Value: "string", Description: "quran or hadith. Filters notes on GET"},
+			{Name: "key", Value: "string", Description: "Verse key e.g 2:255 or hadith key book:number e.g 1:1. Filters notes on GET"},
+			{Name: "tag", Value: "string", Description: "(GET only) Filter notes by tag"},
+			{Name: "text", Value: "string", Description: "(POST only) Note in markdown"},
+			{Name: "tags", Value: "array", Description: "(POST only) Tags for the note"},
+			{Name: "highlights", Value: "array", Description: "(POST only) Character ranges of the text highlighted as start, end and optional color"},
+		},
+		Description: "List (GET) or create (POST) notes of the logged in user. Get, update (PUT) or delete (DELETE) a note at /api/notes/{id}",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "id", Value: "string", Description: "ID of the note"},
+				{Name: "type", Value: "string", Description: "quran or hadith"},
+				{Name: "key", Value: "string", Description: "Key of the verse or hadith"},
+				{Name: "text", Value: "string", Description: "Note in markdown"},
+				{Name: "html", Value: "string", Description: "Note rendered as HTML"},
+				{Name: "tags", Value: "array", Description: "Tags for the note"},
+				{Name: "highlights", Value: "array", Description: "Highlighted ranges"},
+				{Name: "created", Value: "string", Description: "Time created"},
+				{Name: "updated", Value: "string", Description: "Time last updated"},
+			},
+		}},
+	},
+	{
+		Name:        "Note Tags",
+		Path:        "/api/notes/tags",
+		Params:      nil,
+		Description: "Get the tags of the logged in user's notes with the number of notes for each",
+		Response:    nil,
+	},
+	{
+		Name: "Notes Export",
+		Path: "/api/notes/export",
+		Params: []*Param{
+			{Name: "format", Value: "string", Description: "md or json. Defaults to md"},
+			{Name: "type", Value: "string", Description: "Optional filter by quran or hadith"},
+			{Name: "tag", Value: "string", Description: "Optional filter by tag"},
+		},
+		Description: "Download the logged in user's notes as Markdown or JSON",
+		Response:    nil,
+	},
+	{
+		Name: "Reading Plans",
+		Path: "/api/plans",
+		Params: []*Param{
+			{Name: "method", Value: "string", Description: "(POST only) days (equal portions by words), juz (whole juz per day), pages (pages per day) or custom (chosen dates). Defaults to days"},
+			{Name: "days", Value: "int", Description: "(POST only) Number of days for the days and juz methods. Defaults to 30"},
+			{Name: "pages", Value: "int", Description: "(POST only) Approximate pages per day for the pages method. Defaults to 20"},
+			{Name: "dates", Value: "array", Description: "(POST only) Reading dates as YYYY-MM-DD for the custom method"},
+			{Name: "start", Value: "string", Description: "(POST only) First day as YYYY-MM-DD. Defaults to today"},
+			{Name: "name", Value: "string", Description: "(POST only) Name of the plan"},
+			{Name: "timezone", Value: "string", Description: "(POST only) IANA time zone for the current day. Defaults to UTC"},
+			{Name: "endpoint", Value: "string", Description: "(POST only) Push subscription endpoint to include the day's portion in notifications"},
+		},
+		Description: "List (GET) or create (POST) plans to complete the Quran for the logged in user. Get or delete (DELETE) a plan at /api/plans/{id}",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "id", Value: "string", Description: "ID of the plan"},
+				{Name: "name", Value: "string", Description: "Name of the plan"},
+				{Name: "method", Value: "string", Description: "Method used to divide the portions"},
+				{Name: "portions", Value: "array", Description: "Daily portions with day, date, start, end, verses and words"},
+				{Name: "position", Value: "map", Description: "Last verse read"},
+			},
+		}},
+	},
+	{
+		Name: "Reading Plan Progress",
+		Path: "/api/plans/{id}/progress",
+		Params: []*Param{
+			{Name: "verse", Value: "string", Description: "(POST only) Last verse read e.g 2:252"},
+		},
+		Description: "Get (GET) or record (POST) progress of a plan. Today's portion for every plan is at /api/plans/today",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "position", Value: "map", Description: "Last verse read"},
+				{Name: "resume", Value: "map", Description: "Next verse to read"},
+				{Name: "today", Value: "map", Description: "Today's portion, null if there's no reading today"},
+				{Name: "percent", Value: "float", Description: "Percent of the Quran read"},
+				{Name: "complete", Value: "bool", Description: "Whether the Quran has been completed"},
+				{Name: "behind", Value: "int", Description: "Days behind schedule, negative when ahead"},
+			},
+		}},
+	},
+	{
+		Name: "Hifz",
+		Path: "/api/hifz",
+		Params: []*Param{
+			{Name: "range", Value: "string", Description: "(POST only) Verses memorised e.g 2:255 or 2:255-257"},
+		},
+		Description: "List (GET) or add (POST) ranges memorised by the logged in user, scheduled for review by spaced repetition. Get or delete (DELETE) a range at /api/hifz/{id}",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "id", Value: "string", Description: "ID of the range"},
+				{Name: "chapter", Value: "int", Description: "Chapter"},
+				{Name: "start", Value: "int", Description: "First verse"},
+				{Name: "end", Value: "int", Description: "Last verse"},
+				{Name: "due", Value: "string", Description: "Date the next review is due"},
+				{Name: "interval", Value: "int", Description: "Days between reviews"},
+				{Name: "easiness", Value: "float", Description: "SM-2 easiness factor"},
+				{Name: "reviews", Value: "array", Description: "Past reviews with time and grade"},
+			},
+		}},
+	},
+	{
+		Name:        "Hifz Due",
+		Path:        "/api/hifz/due",
+		Params:      nil,
+		Description: "Get the ranges due for review today, most overdue first",
+		Response:    nil,
+	},
+	{
+		Name: "Hifz Review",
+		Path: "/api/hifz/{id}/review",
+		Params: []*Param{
+			{Name: "grade", Value: "int", Description: "Self graded recall from 0 (forgotten) to 5 (perfect). Below 3 restarts the schedule"},
+		},
+		Description: "Record a review of a range (POST) and schedule the next one",
+		Response:    nil,
+	},
+	{
+		Name: "Hifz Drill",
+		Path: "/api/hifz/{id}/drill",
+		Params: []*Param{
+			{Name: "count", Value: "int", Description: "Number of drills. Defaults to 10"},
+			{Name: "seed", Value: "int", Description: "Optional seed to repeat the same drills"},
+		},
+		Description: "Fill the next word drills for a range",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "verse", Value: "string", Description: "Verse of the drill"},
+				{Name: "prompt", Value: "string", Description: "Preceding words in Arabic"},
+				{Name: "answer", Value: "map", Description: "The next word with arabic, transliteration and english"},
+				{Name: "choices", Value: "array", Description: "Answer and distractors in random order"},
+			},
+		}},
+	},
+	{
+		Name: "Quiz",
+		Path: "/api/quiz",
+		Params: []*Param{
+			{Name: "type", Value: "string", Description: "names (meaning of a name of Allah), surah (which surah a verse is from), words (meaning of a Quran word) or narrator (who narrated a hadith). Defaults to names"},
+			{Name: "count", Value: "int", Description: "Number of questions (1-50). Defaults to 10"},
+			{Name: "seed", Value: "int", Description: "Optional seed. The same seed always gives the same questions"},
+		},
+		Description: "Get multiple choice questions. Answers aren't included, check them with /api/quiz/answer",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "type", Value: "string", Description: "Type of quiz"},
+				{Name: "seed", Value: "int", Description: "Seed used"},
+				{Name: "questions", Value: "array", Description: "Questions with id, prompt, arabic, choices and reference"},
+			},
+		}},
+	},
+	{
+		Name: "Quiz Answer",
+		Path: "/api/quiz/answer",
+		Params: []*Param{
+			{Name: "id", Value: "string", Description: "ID of the question"},
+			{Name: "answer", Value: "string", Description: "The chosen answer"},
+		},
+		Description: "Check the answer to a question (POST)",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "correct", Value: "bool", Description: "Whether the answer is correct"},
+				{Name: "answer", Value: "string", Description: "The correct answer"},
+				{Name: "reference", Value: "string", Description: "Link to the source"},
+			},
+		}},
+	},
+	{
+		Name: "Anki Export",
+		Path: "/api/export/anki",
+		Params: []*Param{
+			{Name: "deck", Value: "string", Description: "names (Names of Allah), words (Quran vocabulary) or verses (Arabic on the front, translation on the back)"},
+			{Name: "range", Value: "string", Description: "Chapter e.g 36 or verses e.g 2:255-257. Required for verses, optional for words which defaults to the whole Quran"},
+		},
+		Description: "Download a deck as an Anki package (.apkg) to study offline",
+		Response:    nil,
+	},
+	{
+		Name: "Hijri Convert",
+		Path: "/api/hijri/convert",
+		Params: []*Param{
+			{Name: "date", Value: "string", Description: "Gregorian date e.g 2024-03-11 or hijri date e.g 1445-09-01. Defaults to today"},
+			{Name: "from", Value: "string", Description: "Optional gregorian or hijri. Detected from the year if omitted"},
+			{Name: "offset", Value: "int", Description: "Optional days (-2 to 2) to adjust for local moon sighting. Defaults to the server's offset"},
+		},
+		Description: "Convert a date between the Gregorian and Umm al-Qura Hijri calendars",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "day", Value: "int", Description: "Day of the hijri month"},
+				{Name: "hijri", Value: "string", Description: "Hijri date (YYYY-MM-DD)"},
+				{Name: "display", Value: "string", Description: "Hijri date e.g 1st of Ramadan, 1445"},
+				{Name: "gregorian", Value: "string", Description: "Gregorian date (YYYY-MM-DD)"},
+				{Name: "weekday", Value: "string", Description: "Day of the week"},
+				{Name: "events", Value: "array", Description: "Islamic events on the day e.g Eid al-Fitr"},
+			},
+		}},
+	},
+	{
+		Name: "Hijri Month",
+		Path: "/api/hijri/month/{year}/{month}",
+		Params: []*Param{
+			{Name: "year", Value: "int", Description: "Hijri year e.g 1445"},
+			{Name: "month", Value: "int", Description: "Hijri month (1-12)"},
+			{Name: "offset", Value: "int", Description: "Optional days (-2 to 2) to adjust for local moon sighting. Defaults to the server's offset"},
+		},
+		Description: "Get a calendar grid of a hijri month with the gregorian date and events of each day",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "name", Value: "string", Description: "Name of the month"},
+				{Name: "days", Value: "int", Description: "Number of days in the month, 29 or 30"},
+				{Name: "offset", Value: "int", Description: "Offset applied"},
+				{Name: "weeks", Value: "array", Description: "Weeks of 7 days from Sunday, each day as returned by /api/hijri/convert or null outside the month"},
+			},
+		}},
+	},
+	{
+		Name: "Prayer Times",
+		Path: "/api/prayer",
+		Params: []*Param{
+			{Name: "lat", Value: "number", Description: "Latitude"},
+			{Name: "lon", Value: "number", Description: "Longitude"},
+			{Name: "date", Value: "string", Description: "Optional date in YYYY-MM-DD format. Defaults to today"},
+			{Name: "method", Value: "string", Description: "mwl (Muslim World League, default), isna, egyptian, karachi or umm_al_qura"},
+			{Name: "asr", Value: "string", Description: "shafii (default) or hanafi"},
+			{Name: "high_latitude", Value: "string", Description: "Rule for Fajr and Isha at high latitudes: angle (default), middle, seventh or none"},
+			{Name: "tz", Value: "string", Description: "Optional IANA timezone of the times e.g Europe/London. Defaults to UTC"},
+		},
+		Description: "Calculate the prayer times for a location from the position of the sun",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "date", Value: "string", Description: "Date of the times"},
+				{Name: "method", Value: "string", Description: "Method used"},
+				{Name: "method_name", Value: "string", Description: "Name of the method"},
+				{Name: "times", Value: "map", Description: "fajr, sunrise, dhuhr, asr, maghrib and isha in RFC3339 format"},
+			},
+		}},
+	},
+	{
+		Name: "Qibla",
+		Path: "/api/qibla",
+		Params: []*Param{
+			{Name: "lat", Value: "number", Description: "Latitude"},
+			{Name: "lon", Value: "number", Description: "Longitude"},
+		},
+		Description: "Get the direction of the Kaaba from a location",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "bearing", Value: "number", Description: "Great circle bearing in degrees clockwise from true north"},
+				{Name: "distance", Value: "number", Description: "Distance to the Kaaba in km"},
+			},
+		}},
+	},
+	{
+		Name: "Calendar Events",
+		Path: "/api/calendar/events.ics",
+		Params: []*Param{
+			{Name: "days", Value: "int", Description: "Optional days from today to include, up to 366. Defaults to 365"},
+			{Name: "offset", Value: "int", Description: "Optional hijri offset in days between -2 and 2"},
+		},
+		Description: "Subscribable iCalendar feed of the events of the Islamic calendar",
+		Response:    nil,
+	},
+	{
+		Name: "Calendar Prayer Times",
+		Path: "/api/calendar/prayer.ics",
+		Params: []*Param{
+			{Name: "lat", Value: "number", Description: "Latitude"},
+			{Name: "lon", Value: "number", Description: "Longitude"},
+			{Name: "method", Value: "string", Description: "Optional method, see /api/prayer"},
+			{Name: "asr", Value: "string", Description: "shafii (default) or hanafi"},
+			{Name: "high_latitude", Value: "string", Description: "Optional high latitude rule, see /api/prayer"},
+			{Name: "tz", Value: "string", Description: "Optional IANA timezone of the location. Defaults to UTC"},
+			{Name: "days", Value: "int", Description: "Optional days from today to include, up to 366. Defaults to 30"},
+		},
+		Description: "Subscribable iCalendar feed of the prayer times at a location",
+		Response:    nil,
+	},
+	{
+		Name: "Calendar Daily Reminder",
+		Path: "/api/calendar/daily.ics",
+		Params: []*Param{
+			{Name: "days", Value: "int", Description: "Optional number of recent days to include, up to 366. Defaults to 30"},
+		},
+		Description: "Subscribable iCalendar feed of the daily verse, hadith and name of Allah",
+		Response:    nil,
+	},
+	{
+		Name: "Themes",
+		Path: "/api/themes",
+		Params: []*Param{
+			{Name: "date", Value: "string", Description: "Optional date in YYYY-MM-DD format to only return the themes which apply to it"},
+		},
+		Description: "Get the themes of the Islamic calendar such as Ramadan and Fridays whose verses, hadith and names are preferred for the daily reminder, in order of preference",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "name", Value: "string", Description: "Name of the theme e.g ramadan"},
+				{Name: "title", Value: "string", Description: "Title e.g Ramadan"},
+				{Name: "month", Value: "int", Description: "Hijri month it applies to, if any"},
+				{Name: "days", Value: "array", Description: "Days of the hijri month it applies to, if any"},
+				{Name: "weekday", Value: "string", Description: "Day of the week it applies to, if any"},
+				{Name: "verses", Value: "array", Description: "Verses e.g 2:183"},
+				{Name: "hadith", Value: "array", Description: "Hadith as book:number"},
+				{Name: "names", Value: "array", Description: "Numbers of the names of Allah"},
+			},
+		}},
+	},
 	{
 		Name: "Daily verse, hadith and name of Allah (by Date)",
 		Path: "/api/daily",
@@ -202,6 +864,28 @@ var Endpoints = []*Endpoint{
 			},
 		}},
 	},
+	{
+		Name: "Daily reminder for any date",
+		Path: "/api/daily/{date}",
+		Params: []*Param{
+			{Name: "date", Value: "string", Description: "Date in YYYY-MM-DD format, past or future"},
+			{Name: "computed", Value: "bool", Description: "Optional. Compute the entry even if it was archived, to audit the archive"},
+		},
+		Description: "Returns the daily reminder archived for the date, otherwise it's computed. Content is selected deterministically from the date so the same date always gives the same verse, hadith and name",
+		Response: []*Value{{
+			Type: "JSON",
+			Params: []*Param{
+				{Name: "name", Value: "string", Description: "Name of Allah"},
+				{Name: "hadith", Value: "string", Description: "Hadith from Sahih Bukhari"},
+				{Name: "verse", Value: "string", Description: "A verse of the Quran"},
+				{Name: "links", Value: "map", Description: "Links to relevant content"},
+				{Name: "message", Value: "string", Description: "Reflection, the default message for computed entries"},
+				{Name: "date", Value: "string", Description: "Gregorian date (YYYY-MM-DD)"},
+				{Name: "hijri", Value: "string", Description: "Hijri date (display format)"},
+				{Name: "selection", Value: "map", Description: "Seed, slot, window and keys of the selected verse, hadith and name"},
+			},
+		}},
+	},
 }
 
 func (a *Api) Markdown() string {
@@ -0,0 +1,452 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Name of the cookie holding the signed session
+const SessionCookie = "reminder_session"
+
+// How long a login lasts
+var SessionDuration = 30 * 24 * time.Hour
+
+// How long a magic login link is valid for
+var LoginLinkDuration = 15 * time.Minute
+
+// Minimum password length
+const MinPasswordLength = 8
+
+var sessionKeyFile = ReminderPath("session.key")
+var sessionKey []byte
+var sessionKeyOnce sync.Once
+
+// loginTokens are the outstanding magic link tokens mapped to email
+var loginMtx sync.Mutex
+var loginTokens = map[string]loginToken{}
+
+type loginToken struct {
+	Email string
+	// Hash of the password chosen at signup, set once the link is followed
+	PasswordHash string
+	Created      time.Time
+	Expires      time.Time
+}
+
+type contextKey string
+
+const userContextKey contextKey = "user"
+
+// key returns the secret used to sign sessions, from REMINDER_SESSION_KEY
+// or generated once and stored under the reminder dir
+func key() []byte {
+	sessionKeyOnce.Do(func() {
+		if k := os.Getenv("REMINDER_SESSION_KEY"); len(k) > 0 {
+			sessionKey = []byte(k)
+			return
+		}
+		if b, err := os.ReadFile(sessionKeyFile); err == nil && len(b) > 0 {
+			sessionKey = b
+			return
+		}
+		sessionKey = make([]byte, 32)
+		rand.Read(sessionKey)
+		if err := os.WriteFile(sessionKeyFile, sessionKey, 0600); err != nil {
+			log.Printf("Failed to save session key: %v", err)
+		}
+	})
+	return sessionKey
+}
+
+func sign(data string) string {
+	mac := hmac.New(sha256.New, key())
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// NewSession returns a signed session value for the user
+func NewSession(email string, expires time.Time) string {
+	data := base64.RawURLEncoding.EncodeToString([]byte(email + "|" + strconv.FormatInt(expires.Unix(), 10)))
+	return data + "." + sign(data)
+}
+
+// ParseSession verifies a session value and returns the email
+func ParseSession(value string) (string, error) {
+	data, sig, ok := strings.Cut(value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(sign(data))) {
+		return "", fmt.Errorf("invalid session")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return "", fmt.Errorf("invalid session")
+	}
+	email, exp, ok := strings.Cut(string(b), "|")
+	if !ok {
+		return "", fmt.Errorf("invalid session")
+	}
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return "", fmt.Errorf("session expired")
+	}
+	return email, nil
+}
+
+func secure(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+// Login sets the session cookie for the user
+func Login(w http.ResponseWriter, r *http.Request, email string) error {
+	if err := UpdateUser(email, func(u *User) { u.Login = time.Now() }); err != nil {
+		return err
+	}
+	expires := time.Now().Add(SessionDuration)
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    NewSession(email, expires),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   secure(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// Logout clears the session cookie
+func Logout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secure(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// CurrentUser returns the logged in user for the request
+func CurrentUser(r *http.Request) (User, bool) {
+	if u, ok := r.Context().Value(userContextKey).(User); ok {
+		return u, true
+	}
+	c, err := r.Cookie(SessionCookie)
+	if err != nil {
+		return User{}, false
+	}
+	email, err := ParseSession(c.Value)
+	if err != nil {
+		return User{}, false
+	}
+	return GetUser(email)
+}
+
+// RequireUser only calls the handler for logged in users, who are then
+// available via CurrentUser or UserFromContext
+func RequireUser(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, ok := CurrentUser(r)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		h(w, r.WithContext(context.WithValue(r.Context(), userContextKey, u)))
+	}
+}
+
+// UserFromContext returns the user set by RequireUser
+func UserFromContext(ctx context.Context) (User, bool) {
+	u, ok := ctx.Value(userContextKey).(User)
+	return u, ok
+}
+
+// SetPassword stores the bcrypt hash of the password for the user
+func SetPassword(email, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	return UpdateUser(email, func(u *User) { u.PasswordHash = hash })
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether the password matches the user's. Passwords
+// of unconfirmed accounts can't be used.
+func CheckPassword(u User, password string) bool {
+	if len(u.PasswordHash) == 0 || !u.Confirmed {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+}
+
+// newLoginToken creates a single use magic link token for the email,
+// optionally carrying the password hash chosen at signup
+func newLoginToken(email, passwordHash string) string {
+	token := newToken()
+	loginMtx.Lock()
+	defer loginMtx.Unlock()
+	now := time.Now()
+	for t, lt := range loginTokens {
+		if now.After(lt.Expires) {
+			delete(loginTokens, t)
+		}
+	}
+	loginTokens[token] = loginToken{Email: email, PasswordHash: passwordHash, Created: now, Expires: now.Add(LoginLinkDuration)}
+	return token
+}
+
+// loginPending reports whether a link sent to the email in the last
+// EmailResendInterval is still unused
+func loginPending(email string) bool {
+	loginMtx.Lock()
+	defer loginMtx.Unlock()
+	now := time.Now()
+	for _, lt := range loginTokens {
+		if lt.Email == email && now.Before(lt.Expires) && now.Sub(lt.Created) < EmailResendInterval {
+			return true
+		}
+	}
+	return false
+}
+
+// useLoginToken consumes a magic link token
+func useLoginToken(token string) (loginToken, bool) {
+	loginMtx.Lock()
+	defer loginMtx.Unlock()
+	lt, ok := loginTokens[token]
+	delete(loginTokens, token)
+	if !ok || time.Now().After(lt.Expires) {
+		return loginToken{}, false
+	}
+	return lt, true
+}
+
+// sendSignupLink emails a link to confirm the address, which sets the
+// password and logs in
+func sendSignupLink(email, passwordHash string) error {
+	link := fmt.Sprintf("%s/api/login/verify?token=%s", BaseURL, url.QueryEscape(newLoginToken(email, passwordHash)))
+
+	return SendEmail(&Email{
+		To:      email,
+		Subject: "Confirm your Reminder account",
+		Text:    fmt.Sprintf("Confirm your email address to finish creating your Reminder account by visiting %s\n\nThe link expires in %d minutes. If you didn't sign up you can ignore this email.\n", link, int(LoginLinkDuration.Minutes())),
+		HTML: fmt.Sprintf(`<p><a href="%s">Confirm your email address</a> to finish creating your Reminder account.</p><p style="font-size: 12px; color: #666;">The link expires in %d minutes. If you didn't sign up you can ignore this email.</p>`,
+			html.EscapeString(link), int(LoginLinkDuration.Minutes())),
+	})
+}
+
+// sendLoginLink emails a magic login link
+func sendLoginLink(email string) error {
+	link := fmt.Sprintf("%s/api/login/verify?token=%s", BaseURL, url.QueryEscape(newLoginToken(email, "")))
+
+	return SendEmail(&Email{
+		To:      email,
+		Subject: "Log in to Reminder",
+		Text:    fmt.Sprintf("Log in to Reminder by visiting %s\n\nThe link expires in %d minutes. If you didn't request it you can ignore this email.\n", link, int(LoginLinkDuration.Minutes())),
+		HTML: fmt.Sprintf(`<p><a href="%s">Log in to Reminder</a></p><p style="font-size: 12px; color: #666;">The link expires in %d minutes. If you didn't request it you can ignore this email.</p>`,
+			html.EscapeString(link), int(LoginLinkDuration.Minutes())),
+	})
+}
+
+type credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+func readCredentials(r *http.Request) (credentials, error) {
+	var c credentials
+	b, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, err
+	}
+	addr, err := mail.ParseAddress(c.Email)
+	if err != nil {
+		return c, fmt.Errorf("invalid email")
+	}
+	c.Email = strings.ToLower(addr.Address)
+	return c, nil
+}
+
+// profile is the public view of a user
+func profile(u User) map[string]interface{} {
+	return map[string]interface{}{
+		"email":     u.Email,
+		"confirmed": u.Confirmed,
+		"digest":    u.Digest,
+		"password":  len(u.PasswordHash) > 0,
+		"created":   u.Created,
+	}
+}
+
+func registerAuthRoutes(mux *http.ServeMux) {
+	// Create an account with a password. The account is only created, the
+	// password set and the user logged in, once the link emailed to the
+	// address is followed. The response is the same whether or not the
+	// address has an account, which is sent a login link instead.
+	mux.HandleFunc("/api/signup", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		c, err := readCredentials(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(c.Password) < MinPasswordLength {
+			http.Error(w, fmt.Sprintf("password must be at least %d characters", MinPasswordLength), http.StatusBadRequest)
+			return
+		}
+		// hashed either way so the time taken doesn't tell accounts apart
+		hash, err := hashPassword(c.Password)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if loginPending(c.Email) {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		if !allowEmail(w, r, c.Email) {
+			return
+		}
+		// confirmed addresses must log in via the magic link
+		if u, ok := GetUser(c.Email); ok && u.Confirmed {
+			err = sendLoginLink(c.Email)
+		} else {
+			err = sendSignupLink(c.Email, hash)
+		}
+		if err != nil {
+			log.Printf("Failed to send signup link to %s: %v", c.Email, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	// Log in with a password
+	mux.HandleFunc("/api/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		c, err := readCredentials(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		u, ok := GetUser(c.Email)
+		if !ok || !CheckPassword(u, c.Password) {
+			http.Error(w, "invalid email or password", http.StatusUnauthorized)
+			return
+		}
+		if err := Login(w, r, u.Email); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(profile(u))
+	})
+
+	// Email a magic login link, the account is created when it's followed.
+	// A link is not resent while a recent one is pending.
+	mux.HandleFunc("/api/login/email", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		c, err := readCredentials(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if loginPending(c.Email) {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if !allowEmail(w, r, c.Email) {
+			return
+		}
+		if err := sendLoginLink(c.Email); err != nil {
+			log.Printf("Failed to send login link to %s: %v", c.Email, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// Follow the magic login link
+	mux.HandleFunc("/api/login/verify", func(w http.ResponseWriter, r *http.Request) {
+		lt, ok := useLoginToken(r.URL.Query().Get("token"))
+		if !ok {
+			http.Error(w, "invalid or expired link", http.StatusUnauthorized)
+			return
+		}
+		// following the link proves ownership of the address
+		if _, ok := GetUser(lt.Email); !ok {
+			if err := AddUser(lt.Email); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := ConfirmUser(lt.Email); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if len(lt.PasswordHash) > 0 {
+			if err := UpdateUser(lt.Email, func(u *User) { u.PasswordHash = lt.PasswordHash }); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := Login(w, r, lt.Email); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/", http.StatusFound)
+	})
+
+	mux.HandleFunc("/api/logout", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		Logout(w, r)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	mux.HandleFunc("/api/me", RequireUser(func(w http.ResponseWriter, r *http.Request) {
+		u, _ := UserFromContext(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(profile(u))
+	}))
+}
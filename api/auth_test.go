@@ -0,0 +1,144 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func init() {
+	// don't create a session key under the reminder dir
+	sessionKeyOnce.Do(func() { sessionKey = []byte("test") })
+}
+
+func TestSession(t *testing.T) {
+	value := NewSession("test@example.com", time.Now().Add(time.Hour))
+	if email, err := ParseSession(value); err != nil || email != "test@example.com" {
+		t.Fatalf("expected valid session, got %q %v", email, err)
+	}
+
+	// tampering with the email invalidates the signature
+	forged := NewSession("other@example.com", time.Now().Add(time.Hour))
+	_, sig, _ := strings.Cut(value, ".")
+	data, _, _ := strings.Cut(forged, ".")
+	if _, err := ParseSession(data + "." + sig); err == nil {
+		t.Fatal("expected forged session to be rejected")
+	}
+
+	if _, err := ParseSession(NewSession("test@example.com", time.Now().Add(-time.Minute))); err == nil {
+		t.Fatal("expected expired session to be rejected")
+	}
+}
+
+func TestPasswordLogin(t *testing.T) {
+	resetEmail(t)
+
+	srv := newSMTPServer(t)
+	defer srv.Close()
+	SMTPHost, SMTPPort, _ = net.SplitHostPort(srv.Addr().String())
+
+	mux := http.NewServeMux()
+	registerAuthRoutes(mux)
+
+	do := func(method, path, body string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := do("POST", "/api/signup", `{"email":"Test@Example.com","password":"short"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected short password to be rejected, got %d", w.Code)
+	}
+	if w := do("POST", "/api/signup", `{"email":"Test@Example.com","password":"correct horse"}`); w.Code != http.StatusAccepted || len(w.Result().Cookies()) > 0 {
+		t.Fatalf("signup failed: %d %s", w.Code, w.Body.String())
+	}
+	msgs := srv.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("expected a confirmation link, got %d messages", len(msgs))
+	}
+	if _, ok := GetUser("test@example.com"); ok {
+		t.Fatal("expected the account to be created by the link")
+	}
+
+	// the password can't be used until the link is followed
+	if w := do("POST", "/api/login", `{"email":"test@example.com","password":"correct horse"}`); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected unconfirmed login to be rejected, got %d", w.Code)
+	}
+	// the link is quoted-printable encoded
+	token := regexp.MustCompile(`token=3D(\w+)`).FindStringSubmatch(strings.ReplaceAll(msgs[0], "=\r\n", ""))
+	if w := do("GET", "/api/login/verify?token="+token[1], ""); w.Code != http.StatusFound || len(w.Result().Cookies()) != 1 {
+		t.Fatalf("verify failed: %d", w.Code)
+	}
+	if u, ok := GetUser("test@example.com"); !ok || !u.Confirmed {
+		t.Fatalf("expected a confirmed account, got %+v", u)
+	}
+
+	// an existing account gets the same response and a login link, without
+	// its password changing
+	loginMtx.Lock()
+	loginTokens = map[string]loginToken{}
+	loginMtx.Unlock()
+	if w := do("POST", "/api/signup", `{"email":"test@example.com","password":"another one"}`); w.Code != http.StatusAccepted {
+		t.Fatalf("expected existing account to be accepted, got %d", w.Code)
+	}
+	if msgs := srv.Messages(); len(msgs) != 2 || !strings.Contains(msgs[1], "Subject: Log in to Reminder") {
+		t.Fatalf("expected a login link, got %v", msgs)
+	}
+	if w := do("POST", "/api/login", `{"email":"test@example.com","password":"another one"}`); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the password to be unchanged, got %d", w.Code)
+	}
+	if w := do("POST", "/api/login", `{"email":"test@example.com","password":"wrong horse"}`); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected wrong password to be rejected, got %d", w.Code)
+	}
+	if w := do("GET", "/api/me", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected /api/me to require login, got %d", w.Code)
+	}
+
+	w := do("POST", "/api/login", `{"email":"test@example.com","password":"correct horse"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login failed: %d", w.Code)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteLaxMode {
+		t.Fatalf("expected HttpOnly SameSite session cookie, got %+v", cookies)
+	}
+
+	w = do("GET", "/api/me", "", cookies...)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"email":"test@example.com"`) {
+		t.Fatalf("expected profile, got %d %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "password_hash") {
+		t.Fatal("profile must not expose the password hash")
+	}
+}
+
+func TestConfirmClearsPassword(t *testing.T) {
+	usersFile = filepath.Join(t.TempDir(), "users.json")
+	users.Users = make(map[string]User)
+
+	// a password set on an address before its owner confirmed it
+	AddUser("test@example.com")
+	UpdateUser("test@example.com", func(u *User) { u.PasswordHash = "hash" })
+	if err := ConfirmUser("test@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := GetUser("test@example.com"); !u.Confirmed || len(u.PasswordHash) > 0 {
+		t.Fatalf("expected confirmed user without a password, got %+v", u)
+	}
+
+	// confirmed accounts keep theirs
+	SetPassword("test@example.com", "correct horse")
+	ConfirmUser("test@example.com")
+	if u, _ := GetUser("test@example.com"); !CheckPassword(u, "correct horse") {
+		t.Fatal("expected the password to be kept")
+	}
+}
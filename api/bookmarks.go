@@ -0,0 +1,365 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Types of bookmark matching the data-type of bookmark buttons
+var BookmarkTypes = []string{"quran", "hadith", "names"}
+
+// Bookmark is a saved verse, hadith or name
+type Bookmark struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+	// ISO 8601 time as set by the browser e.g 2024-01-10T12:00:00.000Z
+	Timestamp string `json:"timestamp"`
+}
+
+// Bookmarks maps type to key to bookmark, the same shape as the
+// reminder_bookmarks blob kept in localStorage
+type Bookmarks map[string]map[string]Bookmark
+
+// BookmarksDeleted is the type holding a tombstone for each removed
+// bookmark, keyed by type:key with the time of removal, so removals are
+// merged across devices like additions
+const BookmarksDeleted = "deleted"
+
+// BookmarkTombstoneTTL is how long tombstones are kept. A device which
+// hasn't synced for longer may restore a removed bookmark.
+var BookmarkTombstoneTTL = 90 * 24 * time.Hour
+
+// MaxBookmarkLabel is the longest label accepted in characters
+const MaxBookmarkLabel = 200
+
+// bookmarkKeys matches the keys of each type e.g 2:255 for a verse, 1:1
+// for a hadith by book and number or 99 for a name
+var bookmarkKeys = map[string]*regexp.Regexp{
+	"quran":  regexp.MustCompile(`^[0-9]+:[0-9]+$`),
+	"hadith": regexp.MustCompile(`^[0-9]+:[0-9]+$`),
+	"names":  regexp.MustCompile(`^[0-9]+$`),
+}
+
+// bookmarkURLs matches the relative links bookmarks may point to on this
+// site e.g /quran/2#255, so a shared bookmark can't link elsewhere
+var bookmarkURLs = regexp.MustCompile(`^/(quran|hadith|names)/[0-9]+(#[0-9]+)?$`)
+
+// layout of bookmark timestamps, which compare in time order as strings
+const bookmarkLayout = "2006-01-02T15:04:05.000Z"
+
+var bookmarksFile = ReminderPath("bookmarks.json")
+var bookmarksMtx sync.RWMutex
+
+// bookmarks by owner, either user:<email> or sync:<code>
+var bookmarks = map[string]Bookmarks{}
+
+// SyncCodeTTL is how long a new sync code is held in memory for its first
+// bookmarks, only then is it saved
+var SyncCodeTTL = 24 * time.Hour
+
+// pendingSyncCodes are the codes created but not yet written to, with the
+// time they were created, guarded by bookmarksMtx
+var pendingSyncCodes = map[string]time.Time{}
+
+// syncCodeLimiter limits the sync codes created by each client
+var syncCodeLimiter = NewLimiter(10, time.Hour)
+
+func LoadBookmarks() error {
+	f, err := os.Open(bookmarksFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	bookmarksMtx.Lock()
+	defer bookmarksMtx.Unlock()
+	return json.NewDecoder(f).Decode(&bookmarks)
+}
+
+// saveBookmarks writes the bookmarks, the caller must hold the lock
+func saveBookmarks() error {
+	b, err := json.Marshal(bookmarks)
+	if err != nil {
+		return err
+	}
+	return WriteFile(bookmarksFile, b, 0600)
+}
+
+func bookmarkTimestamp() string {
+	return time.Now().UTC().Format(bookmarkLayout)
+}
+
+// validBookmarkKey reports whether the key is valid for the type
+func validBookmarkKey(typ, key string) bool {
+	re, ok := bookmarkKeys[typ]
+	return ok && re.MatchString(key)
+}
+
+// validBookmark reports whether the bookmark can be stored for the type
+// and key, with a link to the same type of page on this site
+func validBookmark(typ, key string, bm Bookmark) bool {
+	if !validBookmarkKey(typ, key) || utf8.RuneCountInString(bm.Label) > MaxBookmarkLabel {
+		return false
+	}
+	m := bookmarkURLs.FindStringSubmatch(bm.URL)
+	return m != nil && m[1] == typ
+}
+
+// Add sets a bookmark, clearing any tombstone
+func (b Bookmarks) Add(typ, key string, bm Bookmark) {
+	if b[typ] == nil {
+		b[typ] = map[string]Bookmark{}
+	}
+	b[typ][key] = bm
+	delete(b[BookmarksDeleted], typ+":"+key)
+}
+
+// Remove removes a bookmark leaving a tombstone with the timestamp
+func (b Bookmarks) Remove(typ, key, timestamp string) {
+	delete(b[typ], key)
+	if b[BookmarksDeleted] == nil {
+		b[BookmarksDeleted] = map[string]Bookmark{}
+	}
+	b[BookmarksDeleted][typ+":"+key] = Bookmark{Timestamp: timestamp}
+}
+
+// Merge adds the other bookmarks and removals keeping the most recent of
+// any duplicates, so a bookmark removed after it was added stays removed
+func (b Bookmarks) Merge(other Bookmarks) {
+	for typ, entries := range other {
+		if !contains(BookmarkTypes, typ) {
+			continue
+		}
+		for key, bm := range entries {
+			if !validBookmark(typ, key, bm) {
+				continue
+			}
+			if existing, ok := b[typ][key]; ok && existing.Timestamp >= bm.Timestamp {
+				continue
+			}
+			if tomb, ok := b[BookmarksDeleted][typ+":"+key]; ok && tomb.Timestamp >= bm.Timestamp {
+				continue
+			}
+			b.Add(typ, key, bm)
+		}
+	}
+
+	for id, tomb := range other[BookmarksDeleted] {
+		typ, key, ok := strings.Cut(id, ":")
+		if !ok || !validBookmarkKey(typ, key) {
+			continue
+		}
+		if existing, ok := b[BookmarksDeleted][id]; ok && existing.Timestamp >= tomb.Timestamp {
+			continue
+		}
+		if bm, ok := b[typ][key]; ok && bm.Timestamp > tomb.Timestamp {
+			continue
+		}
+		b.Remove(typ, key, tomb.Timestamp)
+	}
+}
+
+// prune drops the tombstones older than BookmarkTombstoneTTL
+func (b Bookmarks) prune(now time.Time) {
+	cutoff := now.Add(-BookmarkTombstoneTTL).UTC().Format(bookmarkLayout)
+	for id, tomb := range b[BookmarksDeleted] {
+		if tomb.Timestamp < cutoff {
+			delete(b[BookmarksDeleted], id)
+		}
+	}
+}
+
+// copy returns a deep copy including every type and the tombstones
+func (b Bookmarks) copy() Bookmarks {
+	c := Bookmarks{}
+	for _, typ := range BookmarkTypes {
+		c[typ] = map[string]Bookmark{}
+		for key, bm := range b[typ] {
+			c[typ][key] = bm
+		}
+	}
+	c[BookmarksDeleted] = map[string]Bookmark{}
+	for id, tomb := range b[BookmarksDeleted] {
+		c[BookmarksDeleted][id] = tomb
+	}
+	return c
+}
+
+// GetBookmarks returns the bookmarks for an owner
+func GetBookmarks(owner string) Bookmarks {
+	bookmarksMtx.RLock()
+	defer bookmarksMtx.RUnlock()
+	return bookmarks[owner].copy()
+}
+
+// UpdateBookmarks applies fn to the owner's bookmarks and saves them,
+// pruning old tombstones
+func UpdateBookmarks(owner string, fn func(b Bookmarks)) (Bookmarks, error) {
+	bookmarksMtx.Lock()
+	defer bookmarksMtx.Unlock()
+	b := bookmarks[owner].copy()
+	fn(b)
+	b.prune(time.Now())
+	bookmarks[owner] = b
+	delete(pendingSyncCodes, owner)
+	return b.copy(), saveBookmarks()
+}
+
+// NewSyncCode creates an empty set of bookmarks which can be accessed
+// from any device with the returned code e.g 7KQ2-M9XD-4TPA. The code is
+// saved with its first bookmarks, or forgotten after SyncCodeTTL.
+func NewSyncCode() string {
+	const alphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+	b := make([]byte, 12)
+	rand.Read(b)
+	var code strings.Builder
+	for i, c := range b {
+		if i > 0 && i%4 == 0 {
+			code.WriteByte('-')
+		}
+		code.WriteByte(alphabet[int(c)%len(alphabet)])
+	}
+
+	bookmarksMtx.Lock()
+	defer bookmarksMtx.Unlock()
+	now := time.Now()
+	for owner, created := range pendingSyncCodes {
+		if now.Sub(created) > SyncCodeTTL {
+			delete(pendingSyncCodes, owner)
+		}
+	}
+	pendingSyncCodes["sync:"+code.String()] = now
+	return code.String()
+}
+
+// bookmarkOwner identifies the bookmarks for the request from the logged
+// in user or a sync code in the X-Sync-Code header
+func bookmarkOwner(r *http.Request) (string, bool) {
+	if u, ok := CurrentUser(r); ok {
+		return "user:" + u.Email, true
+	}
+	code := strings.ToUpper(strings.TrimSpace(r.Header.Get("X-Sync-Code")))
+	if len(code) == 0 {
+		return "", false
+	}
+	owner := "sync:" + code
+	bookmarksMtx.RLock()
+	defer bookmarksMtx.RUnlock()
+	if _, ok := bookmarks[owner]; ok {
+		return owner, true
+	}
+	created, ok := pendingSyncCodes[owner]
+	return owner, ok && time.Since(created) <= SyncCodeTTL
+}
+
+func registerBookmarkRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/api/bookmarks", func(w http.ResponseWriter, r *http.Request) {
+		owner, ok := bookmarkOwner(r)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var result Bookmarks
+		var err error
+
+		switch r.Method {
+		case http.MethodGet:
+			result = GetBookmarks(owner)
+		case http.MethodPost:
+			var req struct {
+				Type  string `json:"type"`
+				Key   string `json:"key"`
+				Label string `json:"label"`
+				URL   string `json:"url"`
+			}
+			b, _ := io.ReadAll(r.Body)
+			if err := json.Unmarshal(b, &req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			bm := Bookmark{Label: req.Label, URL: req.URL, Timestamp: bookmarkTimestamp()}
+			if !validBookmark(req.Type, req.Key, bm) {
+				http.Error(w, "invalid bookmark", http.StatusBadRequest)
+				return
+			}
+			result, err = UpdateBookmarks(owner, func(b Bookmarks) {
+				b.Add(req.Type, req.Key, bm)
+			})
+		case http.MethodDelete:
+			typ, key := r.URL.Query().Get("type"), r.URL.Query().Get("key")
+			if !validBookmarkKey(typ, key) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			result, err = UpdateBookmarks(owner, func(b Bookmarks) {
+				b.Remove(typ, key, bookmarkTimestamp())
+			})
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	})
+
+	// Merge a reminder_bookmarks blob from localStorage
+	mux.HandleFunc("/api/bookmarks/import", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		owner, ok := bookmarkOwner(r)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var blob Bookmarks
+		b, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(b, &blob); err != nil {
+			http.Error(w, fmt.Sprintf("invalid bookmarks: %v", err), http.StatusBadRequest)
+			return
+		}
+		result, err := UpdateBookmarks(owner, func(b Bookmarks) {
+			b.Merge(blob)
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	})
+
+	// Create a sync code to share bookmarks across devices without an account
+	mux.HandleFunc("/api/bookmarks/sync", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if !syncCodeLimiter.Allow(clientIP(r)) {
+			http.Error(w, "too many sync codes requested, try again later", http.StatusTooManyRequests)
+			return
+		}
+		code := NewSyncCode()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"code": code})
+	})
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBookmarksMerge(t *testing.T) {
+	b := Bookmarks{
+		"quran": {
+			"2:255": {Label: "Ayat al-Kursi", URL: "/quran/2#255", Timestamp: "2024-01-10T12:00:00.000Z"},
+			"1:1":   {Label: "Old", URL: "/quran/1#1", Timestamp: "2024-01-01T00:00:00.000Z"},
+		},
+	}
+
+	b.Merge(Bookmarks{
+		"quran": {
+			"2:255": {Label: "Older", URL: "/quran/2#255", Timestamp: "2024-01-09T12:00:00.000Z"},
+			"1:1":   {Label: "Newer", URL: "/quran/1#1", Timestamp: "2024-01-02T00:00:00.000Z"},
+		},
+		"hadith":  {"1:1": {Label: "Hadith 1", URL: "/hadith/1#1"}},
+		"unknown": {"x": {Label: "ignored"}},
+	})
+
+	if got := b["quran"]["2:255"].Label; got != "Ayat al-Kursi" {
+		t.Fatalf("expected newer local bookmark to be kept, got %q", got)
+	}
+	if got := b["quran"]["1:1"].Label; got != "Newer" {
+		t.Fatalf("expected newer imported bookmark to win, got %q", got)
+	}
+	if _, ok := b["hadith"]["1:1"]; !ok {
+		t.Fatal("expected imported hadith bookmark")
+	}
+	if _, ok := b["unknown"]; ok {
+		t.Fatal("expected unknown types to be ignored")
+	}
+}
+
+func TestBookmarksMergeRemovals(t *testing.T) {
+	b := Bookmarks{}
+	b.Add("quran", "2:255", Bookmark{Label: "Ayat al-Kursi", Timestamp: "2024-01-10T12:00:00.000Z"})
+	b.Add("quran", "1:1", Bookmark{Label: "Al-Fatiha", Timestamp: "2024-01-10T12:00:00.000Z"})
+
+	// another device removed one bookmark after it was added and the
+	// other before it was added again here
+	b.Merge(Bookmarks{
+		"quran": {},
+		BookmarksDeleted: {
+			"quran:2:255": {Timestamp: "2024-01-11T12:00:00.000Z"},
+			"quran:1:1":   {Timestamp: "2024-01-09T12:00:00.000Z"},
+		},
+	})
+	if _, ok := b["quran"]["2:255"]; ok {
+		t.Fatal("expected the newer removal to win")
+	}
+	if _, ok := b["quran"]["1:1"]; !ok {
+		t.Fatal("expected the newer bookmark to win")
+	}
+
+	// a device which still has the removed bookmark doesn't restore it
+	b.Merge(Bookmarks{"quran": {"2:255": {Label: "Ayat al-Kursi", URL: "/quran/2#255", Timestamp: "2024-01-10T12:00:00.000Z"}}})
+	if _, ok := b["quran"]["2:255"]; ok {
+		t.Fatal("expected the stale bookmark to stay removed")
+	}
+
+	// old tombstones are pruned
+	b.prune(time.Date(2024, 1, 11, 12, 0, 0, 0, time.UTC).Add(BookmarkTombstoneTTL + time.Second))
+	if len(b[BookmarksDeleted]) != 0 {
+		t.Fatalf("expected tombstones to be pruned, got %v", b[BookmarksDeleted])
+	}
+}
+
+func TestBookmarksValidation(t *testing.T) {
+	b := Bookmarks{}
+	b.Merge(Bookmarks{
+		"quran": {
+			"2:255":  {Label: "Ayat al-Kursi", URL: "/quran/2#255"},
+			"1:1":    {Label: "Script", URL: "javascript:alert(1)"},
+			"1:2":    {Label: "Elsewhere", URL: "//example.com/quran/1"},
+			"1:3":    {Label: "Hadith", URL: "/hadith/1#3"},
+			"1:4":    {Label: strings.Repeat("a", MaxBookmarkLabel+1), URL: "/quran/1#4"},
+			"');x('": {Label: "Key", URL: "/quran/1#5"},
+		},
+		"names": {"99": {Label: "<img src=x onerror=alert(1)>", URL: "/names/99"}},
+		BookmarksDeleted: {
+			"quran:');x('": {Timestamp: "2024-01-10T12:00:00.000Z"},
+		},
+	})
+
+	if len(b["quran"]) != 1 {
+		t.Fatalf("expected only the valid verse bookmark, got %v", b["quran"])
+	}
+	if _, ok := b["quran"]["2:255"]; !ok {
+		t.Fatal("expected the valid verse bookmark")
+	}
+	// labels are text, escaped by the page rendering them
+	if _, ok := b["names"]["99"]; !ok {
+		t.Fatal("expected the name bookmark")
+	}
+	if len(b[BookmarksDeleted]) != 0 {
+		t.Fatalf("expected the invalid tombstone to be ignored, got %v", b[BookmarksDeleted])
+	}
+}
+
+func TestBookmarksSyncCode(t *testing.T) {
+	bookmarksFile = filepath.Join(t.TempDir(), "bookmarks.json")
+	bookmarks = map[string]Bookmarks{}
+	pendingSyncCodes = map[string]time.Time{}
+	limiter := syncCodeLimiter
+	syncCodeLimiter = NewLimiter(2, time.Hour)
+	t.Cleanup(func() { syncCodeLimiter = limiter })
+
+	mux := http.NewServeMux()
+	registerBookmarkRoutes(mux)
+
+	do := func(method, path, code, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if len(code) > 0 {
+			r.Header.Set("X-Sync-Code", code)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do("POST", "/api/bookmarks/sync", "", "")
+	var resp struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Code) == 0 {
+		t.Fatalf("expected a sync code, got %d %s", w.Code, w.Body.String())
+	}
+
+	// nothing is saved until the code is written to
+	if _, err := os.Stat(bookmarksFile); !os.IsNotExist(err) {
+		t.Fatalf("expected no bookmarks file, got %v", err)
+	}
+	if w := do("GET", "/api/bookmarks", resp.Code, ""); w.Code != http.StatusOK {
+		t.Fatalf("expected the new code to be accepted, got %d", w.Code)
+	}
+	if w := do("GET", "/api/bookmarks", "AAAA-AAAA-AAAA", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected an unknown code to be rejected, got %d", w.Code)
+	}
+	if w := do("POST", "/api/bookmarks/import", resp.Code, `{"quran":{"2:255":{"label":"Ayat al-Kursi","url":"/quran/2#255"}}}`); w.Code != http.StatusOK {
+		t.Fatalf("import failed: %d", w.Code)
+	}
+	if err := LoadBookmarks(); err != nil || len(bookmarks["sync:"+resp.Code]["quran"]) != 1 {
+		t.Fatalf("expected the code to be saved with its bookmarks, got %v %v", bookmarks, err)
+	}
+	if _, ok := pendingSyncCodes["sync:"+resp.Code]; ok {
+		t.Fatal("expected the code to no longer be pending")
+	}
+
+	// each client can only create so many codes
+	do("POST", "/api/bookmarks/sync", "", "")
+	if w := do("POST", "/api/bookmarks/sync", "", ""); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the client to be limited, got %d", w.Code)
+	}
+	if len(pendingSyncCodes) != 1 {
+		t.Fatalf("expected one pending code, got %d", len(pendingSyncCodes))
+	}
+}
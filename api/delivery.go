@@ -0,0 +1,385 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SherClockHolmes/webpush-go"
+)
+
+// Delivery records the outcome of sending a notification to a subscription
+type Delivery struct {
+	Endpoint string    `json:"endpoint"`
+	Host     string    `json:"host"`
+	Attempts int       `json:"attempts"`
+	Status   int       `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Latency  int64     `json:"latency_ms"`
+	Time     time.Time `json:"time"`
+}
+
+// PushMessage is a notification payload for a subscription
+type PushMessage struct {
+	Subscription PushSubscription
+	Payload      string
+}
+
+// Dispatcher sends push notifications from a bounded pool of workers,
+// spacing out requests to each push service host and retrying with
+// exponential backoff when the service is rate limiting or failing.
+type Dispatcher struct {
+	// Number of concurrent deliveries
+	Workers int
+	// Attempts per notification including the first
+	MaxAttempts int
+	// Backoff before the first retry, doubled on each attempt
+	Backoff time.Duration
+	// Upper bound on backoff including Retry-After
+	MaxBackoff time.Duration
+	// Minimum time between requests to the same push service host
+	HostInterval time.Duration
+	// HTTP client used to reach the push services
+	Client webpush.HTTPClient
+	// Log of every delivery, may be nil
+	Log *DeliveryLog
+
+	mu    sync.Mutex
+	hosts map[string]time.Time
+}
+
+// DefaultDispatcher is used by SendPushNotification and SendPushToAll
+var DefaultDispatcher = &Dispatcher{
+	Workers:      16,
+	MaxAttempts:  5,
+	Backoff:      time.Second,
+	MaxBackoff:   5 * time.Minute,
+	HostInterval: 10 * time.Millisecond,
+	Log:          NewDeliveryLog(ReminderPath("push_deliveries.jsonl"), 10000),
+}
+
+// Dispatch delivers the messages and returns a delivery for each one
+func (d *Dispatcher) Dispatch(msgs []PushMessage) []*Delivery {
+	workers := d.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	deliveries := make([]*Delivery, len(msgs))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				deliveries[j] = d.Deliver(msgs[j])
+			}
+		}()
+	}
+
+	for i := range msgs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return deliveries
+}
+
+// Deliver sends a single message, retrying as needed
+func (d *Dispatcher) Deliver(msg PushMessage) *Delivery {
+	sub := msg.Subscription
+	host := endpointHost(sub.Endpoint)
+
+	delivery := &Delivery{
+		Endpoint: sub.Endpoint,
+		Host:     host,
+		Time:     time.Now(),
+	}
+
+	maxAttempts := d.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	start := time.Now()
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		d.wait(host)
+
+		delivery.Attempts = attempt
+		status, retryAfter, err := d.send(sub, msg.Payload)
+		delivery.Status = status
+		delivery.Error = ""
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+
+		// Remove subscription if the push service says it's gone
+		if status == http.StatusGone || status == http.StatusNotFound {
+			log.Printf("Removing expired push subscription: %s (status %d)", sub.Endpoint, status)
+			RemovePushSubscription(sub.Endpoint)
+			delivery.Error = fmt.Sprintf("subscription expired (status %d)", status)
+			break
+		}
+
+		retryable := (err != nil && status == 0) || status == http.StatusTooManyRequests || status >= 500
+		if !retryable || attempt == maxAttempts {
+			if err == nil && status >= 400 {
+				delivery.Error = fmt.Sprintf("push service returned status %d", status)
+			}
+			break
+		}
+
+		backoff := d.backoff(attempt, retryAfter)
+		if status == http.StatusTooManyRequests {
+			// hold back every delivery to the host, not just this one
+			d.hold(host, time.Now().Add(backoff))
+		}
+		time.Sleep(backoff)
+	}
+
+	delivery.Latency = time.Since(start).Milliseconds()
+
+	if d.Log != nil {
+		if err := d.Log.Add(delivery); err != nil {
+			log.Printf("Failed to write push delivery log: %v", err)
+		}
+	}
+
+	return delivery
+}
+
+// send makes a single request returning the status and any Retry-After delay
+func (d *Dispatcher) send(sub PushSubscription, payload string) (int, time.Duration, error) {
+	// Safe key extraction — avoids panic on corrupted subscriptions
+	p256dh, _ := sub.Keys["p256dh"].(string)
+	auth, _ := sub.Keys["auth"].(string)
+	if p256dh == "" || auth == "" {
+		return http.StatusBadRequest, 0, fmt.Errorf("invalid subscription keys for %s", sub.Endpoint)
+	}
+
+	subscription := &webpush.Subscription{
+		Endpoint: sub.Endpoint,
+		Keys: webpush.Keys{
+			P256dh: p256dh,
+			Auth:   auth,
+		},
+	}
+
+	resp, err := webpush.SendNotification([]byte(payload), subscription, &webpush.Options{
+		HTTPClient:      d.Client,
+		Subscriber:      VAPIDEmail,
+		VAPIDPublicKey:  VAPIDPublicKey,
+		VAPIDPrivateKey: VAPIDPrivateKey,
+		TTL:             86400, // 24 hours — gives the push service time to deliver
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	// Drain body so the connection can be reused
+	io.Copy(io.Discard, resp.Body)
+
+	return resp.StatusCode, retryAfter(resp.Header.Get("Retry-After")), nil
+}
+
+// backoff returns the delay before the next attempt, honouring Retry-After
+func (d *Dispatcher) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	delay := d.Backoff << (attempt - 1)
+	// jitter so retries from many workers don't arrive together
+	if delay > 0 {
+		delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+	}
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+	if d.MaxBackoff > 0 && delay > d.MaxBackoff {
+		delay = d.MaxBackoff
+	}
+	return delay
+}
+
+// wait blocks until the next request to host is allowed
+func (d *Dispatcher) wait(host string) {
+	d.mu.Lock()
+	if d.hosts == nil {
+		d.hosts = make(map[string]time.Time)
+	}
+	now := time.Now()
+	next := d.hosts[host]
+	if next.Before(now) {
+		next = now
+	}
+	d.hosts[host] = next.Add(d.HostInterval)
+	d.mu.Unlock()
+
+	time.Sleep(time.Until(next))
+}
+
+// hold prevents requests to host until the given time
+func (d *Dispatcher) hold(host string, until time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.hosts == nil {
+		d.hosts = make(map[string]time.Time)
+	}
+	if d.hosts[host].Before(until) {
+		d.hosts[host] = until
+	}
+}
+
+func endpointHost(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// retryAfter parses a Retry-After header given in seconds or as a date
+func retryAfter(v string) time.Duration {
+	if len(v) == 0 {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// DeliveryLog persists deliveries as JSON lines and keeps the most recent
+// in memory for querying. The file is compacted to the entries in memory
+// once it holds twice as many.
+type DeliveryLog struct {
+	path string
+	max  int
+
+	once    sync.Once
+	mu      sync.RWMutex
+	entries []*Delivery
+	// lines in the file
+	lines int
+}
+
+// NewDeliveryLog creates a log at path keeping up to max recent entries
+// in memory. Previously written entries are loaded on first use.
+func NewDeliveryLog(path string, max int) *DeliveryLog {
+	return &DeliveryLog{path: path, max: max}
+}
+
+func (l *DeliveryLog) load() {
+	l.once.Do(func() {
+		f, err := os.Open(l.path)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			l.lines++
+			var d Delivery
+			if err := json.Unmarshal(scanner.Bytes(), &d); err == nil {
+				l.append(&d)
+			}
+		}
+	})
+}
+
+func (l *DeliveryLog) append(d *Delivery) {
+	l.entries = append(l.entries, d)
+	if l.max > 0 && len(l.entries) > l.max {
+		l.entries = l.entries[len(l.entries)-l.max:]
+	}
+}
+
+// Add records a delivery
+func (l *DeliveryLog) Add(d *Delivery) error {
+	l.load()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.append(d)
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	if l.max > 0 && l.lines >= 2*l.max {
+		return l.compact()
+	}
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	l.lines++
+	return nil
+}
+
+// compact rewrites the file with the entries in memory, the caller holds
+// the lock
+func (l *DeliveryLog) compact() error {
+	var buf bytes.Buffer
+	for _, d := range l.entries {
+		b, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(b, '\n'))
+	}
+	if err := WriteFile(l.path, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	l.lines = len(l.entries)
+	return nil
+}
+
+// Query returns the most recent deliveries first, optionally filtered by
+// endpoint and to failures only
+func (l *DeliveryLog) Query(endpoint string, failed bool, limit int) []*Delivery {
+	l.load()
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	result := []*Delivery{}
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		d := l.entries[i]
+		if len(endpoint) > 0 && d.Endpoint != endpoint {
+			continue
+		}
+		if failed && len(d.Error) == 0 {
+			continue
+		}
+		result = append(result, d)
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+	}
+
+	return result
+}
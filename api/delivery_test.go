@@ -0,0 +1,182 @@
+package api
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SherClockHolmes/webpush-go"
+)
+
+func testSubscription(t *testing.T, endpoint string) PushSubscription {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	rand.Read(auth)
+
+	return PushSubscription{
+		Endpoint: endpoint,
+		Keys: map[string]interface{}{
+			"p256dh": base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+			"auth":   base64.RawURLEncoding.EncodeToString(auth),
+		},
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	dir := t.TempDir()
+	pushFile = filepath.Join(dir, "push_subscriptions.json")
+
+	priv, pub, err := webpush.GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	VAPIDPrivateKey, VAPIDPublicKey = priv, pub
+
+	// push service stand-in: rate limits the first request to /limited,
+	// fails /broken and reports /gone as expired
+	var mu sync.Mutex
+	requests := map[string]int{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		n := requests[r.URL.Path]
+		mu.Unlock()
+
+		switch {
+		case r.URL.Path == "/limited" && n == 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case r.URL.Path == "/broken":
+			w.WriteHeader(http.StatusBadGateway)
+		case r.URL.Path == "/gone":
+			w.WriteHeader(http.StatusGone)
+		default:
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer srv.Close()
+
+	var msgs []PushMessage
+	for _, path := range []string{"/ok", "/limited", "/broken", "/gone"} {
+		sub := testSubscription(t, srv.URL+path)
+		AddPushSubscription(sub)
+		msgs = append(msgs, PushMessage{Subscription: sub, Payload: `{"title":"Reminder"}`})
+	}
+
+	d := &Dispatcher{
+		Workers:     4,
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		MaxBackoff:  10 * time.Millisecond,
+		Client:      srv.Client(),
+		Log:         NewDeliveryLog(filepath.Join(dir, "push_deliveries.jsonl"), 100),
+	}
+
+	deliveries := d.Dispatch(msgs)
+
+	expect := []struct {
+		status   int
+		attempts int
+		failed   bool
+	}{
+		{http.StatusCreated, 1, false},
+		{http.StatusCreated, 2, false},
+		{http.StatusBadGateway, 3, true},
+		{http.StatusGone, 1, true},
+	}
+
+	for i, e := range expect {
+		got := deliveries[i]
+		if got.Status != e.status || got.Attempts != e.attempts || (len(got.Error) > 0) != e.failed {
+			t.Fatalf("%s: expected status %d after %d attempts, got %+v", got.Endpoint, e.status, e.attempts, got)
+		}
+	}
+
+	if _, ok := GetPushSubscription(srv.URL + "/gone"); ok {
+		t.Fatal("expected expired subscription to be removed")
+	}
+
+	// the log is persisted and can be reloaded
+	reloaded := NewDeliveryLog(filepath.Join(dir, "push_deliveries.jsonl"), 100)
+	if got := reloaded.Query("", false, 0); len(got) != 4 {
+		t.Fatalf("expected 4 logged deliveries, got %d", len(got))
+	}
+	if got := reloaded.Query("", true, 0); len(got) != 2 {
+		t.Fatalf("expected 2 failed deliveries, got %d", len(got))
+	}
+	if got := reloaded.Query(srv.URL+"/limited", false, 0); len(got) != 1 || got[0].Attempts != 2 {
+		t.Fatalf("expected delivery for /limited, got %+v", got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if got := retryAfter("120"); got != 2*time.Minute {
+		t.Fatalf("expected 2m, got %v", got)
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got := retryAfter(date); got < 59*time.Minute || got > time.Hour {
+		t.Fatalf("expected about 1h, got %v", got)
+	}
+	if got := retryAfter("soon"); got != 0 {
+		t.Fatalf("expected 0, got %v", got)
+	}
+}
+
+func TestDeliveryLogCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "push_deliveries.jsonl")
+	l := NewDeliveryLog(path, 3)
+	for i := 0; i < 10; i++ {
+		if err := l.Add(&Delivery{Endpoint: strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, _ := os.ReadFile(path)
+	if lines := strings.Count(string(b), "\n"); lines > 6 {
+		t.Fatalf("expected the file to be compacted, got %d lines", lines)
+	}
+	got := NewDeliveryLog(path, 3).Query("", false, 0)
+	if len(got) != 3 || got[0].Endpoint != "9" || got[2].Endpoint != "7" {
+		t.Fatalf("expected the last 3 deliveries, got %+v", got)
+	}
+}
+
+func TestSavePushSubscriptionsConcurrent(t *testing.T) {
+	pushFile = filepath.Join(t.TempDir(), "push_subscriptions.json")
+	pushSubscriptions = make(map[string]PushSubscription)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			endpoint := "https://push.example.com/" + strconv.Itoa(i)
+			AddPushSubscription(PushSubscription{Endpoint: endpoint})
+			if i%2 == 0 {
+				RemovePushSubscription(endpoint)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	pushSubscriptions = make(map[string]PushSubscription)
+	if err := LoadPushSubscriptions(); err != nil {
+		t.Fatalf("expected a valid file, got %v", err)
+	}
+	if len(pushSubscriptions) != 10 {
+		t.Fatalf("expected 10 subscriptions, got %d", len(pushSubscriptions))
+	}
+}
@@ -0,0 +1,431 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/netip"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// BaseURL is used for links in emails
+var BaseURL = func() string {
+	if u := os.Getenv("REMINDER_BASE_URL"); len(u) > 0 {
+		return strings.TrimSuffix(u, "/")
+	}
+	return "https://reminder.dev"
+}()
+
+// SMTP server used to send email, configured from SMTP_HOST, SMTP_PORT,
+// SMTP_USERNAME, SMTP_PASSWORD and SMTP_FROM
+var SMTPHost = os.Getenv("SMTP_HOST")
+var SMTPPort = envOr("SMTP_PORT", "587")
+var SMTPUsername = os.Getenv("SMTP_USERNAME")
+var SMTPPassword = os.Getenv("SMTP_PASSWORD")
+var SMTPFrom = envOr("SMTP_FROM", "Reminder <reminder@reminder.dev>")
+
+// EmailResendInterval is how long a confirmation or login link is left
+// pending before another can be requested for the address
+var EmailResendInterval = 5 * time.Minute
+
+// Limits on the emails sent on request, so the endpoints can't be used to
+// flood an inbox
+var (
+	emailAddressLimiter = NewLimiter(5, 24*time.Hour)
+	emailIPLimiter      = NewLimiter(20, time.Hour)
+)
+
+// allowEmail reports whether an email requested by the client may be sent
+// to the address, writing an error if not
+func allowEmail(w http.ResponseWriter, r *http.Request, email string) bool {
+	if !emailIPLimiter.Allow(clientIP(r)) || !emailAddressLimiter.Allow(email) {
+		http.Error(w, "too many emails requested, try again later", http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
+
+// TrustedProxies are the addresses or CIDR ranges of proxies in front of
+// the server whose X-Forwarded-For is believed, from a comma separated
+// REMINDER_TRUSTED_PROXIES e.g 127.0.0.1,10.0.0.0/8
+var TrustedProxies = parseProxies(os.Getenv("REMINDER_TRUSTED_PROXIES"))
+
+func parseProxies(v string) []netip.Prefix {
+	var proxies []netip.Prefix
+	for _, p := range strings.Split(v, ",") {
+		p = strings.TrimSpace(p)
+		if len(p) == 0 {
+			continue
+		}
+		if !strings.Contains(p, "/") {
+			addr, err := netip.ParseAddr(p)
+			if err != nil {
+				log.Printf("Invalid trusted proxy %q: %v", p, err)
+				continue
+			}
+			proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			log.Printf("Invalid trusted proxy %q: %v", p, err)
+			continue
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies
+}
+
+func trustedProxy(addr string) bool {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, p := range TrustedProxies {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client. X-Forwarded-For is only
+// believed from a trusted proxy, taking the last address not one of our
+// proxies, as earlier ones are set by the client.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !trustedProxy(host) {
+		return host
+	}
+	addrs := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(addrs) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(addrs[i])
+		if len(addr) == 0 {
+			continue
+		}
+		if !trustedProxy(addr) {
+			return addr
+		}
+		host = addr
+	}
+	return host
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); len(v) > 0 {
+		return v
+	}
+	return def
+}
+
+// Email is a message with plain text and HTML alternatives
+type Email struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	// Link for one-click unsubscribe, optional
+	Unsubscribe string
+}
+
+// Bytes returns the message in MIME format
+func (e *Email) Bytes() []byte {
+	var buf bytes.Buffer
+
+	boundary := make([]byte, 12)
+	rand.Read(boundary)
+	b := "reminder-" + hex.EncodeToString(boundary)
+
+	fmt.Fprintf(&buf, "From: %s\r\n", SMTPFrom)
+	fmt.Fprintf(&buf, "To: %s\r\n", e.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mimeHeader(e.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	if len(e.Unsubscribe) > 0 {
+		fmt.Fprintf(&buf, "List-Unsubscribe: <%s>\r\n", e.Unsubscribe)
+		fmt.Fprintf(&buf, "List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+	}
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", b)
+
+	for _, part := range []struct{ typ, body string }{
+		{"text/plain", e.Text},
+		{"text/html", e.HTML},
+	} {
+		fmt.Fprintf(&buf, "--%s\r\n", b)
+		fmt.Fprintf(&buf, "Content-Type: %s; charset=UTF-8\r\n", part.typ)
+		fmt.Fprintf(&buf, "Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		w := quotedprintable.NewWriter(&buf)
+		w.Write([]byte(part.body))
+		w.Close()
+		fmt.Fprintf(&buf, "\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", b)
+
+	return buf.Bytes()
+}
+
+func mimeHeader(s string) string {
+	for _, r := range s {
+		if r > 127 {
+			return fmt.Sprintf("=?UTF-8?B?%s?=", base64.StdEncoding.EncodeToString([]byte(s)))
+		}
+	}
+	return s
+}
+
+// SendEmail delivers the email via the configured SMTP server
+func SendEmail(e *Email) error {
+	if len(SMTPHost) == 0 {
+		return fmt.Errorf("SMTP_HOST not set")
+	}
+
+	from := SMTPFrom
+	if addr, err := mail.ParseAddress(SMTPFrom); err == nil {
+		from = addr.Address
+	}
+
+	var auth smtp.Auth
+	if len(SMTPUsername) > 0 {
+		auth = smtp.PlainAuth("", SMTPUsername, SMTPPassword, SMTPHost)
+	}
+
+	return smtp.SendMail(net.JoinHostPort(SMTPHost, SMTPPort), auth, from, []string{e.To}, e.Bytes())
+}
+
+// RenderDigest renders the daily reminder entry as an email to the user
+func RenderDigest(entry map[string]interface{}, user User) *Email {
+	get := func(k string) string {
+		v, _ := entry[k].(string)
+		return v
+	}
+
+	link := func(k string) string {
+		var l string
+		switch links := entry["links"].(type) {
+		case map[string]string:
+			l = links[k]
+		case map[string]interface{}:
+			l, _ = links[k].(string)
+		}
+		if len(l) == 0 {
+			return ""
+		}
+		return BaseURL + l
+	}
+
+	date := get("date")
+	unsubscribe := fmt.Sprintf("%s/api/email/unsubscribe?token=%s", BaseURL, url.QueryEscape(user.Token))
+
+	sections := []struct {
+		title, key string
+	}{
+		{"Verse", "verse"},
+		{"Hadith", "hadith"},
+		{"Name of Allah", "name"},
+		{"Reflection", "message"},
+	}
+
+	var text, body strings.Builder
+
+	fmt.Fprintf(&text, "Reminder for %s (%s)\n\n", date, get("hijri"))
+
+	body.WriteString(`<div style="font-family: sans-serif; max-width: 600px; margin: 0 auto; color: #111;">`)
+	fmt.Fprintf(&body, `<h1 style="font-size: 20px;">Reminder</h1><p style="color: #666;">%s &middot; %s</p>`,
+		html.EscapeString(date), html.EscapeString(get("hijri")))
+
+	for _, s := range sections {
+		content := get(s.key)
+		if len(content) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&text, "%s\n\n%s\n", s.title, content)
+		if l := link(s.key); len(l) > 0 {
+			fmt.Fprintf(&text, "\n%s\n", l)
+		}
+		text.WriteString("\n")
+
+		fmt.Fprintf(&body, `<h2 style="font-size: 16px; margin-top: 24px;">%s</h2>`, s.title)
+		fmt.Fprintf(&body, `<p style="white-space: pre-wrap; line-height: 1.5;">%s</p>`, html.EscapeString(content))
+		if l := link(s.key); len(l) > 0 {
+			fmt.Fprintf(&body, `<p><a href="%s">Read more</a></p>`, html.EscapeString(l))
+		}
+	}
+
+	fmt.Fprintf(&text, "Unsubscribe: %s\n", unsubscribe)
+	fmt.Fprintf(&body, `<p style="margin-top: 32px; font-size: 12px; color: #666;"><a href="%s">Unsubscribe</a></p>`, html.EscapeString(unsubscribe))
+	body.WriteString(`</div>`)
+
+	return &Email{
+		To:          user.Email,
+		Subject:     "Reminder for " + date,
+		Text:        text.String(),
+		HTML:        body.String(),
+		Unsubscribe: unsubscribe,
+	}
+}
+
+// SendDigest emails the daily reminder entry to every confirmed user
+func SendDigest(entry map[string]interface{}) []error {
+	if len(SMTPHost) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, u := range ListUsers() {
+		if !u.Confirmed || !u.Digest {
+			continue
+		}
+		if err := SendEmail(RenderDigest(entry, u)); err != nil {
+			log.Printf("Failed to send digest to %s: %v", u.Email, err)
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// sendConfirmation emails the user a link to confirm their subscription
+func sendConfirmation(u User) error {
+	confirm := fmt.Sprintf("%s/api/email/confirm?token=%s", BaseURL, url.QueryEscape(u.Token))
+
+	return SendEmail(&Email{
+		To:      u.Email,
+		Subject: "Confirm your Reminder subscription",
+		Text:    fmt.Sprintf("Confirm your subscription to the daily reminder by visiting %s\n\nIf you didn't sign up you can ignore this email.\n", confirm),
+		HTML: fmt.Sprintf(`<p>Confirm your subscription to the daily reminder.</p><p><a href="%s">Confirm subscription</a></p><p style="font-size: 12px; color: #666;">If you didn't sign up you can ignore this email.</p>`,
+			html.EscapeString(confirm)),
+	})
+}
+
+func registerEmailRoutes(mux *http.ServeMux) {
+	// Sign up for the daily email, sending a confirmation link. The digest
+	// is only enabled by following the link and the response is the same
+	// whether or not the address has an account.
+	mux.HandleFunc("/api/email/subscribe", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct{ Email string }
+		b, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(b, &req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		addr, err := mail.ParseAddress(req.Email)
+		if err != nil {
+			http.Error(w, "invalid email", http.StatusBadRequest)
+			return
+		}
+
+		email := strings.ToLower(addr.Address)
+
+		if _, ok := GetUser(email); !ok {
+			if err := AddUser(email); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		u, _ := GetUser(email)
+
+		// resend the confirmation until the digest is enabled, unless a
+		// recent one is still pending
+		if !u.Digest && time.Since(u.ConfirmationSent) >= EmailResendInterval {
+			if !allowEmail(w, r, u.Email) {
+				return
+			}
+			if err := sendConfirmation(u); err != nil {
+				log.Printf("Failed to send confirmation to %s: %v", u.Email, err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if err := UpdateUser(u.Email, func(u *User) { u.ConfirmationSent = time.Now() }); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"email": u.Email,
+		})
+	})
+
+	mux.HandleFunc("/api/email/confirm", func(w http.ResponseWriter, r *http.Request) {
+		u, ok := UserByToken(r.URL.Query().Get("token"))
+		if !ok {
+			http.Error(w, "invalid token", http.StatusNotFound)
+			return
+		}
+		if err := ConfirmUser(u.Email); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if err := UpdateUser(u.Email, func(u *User) { u.Digest = true }); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("Subscription confirmed. You'll receive the daily reminder by email."))
+	})
+
+	// GET from the email link shows a form to confirm, as mail scanners
+	// follow links, and POST from it or one-click unsubscribe (RFC 8058)
+	// stops the digest
+	mux.HandleFunc("/api/email/unsubscribe", func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		u, ok := UserByToken(token)
+		if !ok {
+			http.Error(w, "invalid token", http.StatusNotFound)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprintf(w, `<!DOCTYPE html>
+<html><head><meta name="viewport" content="width=device-width, initial-scale=1"><title>Unsubscribe</title></head>
+<body style="font-family: sans-serif; max-width: 480px; margin: 48px auto; padding: 0 16px;">
+<p>Unsubscribe %s from the daily reminder?</p>
+<form method="POST" action="/api/email/unsubscribe?token=%s"><button type="submit">Unsubscribe</button></form>
+</body></html>`, html.EscapeString(u.Email), url.QueryEscape(token))
+			return
+		case http.MethodPost:
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		// accounts are kept, only the digest is stopped
+		var err error
+		if !u.Login.IsZero() {
+			err = UpdateUser(u.Email, func(u *User) { u.Digest = false })
+		} else {
+			err = RemoveUser(u.Email)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("You've been unsubscribed from the daily reminder."))
+	})
+}
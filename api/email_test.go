@@ -0,0 +1,265 @@
+package api
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpServer is an in-process SMTP stand-in which records messages
+type smtpServer struct {
+	net.Listener
+
+	mu       sync.Mutex
+	messages []string
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{Listener: l}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case cmd == "DATA":
+			reply("354 end with .")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data.String())
+			s.mu.Unlock()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *smtpServer) Messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.messages...)
+}
+
+// resetEmail gives the test its own users, login links and rate limits,
+// restoring the limits afterwards so tests can run in any order
+func resetEmail(t *testing.T) {
+	usersFile = filepath.Join(t.TempDir(), "users.json")
+	users.Users = make(map[string]User)
+
+	addressLimiter, ipLimiter := emailAddressLimiter, emailIPLimiter
+	emailAddressLimiter = NewLimiter(5, 24*time.Hour)
+	emailIPLimiter = NewLimiter(20, time.Hour)
+
+	loginMtx.Lock()
+	loginTokens = map[string]loginToken{}
+	loginMtx.Unlock()
+
+	t.Cleanup(func() {
+		emailAddressLimiter, emailIPLimiter = addressLimiter, ipLimiter
+	})
+}
+
+func TestEmailDigest(t *testing.T) {
+	resetEmail(t)
+
+	srv := newSMTPServer(t)
+	defer srv.Close()
+	SMTPHost, SMTPPort, _ = net.SplitHostPort(srv.Addr().String())
+
+	mux := http.NewServeMux()
+	registerEmailRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/api/email/subscribe", strings.NewReader(`{"email":"test@example.com"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("subscribe failed: %d %s", w.Code, w.Body.String())
+	}
+
+	u, ok := GetUser("test@example.com")
+	if !ok || u.Confirmed || u.Digest {
+		t.Fatalf("expected unconfirmed user, got %+v", u)
+	}
+	if msgs := srv.Messages(); len(msgs) != 1 || !strings.Contains(msgs[0], u.Token) {
+		t.Fatalf("expected confirmation email with token, got %v", msgs)
+	}
+
+	entry := map[string]interface{}{
+		"date":  "2024-01-10",
+		"hijri": "28th of Jumada al-thani, 1445",
+		"verse": "Indeed, with hardship comes ease",
+		"name":  "Ar-Rahman - The Most Merciful",
+		"links": map[string]string{"verse": "/quran/94#6"},
+	}
+
+	// unconfirmed users don't receive the digest
+	SendDigest(entry)
+	if n := len(srv.Messages()); n != 1 {
+		t.Fatalf("expected no digest before confirmation, got %d messages", n)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/email/confirm?token="+u.Token, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("confirm failed: %d", w.Code)
+	}
+	if u, _ := GetUser("test@example.com"); !u.Digest {
+		t.Fatalf("expected the digest to be enabled by the link, got %+v", u)
+	}
+
+	if errs := SendDigest(entry); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	msgs := srv.Messages()
+	if len(msgs) != 2 {
+		t.Fatalf("expected digest, got %d messages", len(msgs))
+	}
+	digest := msgs[1]
+	for _, want := range []string{"Subject: Reminder for 2024-01-10", "text/plain", "text/html", "List-Unsubscribe:", "Ar-Rahman"} {
+		if !strings.Contains(digest, want) {
+			t.Fatalf("expected digest to contain %q:\n%s", want, digest)
+		}
+	}
+
+	// following the link only asks to confirm
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/email/unsubscribe?token="+u.Token, nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `method="POST"`) {
+		t.Fatalf("expected a confirmation form, got %d %s", w.Code, w.Body.String())
+	}
+	if u, ok := GetUser("test@example.com"); !ok || !u.Digest {
+		t.Fatalf("expected the user to stay subscribed, got %+v", u)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/api/email/unsubscribe?token="+u.Token, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unsubscribe failed: %d", w.Code)
+	}
+	if _, ok := GetUser("test@example.com"); ok {
+		t.Fatal("expected user to be removed")
+	}
+}
+
+func TestEmailLimits(t *testing.T) {
+	resetEmail(t)
+	emailIPLimiter = NewLimiter(2, time.Hour)
+
+	srv := newSMTPServer(t)
+	defer srv.Close()
+	SMTPHost, SMTPPort, _ = net.SplitHostPort(srv.Addr().String())
+
+	mux := http.NewServeMux()
+	registerEmailRoutes(mux)
+	registerAuthRoutes(mux)
+
+	do := func(path, email string) int {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("POST", path, strings.NewReader(`{"email":"`+email+`"}`)))
+		return w.Code
+	}
+
+	// a pending confirmation or link isn't resent
+	for i := 0; i < 2; i++ {
+		if code := do("/api/email/subscribe", "a@example.com"); code != http.StatusOK {
+			t.Fatalf("subscribe failed: %d", code)
+		}
+		if code := do("/api/login/email", "b@example.com"); code != http.StatusOK {
+			t.Fatalf("login failed: %d", code)
+		}
+	}
+	if n := len(srv.Messages()); n != 2 {
+		t.Fatalf("expected 2 emails, got %d", n)
+	}
+	if _, ok := GetUser("b@example.com"); ok {
+		t.Fatal("expected no account until the login link is followed")
+	}
+
+	// the client has used its allowance
+	if code := do("/api/login/email", "c@example.com"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the client to be limited, got %d", code)
+	}
+	if n := len(srv.Messages()); n != 2 {
+		t.Fatalf("expected no more emails, got %d", n)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies := TrustedProxies
+	t.Cleanup(func() { TrustedProxies = proxies })
+
+	ip := func(remote, fwd string) string {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = remote
+		if len(fwd) > 0 {
+			r.Header.Set("X-Forwarded-For", fwd)
+		}
+		return clientIP(r)
+	}
+
+	// without a proxy the header is set by the client
+	TrustedProxies = nil
+	if got := ip("203.0.113.1:1234", "198.51.100.1"); got != "203.0.113.1" {
+		t.Fatalf("expected the remote address, got %s", got)
+	}
+
+	TrustedProxies = parseProxies("127.0.0.1, 10.0.0.0/8")
+	for _, c := range []struct {
+		remote, fwd, want string
+	}{
+		{"127.0.0.1:1234", "198.51.100.1", "198.51.100.1"},
+		// addresses before the one our proxy appended are the client's
+		{"127.0.0.1:1234", "192.0.2.9, 198.51.100.1", "198.51.100.1"},
+		{"127.0.0.1:1234", "198.51.100.1, 10.1.2.3", "198.51.100.1"},
+		{"127.0.0.1:1234", "", "127.0.0.1"},
+		{"203.0.113.1:1234", "198.51.100.1", "203.0.113.1"},
+	} {
+		if got := ip(c.remote, c.fwd); got != c.want {
+			t.Fatalf("%s %q: expected %s, got %s", c.remote, c.fwd, c.want, got)
+		}
+	}
+}
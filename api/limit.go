@@ -0,0 +1,67 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows a number of events per key, e.g an endpoint or address,
+// within a sliding window
+type Limiter struct {
+	limit  int
+	window time.Duration
+
+	mtx    sync.Mutex
+	events map[string][]time.Time
+	swept  time.Time
+}
+
+// NewLimiter returns a limiter allowing limit events per key in the window
+func NewLimiter(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		limit:  limit,
+		window: window,
+		events: make(map[string][]time.Time),
+	}
+}
+
+// Allow records an event for the key and reports whether it's within the
+// limit. Events over the limit aren't recorded.
+func (l *Limiter) Allow(key string) bool {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	events := l.recent(key, now)
+	if len(events) >= l.limit {
+		l.events[key] = events
+		return false
+	}
+	l.events[key] = append(events, now)
+	return true
+}
+
+// recent returns the events for the key within the window
+func (l *Limiter) recent(key string, now time.Time) []time.Time {
+	events := l.events[key]
+	for len(events) > 0 && now.Sub(events[0]) >= l.window {
+		events = events[1:]
+	}
+	return events
+}
+
+// sweep drops keys with no events in the window so the map doesn't grow
+// with every key ever seen, at most once per window
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.window {
+		return
+	}
+	l.swept = now
+	for key := range l.events {
+		if len(l.recent(key, now)) == 0 {
+			delete(l.events, key)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(2, time.Minute)
+	for i, expected := range []bool{true, true, false, false} {
+		if ok := l.Allow("a"); ok != expected {
+			t.Fatalf("event %d: expected %v, got %v", i, expected, ok)
+		}
+	}
+	if !l.Allow("b") {
+		t.Fatal("expected keys to be limited separately")
+	}
+
+	// events fall out of the window
+	l.events["a"] = []time.Time{time.Now().Add(-2 * time.Minute), time.Now().Add(-time.Second)}
+	if !l.Allow("a") || l.Allow("a") {
+		t.Fatal("expected one event to have left the window")
+	}
+
+	// keys with no recent events are swept
+	l.events["c"] = []time.Time{time.Now().Add(-2 * time.Minute)}
+	l.swept = time.Time{}
+	l.Allow("a")
+	if _, ok := l.events["c"]; ok {
+		t.Fatal("expected the expired key to be swept")
+	}
+}
@@ -0,0 +1,367 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Types of content which can be annotated
+var NoteTypes = []string{"quran", "hadith"}
+
+// keys are chapter:verse for the quran and book:number for hadith
+var noteKey = regexp.MustCompile(`^\d+:\d+$`)
+
+// RenderMarkdown renders a note to HTML. It's set by the server and
+// notes are returned without HTML when nil.
+var RenderMarkdown func(string) string
+
+// Highlight is a range of characters in the text of a verse or hadith
+type Highlight struct {
+	// Offset of the first character
+	Start int `json:"start"`
+	// Offset after the last character
+	End   int    `json:"end"`
+	Color string `json:"color,omitempty"`
+}
+
+// Note is a user's annotation of a verse or hadith
+type Note struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	Key        string      `json:"key"`
+	Text       string      `json:"text"`
+	Tags       []string    `json:"tags,omitempty"`
+	Highlights []Highlight `json:"highlights,omitempty"`
+	Created    time.Time   `json:"created"`
+	Updated    time.Time   `json:"updated"`
+	// Rendered markdown, only set in responses
+	HTML string `json:"html,omitempty"`
+}
+
+// Label describes what the note is attached to e.g Quran 2:255
+func (n *Note) Label() string {
+	if n.Type == "quran" {
+		return "Quran " + n.Key
+	}
+	return "Hadith " + n.Key
+}
+
+// URL links to the annotated verse or hadith
+func (n *Note) URL() string {
+	a, b, _ := strings.Cut(n.Key, ":")
+	return fmt.Sprintf("/%s/%s#%s", n.Type, a, b)
+}
+
+// Validate checks the note and normalises its tags
+func (n *Note) Validate() error {
+	if !contains(NoteTypes, n.Type) {
+		return fmt.Errorf("invalid type %q", n.Type)
+	}
+	if !noteKey.MatchString(n.Key) {
+		return fmt.Errorf("invalid key %q", n.Key)
+	}
+	for _, h := range n.Highlights {
+		if h.Start < 0 || h.End <= h.Start {
+			return fmt.Errorf("invalid highlight %d-%d", h.Start, h.End)
+		}
+	}
+	if len(strings.TrimSpace(n.Text)) == 0 && len(n.Highlights) == 0 && len(n.Tags) == 0 {
+		return fmt.Errorf("note is empty")
+	}
+
+	var tags []string
+	for _, t := range n.Tags {
+		t = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(t), "#"))
+		if len(t) > 0 && !contains(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+	n.Tags = tags
+
+	return nil
+}
+
+func (n *Note) render() *Note {
+	c := *n
+	if RenderMarkdown != nil {
+		c.HTML = RenderMarkdown(n.Text)
+	}
+	return &c
+}
+
+var notesFile = ReminderPath("notes.json")
+var notesMtx sync.RWMutex
+
+// notes by user email then id
+var notes = map[string]map[string]*Note{}
+
+func LoadNotes() error {
+	f, err := os.Open(notesFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	notesMtx.Lock()
+	defer notesMtx.Unlock()
+	return json.NewDecoder(f).Decode(&notes)
+}
+
+// saveNotes writes the notes, the caller must hold the lock
+func saveNotes() error {
+	b, err := json.Marshal(notes)
+	if err != nil {
+		return err
+	}
+	return WriteFile(notesFile, b, 0600)
+}
+
+// ListNotes returns the user's notes most recently updated first,
+// optionally filtered by type, key and tag
+func ListNotes(email, typ, key, tag string) []*Note {
+	notesMtx.RLock()
+	defer notesMtx.RUnlock()
+
+	result := []*Note{}
+	for _, n := range notes[email] {
+		if len(typ) > 0 && n.Type != typ {
+			continue
+		}
+		if len(key) > 0 && n.Key != key {
+			continue
+		}
+		if len(tag) > 0 && !contains(n.Tags, strings.ToLower(tag)) {
+			continue
+		}
+		c := *n
+		result = append(result, &c)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Updated.After(result[j].Updated)
+	})
+
+	return result
+}
+
+// GetNote returns one of the user's notes
+func GetNote(email, id string) (*Note, bool) {
+	notesMtx.RLock()
+	defer notesMtx.RUnlock()
+	n, ok := notes[email][id]
+	if !ok {
+		return nil, false
+	}
+	c := *n
+	return &c, true
+}
+
+// SaveNote creates or replaces one of the user's notes
+func SaveNote(email string, n *Note) error {
+	if err := n.Validate(); err != nil {
+		return err
+	}
+
+	notesMtx.Lock()
+	defer notesMtx.Unlock()
+
+	if notes[email] == nil {
+		notes[email] = map[string]*Note{}
+	}
+
+	now := time.Now()
+	if existing, ok := notes[email][n.ID]; ok && len(n.ID) > 0 {
+		n.Created = existing.Created
+	} else {
+		n.ID = newToken()
+		n.Created = now
+	}
+	n.Updated = now
+	n.HTML = ""
+
+	c := *n
+	notes[email][n.ID] = &c
+	return saveNotes()
+}
+
+// DeleteNote removes one of the user's notes
+func DeleteNote(email, id string) error {
+	notesMtx.Lock()
+	defer notesMtx.Unlock()
+	delete(notes[email], id)
+	return saveNotes()
+}
+
+// NoteTags returns the number of notes for each of the user's tags
+func NoteTags(email string) map[string]int {
+	notesMtx.RLock()
+	defer notesMtx.RUnlock()
+	tags := map[string]int{}
+	for _, n := range notes[email] {
+		for _, t := range n.Tags {
+			tags[t]++
+		}
+	}
+	return tags
+}
+
+// NotesMarkdown exports notes as a markdown document grouped by verse
+// and hadith in key order
+func NotesMarkdown(list []*Note) string {
+	sorted := append([]*Note{}, list...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Type != b.Type {
+			return a.Type > b.Type // quran before hadith
+		}
+		if a.Key != b.Key {
+			return keyLess(a.Key, b.Key)
+		}
+		return a.Created.Before(b.Created)
+	})
+
+	var data strings.Builder
+	data.WriteString("# Notes\n")
+
+	var last string
+	for _, n := range sorted {
+		if label := n.Label(); label != last {
+			fmt.Fprintf(&data, "\n## %s\n", label)
+			last = label
+		}
+		data.WriteString("\n")
+		if len(n.Tags) > 0 {
+			fmt.Fprintf(&data, "Tags: #%s\n\n", strings.Join(n.Tags, " #"))
+		}
+		if len(n.Highlights) > 0 {
+			var ranges []string
+			for _, h := range n.Highlights {
+				ranges = append(ranges, fmt.Sprintf("%d-%d", h.Start, h.End))
+			}
+			fmt.Fprintf(&data, "Highlights: %s\n\n", strings.Join(ranges, ", "))
+		}
+		if text := strings.TrimSpace(n.Text); len(text) > 0 {
+			data.WriteString(text + "\n")
+		}
+		fmt.Fprintf(&data, "\n_Updated %s_\n", n.Updated.Format("2 January 2006"))
+	}
+
+	return data.String()
+}
+
+// keyLess orders keys such as 2:255 numerically
+func keyLess(a, b string) bool {
+	var a1, a2, b1, b2 int
+	fmt.Sscanf(a, "%d:%d", &a1, &a2)
+	fmt.Sscanf(b, "%d:%d", &b1, &b2)
+	if a1 != b1 {
+		return a1 < b1
+	}
+	return a2 < b2
+}
+
+func writeNotes(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func registerNoteRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/api/notes", RequireUser(func(w http.ResponseWriter, r *http.Request) {
+		u, _ := UserFromContext(r.Context())
+
+		switch r.Method {
+		case http.MethodGet:
+			q := r.URL.Query()
+			list := ListNotes(u.Email, q.Get("type"), q.Get("key"), q.Get("tag"))
+			for i, n := range list {
+				list[i] = n.render()
+			}
+			writeNotes(w, list)
+		case http.MethodPost:
+			var n Note
+			b, _ := io.ReadAll(r.Body)
+			if err := json.Unmarshal(b, &n); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			n.ID = ""
+			if err := SaveNote(u.Email, &n); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeNotes(w, n.render())
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/api/notes/{id}", RequireUser(func(w http.ResponseWriter, r *http.Request) {
+		u, _ := UserFromContext(r.Context())
+
+		n, ok := GetNote(u.Email, r.PathValue("id"))
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			writeNotes(w, n.render())
+		case http.MethodPut:
+			var update Note
+			b, _ := io.ReadAll(r.Body)
+			if err := json.Unmarshal(b, &update); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			// the annotated verse or hadith can't change
+			update.ID, update.Type, update.Key = n.ID, n.Type, n.Key
+			if err := SaveNote(u.Email, &update); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeNotes(w, update.render())
+		case http.MethodDelete:
+			if err := DeleteNote(u.Email, n.ID); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+
+	mux.HandleFunc("/api/notes/tags", RequireUser(func(w http.ResponseWriter, r *http.Request) {
+		u, _ := UserFromContext(r.Context())
+		writeNotes(w, NoteTags(u.Email))
+	}))
+
+	mux.HandleFunc("/api/notes/export", RequireUser(func(w http.ResponseWriter, r *http.Request) {
+		u, _ := UserFromContext(r.Context())
+		q := r.URL.Query()
+		list := ListNotes(u.Email, q.Get("type"), "", q.Get("tag"))
+
+		switch q.Get("format") {
+		case "", "md", "markdown":
+			w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+			w.Header().Set("Content-Disposition", `attachment; filename="notes.md"`)
+			w.Write([]byte(NotesMarkdown(list)))
+		case "json":
+			w.Header().Set("Content-Disposition", `attachment; filename="notes.json"`)
+			writeNotes(w, list)
+		default:
+			http.Error(w, "format must be md or json", http.StatusBadRequest)
+		}
+	}))
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotes(t *testing.T) {
+	dir := t.TempDir()
+	usersFile = filepath.Join(dir, "users.json")
+	notesFile = filepath.Join(dir, "notes.json")
+	users.Users = make(map[string]User)
+	notes = map[string]map[string]*Note{}
+
+	AddUser("test@example.com")
+	cookie := &http.Cookie{Name: SessionCookie, Value: NewSession("test@example.com", time.Now().Add(time.Hour))}
+
+	mux := http.NewServeMux()
+	registerNoteRoutes(mux)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := do("POST", "/api/notes", `{"type":"quran","key":"2:255x","text":"bad key"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid key to be rejected, got %d", w.Code)
+	}
+
+	w := do("POST", "/api/notes", `{"type":"quran","key":"2:255","text":"The **throne** verse","tags":["#Tawhid"," protection"],"highlights":[{"start":0,"end":10}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("create failed: %d %s", w.Code, w.Body.String())
+	}
+	var created Note
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if created.ID == "" || strings.Join(created.Tags, ",") != "tawhid,protection" {
+		t.Fatalf("unexpected note %+v", created)
+	}
+
+	do("POST", "/api/notes", `{"type":"hadith","key":"1:1","text":"Actions are by intentions","tags":["intention"]}`)
+
+	w = do("PUT", "/api/notes/"+created.ID, `{"text":"Ayat al-Kursi","tags":["tawhid"]}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"key":"2:255"`) {
+		t.Fatalf("update failed: %d %s", w.Code, w.Body.String())
+	}
+
+	var list []*Note
+	json.Unmarshal(do("GET", "/api/notes?tag=tawhid", "").Body.Bytes(), &list)
+	if len(list) != 1 || list[0].Text != "Ayat al-Kursi" {
+		t.Fatalf("expected updated note by tag, got %+v", list)
+	}
+
+	var tags map[string]int
+	json.Unmarshal(do("GET", "/api/notes/tags", "").Body.Bytes(), &tags)
+	if tags["tawhid"] != 1 || tags["intention"] != 1 || len(tags) != 2 {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+
+	md := do("GET", "/api/notes/export?format=md", "").Body.String()
+	if !strings.Contains(md, "## Quran 2:255") || !strings.Contains(md, "## Hadith 1:1") || strings.Index(md, "Quran") > strings.Index(md, "Hadith") {
+		t.Fatalf("unexpected markdown export:\n%s", md)
+	}
+
+	if w := do("DELETE", "/api/notes/"+created.ID, ""); w.Code != http.StatusOK {
+		t.Fatalf("delete failed: %d", w.Code)
+	}
+	if w := do("GET", "/api/notes/"+created.ID, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected deleted note to be gone, got %d", w.Code)
+	}
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Content which can be included in a push notification
+var PushContent = []string{"verse", "hadith", "name", "message"}
+
+// Frequency of push notifications
+const (
+	FrequencyHourly = "hourly"
+	FrequencyDaily  = "daily"
+	FrequencyWeekly = "weekly"
+)
+
+// Deliveries later than this after their scheduled time are skipped
+var PushMaxLateness = time.Hour
+
+// PushPreferences control when and what a subscriber is sent
+type PushPreferences struct {
+	// IANA time zone e.g Europe/London
+	TimeZone string `json:"timezone,omitempty"`
+	// Local delivery time as HH:MM. Only the minute is used for hourly.
+	Time string `json:"time,omitempty"`
+	// Any of verse, hadith, name and message
+	Content []string `json:"content,omitempty"`
+	// One of daily, hourly or weekly
+	Frequency string `json:"frequency,omitempty"`
+	// Day of the week for weekly delivery e.g friday
+	Weekday string `json:"weekday,omitempty"`
+}
+
+// DefaultPushPreferences matches the original behaviour of sending
+// the daily verse at midnight UTC
+func DefaultPushPreferences() PushPreferences {
+	return PushPreferences{
+		TimeZone:  "UTC",
+		Time:      "00:00",
+		Content:   []string{"verse"},
+		Frequency: FrequencyDaily,
+		Weekday:   "friday",
+	}
+}
+
+// WithDefaults fills in any unset preferences with the defaults
+func (p PushPreferences) WithDefaults() PushPreferences {
+	d := DefaultPushPreferences()
+	if len(p.TimeZone) == 0 {
+		p.TimeZone = d.TimeZone
+	}
+	if len(p.Time) == 0 {
+		p.Time = d.Time
+	}
+	if len(p.Content) == 0 {
+		p.Content = d.Content
+	}
+	if len(p.Frequency) == 0 {
+		p.Frequency = d.Frequency
+	}
+	if len(p.Weekday) == 0 {
+		p.Weekday = d.Weekday
+	}
+	p.Frequency = strings.ToLower(p.Frequency)
+	p.Weekday = strings.ToLower(p.Weekday)
+	return p
+}
+
+// Validate checks the preferences after defaults are applied
+func (p PushPreferences) Validate() error {
+	p = p.WithDefaults()
+
+	if _, err := time.LoadLocation(p.TimeZone); err != nil {
+		return fmt.Errorf("invalid timezone %q", p.TimeZone)
+	}
+	if _, _, err := p.clock(); err != nil {
+		return err
+	}
+	for _, c := range p.Content {
+		if !contains(PushContent, c) {
+			return fmt.Errorf("invalid content %q", c)
+		}
+	}
+	switch p.Frequency {
+	case FrequencyHourly, FrequencyDaily, FrequencyWeekly:
+	default:
+		return fmt.Errorf("invalid frequency %q", p.Frequency)
+	}
+	if _, err := parseWeekday(p.Weekday); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Includes reports whether the content type should be sent
+func (p PushPreferences) Includes(content string) bool {
+	return contains(p.WithDefaults().Content, content)
+}
+
+// locations caches time zones by name as loading one reads the zoneinfo
+var locations sync.Map
+
+// location returns the time zone of the preferences, or UTC if invalid
+func (p PushPreferences) location() *time.Location {
+	if loc, ok := locations.Load(p.TimeZone); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(p.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	locations.Store(p.TimeZone, loc)
+	return loc
+}
+
+// Next returns the first scheduled delivery after the given time
+func (p PushPreferences) Next(after time.Time) time.Time {
+	p = p.WithDefaults()
+
+	loc := p.location()
+	hour, minute, _ := p.clock()
+	weekday, _ := parseWeekday(p.Weekday)
+
+	t := after.In(loc)
+
+	if p.Frequency == FrequencyHourly {
+		next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), minute, 0, 0, loc)
+		if !next.After(after) {
+			next = next.Add(time.Hour)
+		}
+		return next
+	}
+
+	next := time.Date(t.Year(), t.Month(), t.Day(), hour, minute, 0, 0, loc)
+	for !next.After(after) || (p.Frequency == FrequencyWeekly && next.Weekday() != weekday) {
+		next = time.Date(next.Year(), next.Month(), next.Day()+1, hour, minute, 0, 0, loc)
+	}
+	return next
+}
+
+func (p PushPreferences) clock() (int, int, error) {
+	t, err := time.Parse("15:04", p.Time)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time %q, expected HH:MM", p.Time)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+func parseWeekday(day string) (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(d.String(), day) {
+			return d, nil
+		}
+	}
+	return time.Sunday, fmt.Errorf("invalid weekday %q", day)
+}
+
+func contains(list []string, v string) bool {
+	for _, l := range list {
+		if l == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPushPreferencesValidate(t *testing.T) {
+	valid := []PushPreferences{
+		{},
+		{TimeZone: "Europe/London", Time: "07:30", Content: []string{"verse", "name"}, Frequency: "daily"},
+		{TimeZone: "Asia/Karachi", Frequency: "weekly", Weekday: "Friday"},
+		{Frequency: "hourly", Time: "00:15"},
+	}
+	for _, p := range valid {
+		if err := p.Validate(); err != nil {
+			t.Fatalf("expected %+v to be valid, got %v", p, err)
+		}
+	}
+
+	invalid := []PushPreferences{
+		{TimeZone: "Mars/Olympus"},
+		{Time: "25:00"},
+		{Content: []string{"tafsir"}},
+		{Frequency: "monthly"},
+		{Frequency: "weekly", Weekday: "someday"},
+	}
+	for _, p := range invalid {
+		if err := p.Validate(); err == nil {
+			t.Fatalf("expected %+v to be invalid", p)
+		}
+	}
+}
+
+func TestPushPreferencesNext(t *testing.T) {
+	london, _ := time.LoadLocation("Europe/London")
+	// Wednesday 10th January 2024 12:00 UTC
+	after := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		prefs PushPreferences
+		want  time.Time
+	}{
+		{PushPreferences{}, time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC)},
+		{PushPreferences{Time: "13:00"}, time.Date(2024, 1, 10, 13, 0, 0, 0, time.UTC)},
+		{PushPreferences{TimeZone: "Asia/Karachi", Time: "08:00"}, time.Date(2024, 1, 11, 3, 0, 0, 0, time.UTC)},
+		{PushPreferences{Frequency: "hourly", Time: "00:15"}, time.Date(2024, 1, 10, 12, 15, 0, 0, time.UTC)},
+		{PushPreferences{Frequency: "weekly", Weekday: "friday", Time: "09:00"}, time.Date(2024, 1, 12, 9, 0, 0, 0, time.UTC)},
+		// local time in London
+		{PushPreferences{TimeZone: "Europe/London", Time: "08:00"}, time.Date(2024, 1, 11, 8, 0, 0, 0, london)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.prefs.Next(after); !got.Equal(tt.want) {
+			t.Fatalf("%+v: expected %v, got %v", tt.prefs, tt.want, got)
+		}
+	}
+
+	// British summer time moves the delivery an hour earlier in UTC
+	summer := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	prefs := PushPreferences{TimeZone: "Europe/London", Time: "08:00"}
+	if got := prefs.Next(summer); !got.Equal(time.Date(2024, 6, 11, 7, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected 07:00 UTC in summer, got %v", got.UTC())
+	}
+}
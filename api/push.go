@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -17,8 +18,21 @@ import (
 )
 
 type PushSubscription struct {
-	Endpoint string                 `json:"endpoint"`
-	Keys     map[string]interface{} `json:"keys"`
+	Endpoint    string                 `json:"endpoint"`
+	Keys        map[string]interface{} `json:"keys"`
+	Preferences PushPreferences        `json:"preferences"`
+	// Topics subscribed to, the daily reminder if empty
+	Topics []string `json:"topics,omitempty"`
+	// Time up to which scheduled notifications have been delivered
+	Delivered time.Time `json:"delivered,omitempty"`
+	// Local date each daily topic such as jumuah was last sent
+	TopicDates map[string]string `json:"topic_dates,omitempty"`
+	// Location for notifications anchored to prayer times
+	Location *PushLocation `json:"location,omitempty"`
+	// Notifications relative to prayer times e.g 10 minutes after fajr
+	Anchors []PrayerAnchor `json:"anchors,omitempty"`
+	// Time up to which anchored notifications have been delivered
+	AnchorDelivered time.Time `json:"anchor_delivered,omitempty"`
 }
 
 var pushFile = ReminderPath("push_subscriptions.json")
@@ -30,6 +44,11 @@ var VAPIDPublicKey string
 var VAPIDPrivateKey string
 var VAPIDEmail = "mailto:admin@reminder.local"
 
+// PushPayload builds the next scheduled notification for a subscription.
+// It's set by the server so previews match what the scheduler sends and
+// returns nil when the content isn't ready yet.
+var PushPayload func(sub PushSubscription) []byte
+
 func LoadOrGenerateVAPIDKeys() error {
 	dir := ReminderPath("keys")
 	privPath := filepath.Join(dir, "vapid_private.pem")
@@ -99,27 +118,142 @@ func LoadPushSubscriptions() error {
 		return err
 	}
 	defer f.Close()
-	return json.NewDecoder(f).Decode(&pushSubscriptions)
+	if err := json.NewDecoder(f).Decode(&pushSubscriptions); err != nil {
+		return err
+	}
+	// subscriptions from before preferences existed start from now
+	now := time.Now()
+	for endpoint, sub := range pushSubscriptions {
+		if sub.Delivered.IsZero() {
+			sub.Delivered = now
+		}
+		if sub.AnchorDelivered.IsZero() {
+			sub.AnchorDelivered = now
+		}
+		pushSubscriptions[endpoint] = sub
+	}
+	return nil
 }
 
+// pushTestLimiter limits the test notifications sent to each subscription
+var pushTestLimiter = NewLimiter(3, 10*time.Minute)
+
+// pushSaveMtx serialises writes of the subscriptions file
+var pushSaveMtx sync.Mutex
+
 func SavePushSubscriptions() error {
+	// held across the write so a slower save can't replace a newer one
+	pushSaveMtx.Lock()
+	defer pushSaveMtx.Unlock()
+
 	pushMtx.RLock()
-	defer pushMtx.RUnlock()
-	f, err := os.Create(pushFile)
+	b, err := json.Marshal(pushSubscriptions)
+	pushMtx.RUnlock()
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	return json.NewEncoder(f).Encode(pushSubscriptions)
+	return WriteFile(pushFile, b, 0600)
 }
 
 func AddPushSubscription(sub PushSubscription) error {
 	pushMtx.Lock()
+	if existing, ok := pushSubscriptions[sub.Endpoint]; ok {
+		// resubscribing keeps existing preferences unless new ones are given
+		if isZeroPreferences(sub.Preferences) {
+			sub.Preferences = existing.Preferences
+		}
+		if len(sub.Topics) == 0 {
+			sub.Topics = existing.Topics
+		}
+		if sub.Location == nil && len(sub.Anchors) == 0 {
+			sub.Location = existing.Location
+			sub.Anchors = existing.Anchors
+		}
+		sub.Delivered = existing.Delivered
+		sub.TopicDates = existing.TopicDates
+		sub.AnchorDelivered = existing.AnchorDelivered
+	}
+	if sub.Delivered.IsZero() {
+		sub.Delivered = time.Now()
+	}
+	if sub.AnchorDelivered.IsZero() {
+		sub.AnchorDelivered = time.Now()
+	}
 	pushSubscriptions[sub.Endpoint] = sub
 	pushMtx.Unlock()
 	return SavePushSubscriptions()
 }
 
+func isZeroPreferences(p PushPreferences) bool {
+	return len(p.TimeZone) == 0 && len(p.Time) == 0 && len(p.Content) == 0 &&
+		len(p.Frequency) == 0 && len(p.Weekday) == 0
+}
+
+// GetPushSubscription returns the subscription for an endpoint
+func GetPushSubscription(endpoint string) (PushSubscription, bool) {
+	pushMtx.RLock()
+	defer pushMtx.RUnlock()
+	sub, ok := pushSubscriptions[endpoint]
+	return sub, ok
+}
+
+// UpdatePushPreferences replaces the preferences of a subscription
+func UpdatePushPreferences(endpoint string, prefs PushPreferences) error {
+	pushMtx.Lock()
+	sub, ok := pushSubscriptions[endpoint]
+	if !ok {
+		pushMtx.Unlock()
+		return fmt.Errorf("subscription not found")
+	}
+	sub.Preferences = prefs
+	pushSubscriptions[endpoint] = sub
+	pushMtx.Unlock()
+	return SavePushSubscriptions()
+}
+
+// SetPushDelivered records that the subscriptions have been delivered
+// everything scheduled up to t
+func SetPushDelivered(endpoints []string, t time.Time) error {
+	if len(endpoints) == 0 {
+		return nil
+	}
+	pushMtx.Lock()
+	for _, endpoint := range endpoints {
+		if sub, ok := pushSubscriptions[endpoint]; ok {
+			sub.Delivered = t
+			pushSubscriptions[endpoint] = sub
+		}
+	}
+	pushMtx.Unlock()
+	return SavePushSubscriptions()
+}
+
+// DuePushSubscriptions returns the subscriptions with a delivery scheduled
+// at or before now. Deliveries missed by more than PushMaxLateness, such as
+// while the server was down, are skipped rather than sent late.
+func DuePushSubscriptions(now time.Time) []PushSubscription {
+	var due []PushSubscription
+	var skipped []string
+
+	for _, sub := range TopicPushSubscriptions(TopicDaily) {
+		next := sub.Preferences.Next(sub.Delivered)
+		if next.After(now) {
+			continue
+		}
+		if now.Sub(next) > PushMaxLateness {
+			skipped = append(skipped, sub.Endpoint)
+			continue
+		}
+		due = append(due, sub)
+	}
+
+	if err := SetPushDelivered(skipped, now); err != nil {
+		log.Printf("Failed to save push subscriptions: %v", err)
+	}
+
+	return due
+}
+
 func RemovePushSubscription(endpoint string) error {
 	pushMtx.Lock()
 	delete(pushSubscriptions, endpoint)
@@ -138,6 +272,11 @@ func ListPushSubscriptions() []PushSubscription {
 }
 
 func RegisterRoutes(mux *http.ServeMux) {
+	registerEmailRoutes(mux)
+	registerAuthRoutes(mux)
+	registerBookmarkRoutes(mux)
+	registerNoteRoutes(mux)
+
 	mux.HandleFunc("/api/push/subscribe", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			w.WriteHeader(http.StatusMethodNotAllowed)
@@ -149,6 +288,22 @@ func RegisterRoutes(mux *http.ServeMux) {
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
+		if err := sub.Preferences.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := ValidateTopics(sub.Topics); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := ValidateAnchors(sub.Location, sub.Anchors); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// the delivery state is managed by the server
+		sub.Delivered = time.Time{}
+		sub.TopicDates = nil
+		sub.AnchorDelivered = time.Time{}
 		if err := AddPushSubscription(sub); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
@@ -174,6 +329,255 @@ func RegisterRoutes(mux *http.ServeMux) {
 		w.WriteHeader(http.StatusOK)
 	})
 
+	mux.HandleFunc("/api/push/preferences", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			sub, ok := GetPushSubscription(r.URL.Query().Get("endpoint"))
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(sub.Preferences.WithDefaults())
+		case http.MethodPost:
+			var req struct {
+				Endpoint    string          `json:"endpoint"`
+				Preferences PushPreferences `json:"preferences"`
+			}
+			b, _ := io.ReadAll(r.Body)
+			if err := json.Unmarshal(b, &req); err != nil || req.Endpoint == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if err := req.Preferences.Validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if _, ok := GetPushSubscription(req.Endpoint); !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if err := UpdatePushPreferences(req.Endpoint, req.Preferences); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(req.Preferences.WithDefaults())
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	// List the topics or get and set those of a subscription
+	mux.HandleFunc("/api/push/topics", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			topics := PushTopics
+			if endpoint := r.URL.Query().Get("endpoint"); len(endpoint) > 0 {
+				sub, ok := GetPushSubscription(endpoint)
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				topics = sub.SubscribedTopics()
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(topics)
+		case http.MethodPost:
+			var req struct {
+				Endpoint string   `json:"endpoint"`
+				Topics   []string `json:"topics"`
+			}
+			b, _ := io.ReadAll(r.Body)
+			if err := json.Unmarshal(b, &req); err != nil || req.Endpoint == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if err := ValidateTopics(req.Topics); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if _, ok := GetPushSubscription(req.Endpoint); !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if err := UpdatePushTopics(req.Endpoint, req.Topics); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(req.Topics)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	// Get or set the location and prayer anchored notifications of a subscription
+	mux.HandleFunc("/api/push/anchors", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			sub, ok := GetPushSubscription(r.URL.Query().Get("endpoint"))
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			resp := map[string]interface{}{
+				"location": sub.Location,
+				"anchors":  sub.Anchors,
+			}
+			if next, anchors, ok := sub.NextAnchor(time.Now()); ok {
+				resp["next"] = map[string]interface{}{"time": next, "anchors": anchors}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(resp)
+		case http.MethodPost:
+			var req struct {
+				Endpoint string         `json:"endpoint"`
+				Location *PushLocation  `json:"location"`
+				Anchors  []PrayerAnchor `json:"anchors"`
+			}
+			b, _ := io.ReadAll(r.Body)
+			if err := json.Unmarshal(b, &req); err != nil || req.Endpoint == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if err := ValidateAnchors(req.Location, req.Anchors); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if _, ok := GetPushSubscription(req.Endpoint); !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if err := UpdatePushAnchors(req.Endpoint, req.Location, req.Anchors); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"location": req.Location,
+				"anchors":  req.Anchors,
+			})
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	// Broadcast a custom message to a topic
+	mux.HandleFunc("/api/push/send", RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			Topic string `json:"topic"`
+			Title string `json:"title"`
+			Body  string `json:"body"`
+			URL   string `json:"url"`
+		}
+		b, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(b, &req); err != nil || req.Body == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.Topic == "" {
+			req.Topic = TopicDaily
+		}
+		if err := ValidateTopics([]string{req.Topic}); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Title == "" {
+			req.Title = "Reminder"
+		}
+		if req.URL == "" {
+			req.URL = "/home"
+		}
+
+		deliveries := SendPushToTopic(req.Topic, NewPushPayload(req.Title, req.Body, req.URL))
+		var failed int
+		for _, d := range deliveries {
+			if len(d.Error) > 0 {
+				failed++
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"topic":  req.Topic,
+			"sent":   len(deliveries) - failed,
+			"failed": failed,
+		})
+	}))
+
+	// Send a notification straight away so subscribers can check their setup
+	mux.HandleFunc("/api/push/test", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct{ Endpoint string }
+		b, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(b, &req); err != nil || req.Endpoint == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		sub, ok := GetPushSubscription(req.Endpoint)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if !pushTestLimiter.Allow(sub.Endpoint) {
+			http.Error(w, "too many test notifications, try again later", http.StatusTooManyRequests)
+			return
+		}
+
+		payload := NewPushPayload("Reminder", "Notifications are working. Your next reminder will arrive as scheduled.", "/home")
+		d := DefaultDispatcher.Deliver(PushMessage{Subscription: sub, Payload: payload})
+
+		w.Header().Set("Content-Type", "application/json")
+		if len(d.Error) > 0 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		json.NewEncoder(w).Encode(d)
+	})
+
+	// Preview the next scheduled notification for a subscription
+	mux.HandleFunc("/api/push/preview", func(w http.ResponseWriter, r *http.Request) {
+		sub, ok := GetPushSubscription(r.URL.Query().Get("endpoint"))
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var payload json.RawMessage
+		if PushPayload != nil {
+			payload = PushPayload(sub)
+		}
+		if payload == nil {
+			payload = json.RawMessage("null")
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"next":    sub.Preferences.Next(sub.Delivered),
+			"topics":  sub.SubscribedTopics(),
+			"payload": payload,
+		})
+	})
+
+	// Delivery log for diagnosing failed notifications
+	mux.HandleFunc("/api/admin/push/deliveries", RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		if limit <= 0 {
+			limit = 100
+		}
+		deliveries := DefaultDispatcher.Log.Query(q.Get("endpoint"), q.Get("failed") == "true", limit)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(deliveries)
+	}))
+
 	// Expose VAPID public key to frontend
 	mux.HandleFunc("/api/push/key", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain")
@@ -182,61 +586,29 @@ func RegisterRoutes(mux *http.ServeMux) {
 	})
 }
 
+// SendPushNotification sends a single notification via the default dispatcher
 func SendPushNotification(sub PushSubscription, payload string) error {
-	// Safe key extraction — avoids panic on corrupted subscriptions
-	p256dh, _ := sub.Keys["p256dh"].(string)
-	auth, _ := sub.Keys["auth"].(string)
-	if p256dh == "" || auth == "" {
-		return fmt.Errorf("invalid subscription keys for %s", sub.Endpoint)
-	}
-
-	subscription := &webpush.Subscription{
-		Endpoint: sub.Endpoint,
-		Keys: webpush.Keys{
-			P256dh: p256dh,
-			Auth:   auth,
-		},
-	}
-
-	resp, err := webpush.SendNotification([]byte(payload), subscription, &webpush.Options{
-		Subscriber:      VAPIDEmail,
-		VAPIDPublicKey:  VAPIDPublicKey,
-		VAPIDPrivateKey: VAPIDPrivateKey,
-		TTL:             86400, // 24 hours — gives the push service time to deliver
-	})
-	if err != nil {
-		return err
+	d := DefaultDispatcher.Deliver(PushMessage{Subscription: sub, Payload: payload})
+	if len(d.Error) > 0 {
+		return fmt.Errorf("%s", d.Error)
 	}
-	defer resp.Body.Close()
-	// Drain body so the connection can be reused
-	io.Copy(io.Discard, resp.Body)
-
-	// Remove subscription if the push service says it's gone
-	if resp.StatusCode == http.StatusGone || resp.StatusCode == http.StatusNotFound {
-		log.Printf("Removing expired push subscription: %s (status %d)", sub.Endpoint, resp.StatusCode)
-		RemovePushSubscription(sub.Endpoint)
-		return fmt.Errorf("subscription expired (status %d)", resp.StatusCode)
-	}
-
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("push service returned status %d for %s", resp.StatusCode, sub.Endpoint)
-	}
-
 	return nil
 }
 
+// SendPushToAll sends the payload to every subscription concurrently
 func SendPushToAll(payload string) []string {
-	subs := ListPushSubscriptions()
+	var msgs []PushMessage
+	for _, sub := range ListPushSubscriptions() {
+		msgs = append(msgs, PushMessage{Subscription: sub, Payload: payload})
+	}
+
 	errors := []string{}
-	for _, sub := range subs {
-		err := SendPushNotification(sub, payload)
-		if err != nil {
-			errMsg := fmt.Sprintf("Failed to send push to %s: %v", sub.Endpoint, err)
+	for _, d := range DefaultDispatcher.Dispatch(msgs) {
+		if len(d.Error) > 0 {
+			errMsg := fmt.Sprintf("Failed to send push to %s: %s", d.Endpoint, d.Error)
 			log.Println(errMsg)
 			errors = append(errors, errMsg)
 		}
-		// avoid rate limits
-		time.Sleep(100 * time.Millisecond)
 	}
 	return errors
 }
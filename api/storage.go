@@ -14,3 +14,26 @@ var ReminderDir = func() string {
 func ReminderPath(filename string) string {
 	return filepath.Join(ReminderDir, filename)
 }
+
+// WriteFile writes the data to a temporary file and renames it over the
+// path so readers and crashes never see a partial file
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
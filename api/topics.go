@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Topics a push subscription can opt into
+const (
+	// The scheduled reminder delivered per subscriber preferences
+	TopicDaily = "daily"
+	// Friday reminder to read Surah Al-Kahf, at the local delivery time
+	TopicJumuah = "jumuah"
+	// Daily messages during the month of Ramadan, at the local delivery time
+	TopicRamadan = "ramadan"
+	// Every refresh of the hourly reminder
+	TopicHourly = "hourly"
+)
+
+var PushTopics = []string{TopicDaily, TopicJumuah, TopicRamadan, TopicHourly}
+
+// ValidateTopics checks the topics are known
+func ValidateTopics(topics []string) error {
+	for _, t := range topics {
+		if !contains(PushTopics, t) {
+			return fmt.Errorf("invalid topic %q", t)
+		}
+	}
+	return nil
+}
+
+// SubscribedTo reports whether the subscription receives the topic.
+// Subscriptions without topics only receive the daily reminder.
+func (s PushSubscription) SubscribedTo(topic string) bool {
+	if len(s.Topics) == 0 {
+		return topic == TopicDaily
+	}
+	return contains(s.Topics, topic)
+}
+
+// SubscribedTopics returns the topics the subscription receives
+func (s PushSubscription) SubscribedTopics() []string {
+	if len(s.Topics) == 0 {
+		return []string{TopicDaily}
+	}
+	return s.Topics
+}
+
+// TopicPushSubscriptions returns the subscriptions for a topic
+func TopicPushSubscriptions(topic string) []PushSubscription {
+	var subs []PushSubscription
+	for _, sub := range ListPushSubscriptions() {
+		if sub.SubscribedTo(topic) {
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
+
+// UpdatePushTopics replaces the topics of a subscription
+func UpdatePushTopics(endpoint string, topics []string) error {
+	pushMtx.Lock()
+	sub, ok := pushSubscriptions[endpoint]
+	if !ok {
+		pushMtx.Unlock()
+		return fmt.Errorf("subscription not found")
+	}
+	sub.Topics = topics
+	pushSubscriptions[endpoint] = sub
+	pushMtx.Unlock()
+	return SavePushSubscriptions()
+}
+
+// NewPushPayload returns the notification JSON understood by the service worker
+func NewPushPayload(title, body, url string) string {
+	b, _ := json.Marshal(map[string]interface{}{
+		"title": title,
+		"body":  body,
+		"data": map[string]interface{}{
+			"url": url,
+		},
+	})
+	return string(b)
+}
+
+// TopicPush is a daily topic message due for a subscription
+type TopicPush struct {
+	Subscription PushSubscription
+	// Local date of the subscriber the message is for
+	Date time.Time
+}
+
+// DueTopicPushes returns the subscriptions to a daily topic such as jumuah
+// whose local delivery time today has passed without it being sent. The
+// date is the subscriber's local date so Friday is their Friday. Like
+// scheduled deliveries, those missed by more than PushMaxLateness are
+// skipped.
+func DueTopicPushes(topic string, now time.Time) []TopicPush {
+	var due []TopicPush
+	for _, sub := range TopicPushSubscriptions(topic) {
+		prefs := sub.Preferences.WithDefaults()
+		loc := prefs.location()
+		hour, minute, _ := prefs.clock()
+
+		local := now.In(loc)
+		at := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, loc)
+		date := local.Format("2006-01-02")
+
+		if now.Before(at) || now.Sub(at) > PushMaxLateness || sub.TopicDates[topic] == date {
+			continue
+		}
+		day, _ := time.Parse("2006-01-02", date)
+		due = append(due, TopicPush{Subscription: sub, Date: day})
+	}
+	return due
+}
+
+// SetTopicDelivered records the local date the topic was sent to each
+// subscription
+func SetTopicDelivered(topic string, delivered map[string]string) error {
+	if len(delivered) == 0 {
+		return nil
+	}
+	pushMtx.Lock()
+	for endpoint, date := range delivered {
+		if sub, ok := pushSubscriptions[endpoint]; ok {
+			dates := make(map[string]string, len(sub.TopicDates)+1)
+			for t, d := range sub.TopicDates {
+				dates[t] = d
+			}
+			dates[topic] = date
+			sub.TopicDates = dates
+			pushSubscriptions[endpoint] = sub
+		}
+	}
+	pushMtx.Unlock()
+	return SavePushSubscriptions()
+}
+
+// SendPushToTopic sends the payload to every subscription for the topic
+func SendPushToTopic(topic, payload string) []*Delivery {
+	var msgs []PushMessage
+	for _, sub := range TopicPushSubscriptions(topic) {
+		msgs = append(msgs, PushMessage{Subscription: sub, Payload: payload})
+	}
+	return DefaultDispatcher.Dispatch(msgs)
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDueTopicPushes(t *testing.T) {
+	pushFile = filepath.Join(t.TempDir(), "push_subscriptions.json")
+	pushSubscriptions = map[string]PushSubscription{
+		"utc":     {Endpoint: "utc", Topics: []string{TopicJumuah}},
+		"newyork": {Endpoint: "newyork", Topics: []string{TopicJumuah}, Preferences: PushPreferences{TimeZone: "America/New_York", Time: "07:00"}},
+		"daily":   {Endpoint: "daily"},
+	}
+
+	// Friday 12th January 2024 00:00 UTC is Thursday evening in New York
+	now := time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)
+	due := DueTopicPushes(TopicJumuah, now)
+	if len(due) != 1 || due[0].Subscription.Endpoint != "utc" || due[0].Date.Weekday() != time.Friday {
+		t.Fatalf("expected utc to be due on friday, got %+v", due)
+	}
+	if err := SetTopicDelivered(TopicJumuah, map[string]string{"utc": "2024-01-12"}); err != nil {
+		t.Fatal(err)
+	}
+	if due := DueTopicPushes(TopicJumuah, now.Add(time.Minute)); len(due) != 0 {
+		t.Fatalf("expected nothing due once delivered, got %+v", due)
+	}
+
+	// 07:00 on Friday in New York
+	due = DueTopicPushes(TopicJumuah, time.Date(2024, 1, 12, 12, 0, 0, 0, time.UTC))
+	if len(due) != 1 || due[0].Subscription.Endpoint != "newyork" || due[0].Date.Format("2006-01-02") != "2024-01-12" {
+		t.Fatalf("expected newyork to be due on its friday, got %+v", due)
+	}
+}
@@ -1,13 +1,29 @@
 package api
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"os"
 	"sync"
+	"time"
 )
 
 type User struct {
 	Email string `json:"email"`
+	// Token used to confirm and unsubscribe from the email digest
+	Token string `json:"token,omitempty"`
+	// Whether the email address has been confirmed
+	Confirmed bool `json:"confirmed"`
+	// Whether the user receives the daily email digest
+	Digest bool `json:"digest"`
+	// bcrypt hash of the password, empty for passwordless accounts
+	PasswordHash string    `json:"password_hash,omitempty"`
+	Created      time.Time `json:"created,omitempty"`
+	// Last time the user logged in, zero for digest only subscribers
+	Login time.Time `json:"login,omitempty"`
+	// Last time a confirmation email was sent
+	ConfirmationSent time.Time `json:"confirmation_sent,omitempty"`
 }
 
 type Users struct {
@@ -33,27 +49,90 @@ func LoadUsers() error {
 func SaveUsers() error {
 	users.mu.RLock()
 	defer users.mu.RUnlock()
+	return saveUsers()
+}
+
+// saveUsers writes the users, the caller must hold the lock
+func saveUsers() error {
 	_ = os.MkdirAll(ReminderDir, 0700)
-	f, err := os.Create(usersFile)
+	b, err := json.Marshal(users.Users)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	return json.NewEncoder(f).Encode(users.Users)
+	return WriteFile(usersFile, b, 0600)
 }
 
 func AddUser(email string) error {
 	users.mu.Lock()
 	defer users.mu.Unlock()
-	users.Users[email] = User{Email: email}
-	return SaveUsers()
+	users.Users[email] = User{Email: email, Token: newToken(), Created: time.Now()}
+	return saveUsers()
 }
 
 func RemoveUser(email string) error {
 	users.mu.Lock()
 	defer users.mu.Unlock()
 	delete(users.Users, email)
-	return SaveUsers()
+	return saveUsers()
+}
+
+// GetUser returns the user with the given email
+func GetUser(email string) (User, bool) {
+	users.mu.RLock()
+	defer users.mu.RUnlock()
+	u, ok := users.Users[email]
+	return u, ok
+}
+
+// UserByToken returns the user with the given token
+func UserByToken(token string) (User, bool) {
+	users.mu.RLock()
+	defer users.mu.RUnlock()
+	if len(token) == 0 {
+		return User{}, false
+	}
+	for _, u := range users.Users {
+		if u.Token == token {
+			return u, true
+		}
+	}
+	return User{}, false
+}
+
+// ConfirmUser marks the user's email as confirmed. A password set before
+// the address was ever confirmed wasn't set by its owner so it's cleared.
+func ConfirmUser(email string) error {
+	users.mu.Lock()
+	defer users.mu.Unlock()
+	u, ok := users.Users[email]
+	if !ok {
+		return os.ErrNotExist
+	}
+	if !u.Confirmed {
+		u.PasswordHash = ""
+	}
+	u.Confirmed = true
+	users.Users[email] = u
+	return saveUsers()
+}
+
+// UpdateUser applies fn to the user with the given email and saves it
+func UpdateUser(email string, fn func(u *User)) error {
+	users.mu.Lock()
+	defer users.mu.Unlock()
+	u, ok := users.Users[email]
+	if !ok {
+		return os.ErrNotExist
+	}
+	fn(&u)
+	users.Users[email] = u
+	return saveUsers()
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
 
 func ListUsers() []User {
@@ -305,6 +305,20 @@ func Render(md []byte) []byte {
 	return markdown.Render(doc, renderer)
 }
 
+// RenderSafe renders user written markdown, dropping raw HTML and
+// links to untrusted protocols
+func RenderSafe(md []byte) []byte {
+	extensions := parser.CommonExtensions | parser.AutoHeadingIDs | parser.NoEmptyLineBeforeBlock
+	p := parser.NewWithExtensions(extensions)
+	doc := p.Parse(md)
+
+	htmlFlags := html.CommonFlags | html.HrefTargetBlank | html.SkipHTML | html.Safelink
+	opts := html.RendererOptions{Flags: htmlFlags}
+	renderer := html.NewRenderer(opts)
+
+	return markdown.Render(doc, renderer)
+}
+
 func RenderHTML(title, desc, html string) string {
 	return fmt.Sprintf(Template, title, title, desc, html)
 }
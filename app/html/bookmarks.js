@@ -1,7 +1,80 @@
 // Bookmarks management for Reminder app
 // Stores bookmarks in localStorage organized by type (quran, hadith, names)
+// and syncs them to the server when logged in or using a sync code
 
 const STORAGE_KEY = 'reminder_bookmarks';
+const SYNC_KEY = 'reminder_sync_code';
+// Set while the server accepts our session or sync code
+const SYNCED_KEY = 'reminder_bookmarks_synced';
+
+// Removed bookmarks are kept as tombstones under deleted by type:key so
+// removals sync like additions, for as long as the server keeps them
+const TOMBSTONE_TTL = 90 * 24 * 60 * 60 * 1000;
+
+// Get the sync code used to share bookmarks without an account
+function getSyncCode() {
+  return localStorage.getItem(SYNC_KEY) || '';
+}
+
+function setSyncCode(code) {
+  localStorage.setItem(SYNC_KEY, code.toUpperCase());
+}
+
+function syncHeaders() {
+  const headers = { 'Content-Type': 'application/json' };
+  const code = getSyncCode();
+  if (code) {
+    headers['X-Sync-Code'] = code;
+  }
+  return headers;
+}
+
+// Create a new sync code, the current bookmarks are merged into it
+async function createSyncCode() {
+  const resp = await fetch('/api/bookmarks/sync', { method: 'POST' });
+  if (!resp.ok) return null;
+  const data = await resp.json();
+  setSyncCode(data.code);
+  return data.code;
+}
+
+// Merge local bookmarks with the server and return the result, or null
+// if not logged in and there's no sync code
+async function syncBookmarks() {
+  try {
+    const resp = await fetch('/api/bookmarks/import', {
+      method: 'POST',
+      headers: syncHeaders(),
+      credentials: 'same-origin',
+      body: JSON.stringify(getBookmarks())
+    });
+    if (!resp.ok) {
+      localStorage.removeItem(SYNCED_KEY);
+      return null;
+    }
+    localStorage.setItem(SYNCED_KEY, '1');
+    const bookmarks = await resp.json();
+    saveBookmarks(bookmarks);
+    return bookmarks;
+  } catch (e) {
+    console.error('Error syncing bookmarks:', e);
+    return null;
+  }
+}
+
+// Mirror a change to the server, skipped when there's neither a sync code
+// nor a session. Changes missed are merged on the next sync.
+function sendBookmark(method, path, body) {
+  if (!getSyncCode() && !localStorage.getItem(SYNCED_KEY)) return;
+  fetch(path, {
+    method: method,
+    headers: syncHeaders(),
+    credentials: 'same-origin',
+    body: body ? JSON.stringify(body) : undefined
+  }).then(resp => {
+    if (resp.status === 401) localStorage.removeItem(SYNCED_KEY);
+  }).catch(e => console.error('Error syncing bookmark:', e));
+}
 
 // Initialize bookmarks structure
 function initBookmarks() {
@@ -50,7 +123,11 @@ function addBookmark(type, key, label, url) {
     url: url,
     timestamp: new Date().toISOString()
   };
+  if (bookmarks.deleted) {
+    delete bookmarks.deleted[type + ':' + key];
+  }
   
+  sendBookmark('POST', '/api/bookmarks', { type: type, key: key, label: label, url: url });
   return saveBookmarks(bookmarks);
 }
 
@@ -60,12 +137,26 @@ function deleteBookmark(type, key) {
   
   if (bookmarks[type] && bookmarks[type][key]) {
     delete bookmarks[type][key];
+    bookmarks.deleted = pruneTombstones(bookmarks.deleted || {});
+    bookmarks.deleted[type + ':' + key] = { timestamp: new Date().toISOString() };
+    sendBookmark('DELETE', '/api/bookmarks?type=' + encodeURIComponent(type) + '&key=' + encodeURIComponent(key));
     return saveBookmarks(bookmarks);
   }
   
   return false;
 }
 
+// Drop tombstones older than the server keeps them
+function pruneTombstones(deleted) {
+  const cutoff = Date.now() - TOMBSTONE_TTL;
+  for (const id of Object.keys(deleted)) {
+    if (Date.parse(deleted[id].timestamp) < cutoff) {
+      delete deleted[id];
+    }
+  }
+  return deleted;
+}
+
 // Check if a bookmark exists
 function hasBookmark(type, key) {
   const bookmarks = getBookmarks();
@@ -0,0 +1,246 @@
+package daily
+
+import (
+	"fmt"
+	"time"
+
+	hijri "github.com/hablullah/go-hijri"
+)
+
+// HijriOffset adjusts hijri dates by days for local moon sighting e.g -1
+// where months begin a day later than the Umm al-Qura calendar
+var HijriOffset int
+
+// MaxHijriOffset is the largest adjustment allowed either way
+const MaxHijriOffset = 2
+
+// Years of the hijri calendar covered by the Umm al-Qura tables, from
+// 1937 to 2077
+const (
+	MinHijriYear = 1356
+	MaxHijriYear = 1500
+)
+
+// Months of the hijri calendar
+var Months = []string{"Muharram", "Safar", "Rabiʿ al-awwal", "Rabiʿ al-thani", "Jumada al-awwal", "Jumada al-thani", "Rajab", "Shaʿban", "Ramadan", "Shawwal", "Dhu al-Qiʿdah", "Dhu al-Hijjah"}
+
+// Hijri is a date of the Umm al-Qura calendar
+type Hijri struct {
+	Year  int `json:"year"`
+	Month int `json:"month"`
+	Day   int `json:"day"`
+}
+
+// String formats the date e.g 1445-09-10
+func (h Hijri) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", h.Year, h.Month, h.Day)
+}
+
+// Display formats the date e.g 10th of Ramadan, 1445
+func (h Hijri) Display() string {
+	return fmt.Sprintf("%d%s of %s, %d", h.Day, ordinal(h.Day), Months[h.Month-1], h.Year)
+}
+
+func ordinal(n int) string {
+	if n%100 >= 11 && n%100 <= 13 {
+		return "th"
+	}
+	switch n % 10 {
+	case 1:
+		return "st"
+	case 2:
+		return "nd"
+	case 3:
+		return "rd"
+	}
+	return "th"
+}
+
+// ParseHijri parses a date such as 1445-09-10
+func ParseHijri(date string) (Hijri, error) {
+	var h Hijri
+	if _, err := fmt.Sscanf(date, "%d-%d-%d", &h.Year, &h.Month, &h.Day); err != nil {
+		return h, fmt.Errorf("invalid hijri date %q", date)
+	}
+	return h, nil
+}
+
+func checkOffset(offset int) error {
+	if offset < -MaxHijriOffset || offset > MaxHijriOffset {
+		return fmt.Errorf("offset must be between -%d and %d", MaxHijriOffset, MaxHijriOffset)
+	}
+	return nil
+}
+
+// ToHijri converts the gregorian date to hijri, adjusted by the offset
+func ToHijri(t time.Time, offset int) (Hijri, error) {
+	if err := checkOffset(offset); err != nil {
+		return Hijri{}, err
+	}
+	h, err := hijri.CreateUmmAlQuraDate(t.AddDate(0, 0, offset))
+	if err != nil {
+		return Hijri{}, err
+	}
+	return Hijri{Year: int(h.Year), Month: int(h.Month), Day: int(h.Day)}, nil
+}
+
+// ToGregorian converts the hijri date, adjusted by the offset, to the
+// gregorian date at midnight UTC
+func ToGregorian(h Hijri, offset int) (time.Time, error) {
+	if err := checkOffset(offset); err != nil {
+		return time.Time{}, err
+	}
+	if h.Month < 1 || h.Month > 12 || h.Day < 1 || h.Day > 30 {
+		return time.Time{}, fmt.Errorf("invalid hijri date %s", h)
+	}
+	if h.Year < MinHijriYear || h.Year > MaxHijriYear {
+		return time.Time{}, fmt.Errorf("date is outside Umm al-Qura scope")
+	}
+
+	g := hijri.UmmAlQuraDate{Year: int64(h.Year), Month: int64(h.Month), Day: int64(h.Day)}.ToGregorian()
+	t := time.Date(g.Year(), g.Month(), g.Day(), 0, 0, 0, 0, time.UTC)
+
+	// the 30th of a 29 day month converts to the next month
+	if back, err := ToHijri(t, 0); err != nil || back != h {
+		return time.Time{}, fmt.Errorf("invalid hijri date %s", h)
+	}
+
+	return t.AddDate(0, 0, -offset), nil
+}
+
+// Event is an annual day of the hijri calendar
+type Event struct {
+	Month int    `json:"month"`
+	Day   int    `json:"day"`
+	Name  string `json:"name"`
+}
+
+// Events of the hijri calendar in date order
+var Events = []*Event{
+	{1, 1, "Islamic New Year"},
+	{1, 9, "Tasu'a"},
+	{1, 10, "Ashura"},
+	{9, 1, "Start of Ramadan"},
+	{9, 21, "Last ten nights of Ramadan"},
+	{10, 1, "Eid al-Fitr"},
+	{12, 1, "First ten days of Dhul Hijjah"},
+	{12, 8, "Day of Tarwiyah"},
+	{12, 9, "Day of Arafah"},
+	{12, 10, "Eid al-Adha"},
+	{12, 11, "Days of Tashriq"},
+	{12, 12, "Days of Tashriq"},
+	{12, 13, "Days of Tashriq"},
+}
+
+// EventsOn returns the names of the events on the date
+func EventsOn(h Hijri) []string {
+	var names []string
+	for _, e := range Events {
+		if e.Month == h.Month && e.Day == h.Day {
+			names = append(names, e.Name)
+		}
+	}
+	return names
+}
+
+// Occurrence is an event on a gregorian date
+type Occurrence struct {
+	*Event
+	Hijri Hijri     `json:"hijri"`
+	Date  time.Time `json:"date"`
+}
+
+// Upcoming returns the events in the days from the start date
+func Upcoming(start time.Time, days, offset int) ([]*Occurrence, error) {
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+
+	var occurrences []*Occurrence
+	for d := 0; d < days; d++ {
+		t := start.AddDate(0, 0, d)
+		h, err := ToHijri(t, offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range Events {
+			if e.Month == h.Month && e.Day == h.Day {
+				occurrences = append(occurrences, &Occurrence{Event: e, Hijri: h, Date: t})
+			}
+		}
+	}
+	return occurrences, nil
+}
+
+// Day is a date in both calendars
+type Day struct {
+	Day       int      `json:"day"`
+	Hijri     string   `json:"hijri"`
+	Display   string   `json:"display"`
+	Gregorian string   `json:"gregorian"`
+	Weekday   string   `json:"weekday"`
+	Events    []string `json:"events,omitempty"`
+}
+
+func newDay(h Hijri, t time.Time) *Day {
+	return &Day{
+		Day:       h.Day,
+		Hijri:     h.String(),
+		Display:   h.Display(),
+		Gregorian: t.Format("2006-01-02"),
+		Weekday:   t.Weekday().String(),
+		Events:    EventsOn(h),
+	}
+}
+
+// ConvertGregorian returns the day of the gregorian date
+func ConvertGregorian(t time.Time, offset int) (*Day, error) {
+	h, err := ToHijri(t, offset)
+	if err != nil {
+		return nil, err
+	}
+	return newDay(h, t), nil
+}
+
+// ConvertHijri returns the day of the hijri date
+func ConvertHijri(h Hijri, offset int) (*Day, error) {
+	t, err := ToGregorian(h, offset)
+	if err != nil {
+		return nil, err
+	}
+	return newDay(h, t), nil
+}
+
+// Month is a hijri month laid out in weeks from Sunday, with nil for the
+// days before the 1st and after the end of the month
+type Month struct {
+	Year   int      `json:"year"`
+	Month  int      `json:"month"`
+	Name   string   `json:"name"`
+	Days   int      `json:"days"`
+	Offset int      `json:"offset"`
+	Weeks  [][]*Day `json:"weeks"`
+}
+
+// CalendarMonth returns the hijri month with gregorian equivalents
+func CalendarMonth(year, month, offset int) (*Month, error) {
+	first, err := ToGregorian(Hijri{year, month, 1}, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Month{Year: year, Month: month, Name: Months[month-1], Days: 29, Offset: offset}
+	if _, err := ToGregorian(Hijri{year, month, 30}, offset); err == nil {
+		m.Days = 30
+	}
+
+	week := make([]*Day, 7)
+	for d := 1; d <= m.Days; d++ {
+		t := first.AddDate(0, 0, d-1)
+		week[t.Weekday()] = newDay(Hijri{year, month, d}, t)
+		if t.Weekday() == time.Saturday || d == m.Days {
+			m.Weeks = append(m.Weeks, week)
+			week = make([]*Day, 7)
+		}
+	}
+
+	return m, nil
+}
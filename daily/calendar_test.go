@@ -0,0 +1,115 @@
+package daily
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	for _, c := range []struct {
+		gregorian string
+		hijri     string
+		offset    int
+	}{
+		{"2024-03-11", "1445-09-01", 0},
+		{"2024-04-10", "1445-10-01", 0},
+		{"2024-06-16", "1445-12-10", 0},
+		// a day behind where the month began a day later
+		{"2024-03-11", "1445-08-29", -1},
+		{"2024-03-12", "1445-09-01", -1},
+	} {
+		g, _ := ParseDate(c.gregorian)
+		day, err := ConvertGregorian(g, c.offset)
+		if err != nil || day.Hijri != c.hijri {
+			t.Fatalf("%s: expected %s, got %+v %v", c.gregorian, c.hijri, day, err)
+		}
+
+		h, _ := ParseHijri(c.hijri)
+		day, err = ConvertHijri(h, c.offset)
+		if err != nil || day.Gregorian != c.gregorian {
+			t.Fatalf("%s: expected %s, got %+v %v", c.hijri, c.gregorian, day, err)
+		}
+	}
+
+	h, _ := ParseHijri("1445-10-01")
+	day, _ := ConvertHijri(h, 0)
+	if day.Display != "1st of Shawwal, 1445" || !reflect.DeepEqual(day.Events, []string{"Eid al-Fitr"}) {
+		t.Fatalf("unexpected day %+v", day)
+	}
+
+	// Ramadan 1445 had 30 days so Shawwal 30 doesn't exist
+	for _, date := range []string{"1445-09-31", "1445-13-01", "1445-10-30"} {
+		h, _ := ParseHijri(date)
+		if _, err := ConvertHijri(h, 0); err == nil {
+			t.Fatalf("%s: expected error", date)
+		}
+	}
+	if _, err := ConvertHijri(Hijri{1445, 9, 1}, 3); err == nil {
+		t.Fatal("expected offset error")
+	}
+
+	// years outside the Umm al-Qura tables are errors, not panics
+	for _, year := range []int{1, 1300, MinHijriYear - 1, MaxHijriYear + 1, 1600, 99999} {
+		if _, err := ConvertHijri(Hijri{year, 1, 1}, 0); err == nil {
+			t.Fatalf("%d: expected error", year)
+		}
+		if _, err := CalendarMonth(year, 1, 0); err == nil {
+			t.Fatalf("%d: expected month error", year)
+		}
+	}
+	for _, h := range []Hijri{{MinHijriYear, 1, 1}, {MaxHijriYear, 12, 1}} {
+		if _, err := ConvertHijri(h, 0); err != nil {
+			t.Fatalf("%s: %v", h, err)
+		}
+	}
+}
+
+func TestCalendarMonth(t *testing.T) {
+	m, err := CalendarMonth(1445, 9, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "Ramadan" || m.Days != 30 {
+		t.Fatalf("unexpected month %+v", m)
+	}
+
+	// the 1st was a Monday
+	if m.Weeks[0][0] != nil || m.Weeks[0][1].Gregorian != "2024-03-11" {
+		t.Fatalf("unexpected first week %+v", m.Weeks[0])
+	}
+
+	var days int
+	for _, week := range m.Weeks {
+		if len(week) != 7 {
+			t.Fatalf("expected 7 days in a week, got %d", len(week))
+		}
+		for _, d := range week {
+			if d != nil {
+				days++
+			}
+		}
+	}
+	if days != 30 {
+		t.Fatalf("expected 30 days, got %d", days)
+	}
+}
+
+func TestUpcoming(t *testing.T) {
+	start, _ := ParseDate("2024-03-01")
+	events, err := Upcoming(start, 365, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != len(Events) {
+		t.Fatalf("expected every event once in a year, got %d", len(events))
+	}
+	if e := events[0]; e.Name != "Start of Ramadan" || e.Date.Format("2006-01-02") != "2024-03-11" {
+		t.Fatalf("expected ramadan first, got %+v", e)
+	}
+
+	// a day later where the month began a day later
+	events, _ = Upcoming(start, 30, -1)
+	if len(events) == 0 || events[0].Date.Format("2006-01-02") != "2024-03-12" {
+		t.Fatalf("expected ramadan on 2024-03-12, got %+v", events)
+	}
+}
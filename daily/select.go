@@ -0,0 +1,236 @@
+package daily
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"time"
+
+	"github.com/asim/reminder/hadith"
+	"github.com/asim/reminder/names"
+	"github.com/asim/reminder/quran"
+)
+
+// Hours is the number of selections made each day, one an hour. The
+// selection for hour 0 is the daily reminder.
+const Hours = 24
+
+// Selection is the verse, hadith and name for an hour of a date
+type Selection struct {
+	Date string `json:"date"`
+	Hour int    `json:"hour"`
+	// Seed and slot from which the selection is derived
+	Seed int64 `json:"seed"`
+	Slot int64 `json:"slot"`
+	// Days in which no verse or hadith repeats
+	Window int `json:"window"`
+	// Name of the theme content was preferred from, only for hour 0
+	Theme string `json:"theme,omitempty"`
+	// Keys of the selected content e.g 2:255, 1:1 and 1
+	VerseKey  string `json:"verse"`
+	HadithKey string `json:"hadith"`
+	NameKey   int    `json:"name"`
+
+	Chapter *quran.Chapter `json:"-"`
+	Verse   *quran.Verse   `json:"-"`
+	Book    *hadith.Book   `json:"-"`
+	Hadith  *hadith.Hadith `json:"-"`
+	Name    *names.Name    `json:"-"`
+}
+
+type verseRef struct {
+	chapter *quran.Chapter
+	verse   *quran.Verse
+}
+
+type hadithRef struct {
+	book   *hadith.Book
+	hadith *hadith.Hadith
+}
+
+// Selector deterministically selects content for any date and hour. Each
+// pool of content is walked in a shuffled order derived from the seed so
+// every item is used once before any repeats, and the start of each pass
+// avoids the end of the previous one so nothing repeats within the window.
+// On days with a theme its content is preferred for hour 0, the daily
+// reminder, and may repeat. The other hours keep to the shuffled pools and
+// their window.
+type Selector struct {
+	Seed int64
+	// Days in which no verse or hadith repeats, limited by the size of
+	// the smallest pool
+	Window int
+
+	names  []*names.Name
+	verses []verseRef
+	hadith []hadithRef
+	themes []*themed
+
+	quran      *quran.Quran
+	collection *hadith.Collection
+	valid      func(*quran.Verse) bool
+}
+
+// NewSelector creates a selector over the content. Only verses accepted
+// by valid are selected, or all verses if it's nil.
+func NewSelector(seed int64, window int, n *names.Names, q *quran.Quran, b *hadith.Collection, valid func(*quran.Verse) bool) *Selector {
+	s := &Selector{Seed: seed, names: *n, quran: q, collection: b, valid: valid}
+
+	for _, ch := range q.Chapters {
+		for _, v := range ch.Verses {
+			if !s.selectable(v) {
+				continue
+			}
+			s.verses = append(s.verses, verseRef{ch, v})
+		}
+	}
+
+	for _, book := range b.Books {
+		for _, h := range book.Hadiths {
+			s.hadith = append(s.hadith, hadithRef{book, h})
+		}
+	}
+
+	s.Window = min(max(window, 0), maxWindow(len(s.verses)), maxWindow(len(s.hadith)))
+
+	return s
+}
+
+// maxWindow is the largest window in days a pool of the size supports
+func maxWindow(size int) int {
+	return size / (4 * Hours)
+}
+
+// ParseDate parses a date such as 2024-01-10 as midnight UTC
+func ParseDate(date string) (time.Time, error) {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return t, fmt.Errorf("invalid date %q", date)
+	}
+	return t, nil
+}
+
+// Slot numbers every hour since 1970-01-01 UTC
+func Slot(t time.Time, hour int) int64 {
+	day := floorDiv(t.UTC().Unix(), 24*60*60)
+	return day*Hours + int64(hour)
+}
+
+func floorDiv(a, b int64) int64 {
+	d := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		d--
+	}
+	return d
+}
+
+// Select returns the selection for the hour of the date
+func (s *Selector) Select(date time.Time, hour int) (*Selection, error) {
+	if len(s.names) == 0 || len(s.verses) == 0 || len(s.hadith) == 0 {
+		return nil, fmt.Errorf("no content to select from")
+	}
+	if hour < 0 || hour >= Hours {
+		return nil, fmt.Errorf("invalid hour %d", hour)
+	}
+
+	slot := Slot(date, hour)
+
+	var name *names.Name
+	var v *verseRef
+	var h *hadithRef
+	var theme string
+
+	// prefer the content of the first theme which has any for the daily
+	// reminder, walking each themed pool a day at a time
+	var themes []*themed
+	if hour == 0 {
+		themes = s.match(date)
+	}
+	day := floorDiv(slot, Hours)
+	for _, t := range themes {
+		pool := "theme:" + t.Name
+		if name == nil && len(t.names) > 0 {
+			name = s.names[t.names[s.pick(pool+":names", len(t.names), day, 0)]]
+		}
+		if v == nil && len(t.verses) > 0 {
+			v = &t.verses[s.pick(pool+":verses", len(t.verses), day, 0)]
+		}
+		if h == nil && len(t.hadith) > 0 {
+			h = &t.hadith[s.pick(pool+":hadith", len(t.hadith), day, 0)]
+		}
+		if len(theme) == 0 && (len(t.names) > 0 || len(t.verses) > 0 || len(t.hadith) > 0) {
+			theme = t.Name
+		}
+	}
+
+	if name == nil {
+		nameWindow := min(s.Window, maxWindow(len(s.names)))
+		name = s.names[s.pick("names", len(s.names), slot, nameWindow)]
+	}
+	if v == nil {
+		v = &s.verses[s.pick("verses", len(s.verses), slot, s.Window)]
+	}
+	if h == nil {
+		h = &s.hadith[s.pick("hadith", len(s.hadith), slot, s.Window)]
+	}
+
+	return &Selection{
+		Date:      date.UTC().Format("2006-01-02"),
+		Hour:      hour,
+		Seed:      s.Seed,
+		Slot:      slot,
+		Window:    s.Window,
+		Theme:     theme,
+		VerseKey:  fmt.Sprintf("%d:%d", v.chapter.Number, v.verse.Number),
+		HadithKey: fmt.Sprintf("%d:%d", h.book.Number, h.hadith.Number),
+		NameKey:   name.Number,
+		Chapter:   v.chapter,
+		Verse:     v.verse,
+		Book:      h.book,
+		Hadith:    h.hadith,
+		Name:      name,
+	}, nil
+}
+
+// pick returns the index into a pool of the size for the slot
+func (s *Selector) pick(pool string, size int, slot int64, window int) int {
+	epoch := floorDiv(slot, int64(size))
+	return s.order(pool, size, epoch, window)[slot-epoch*int64(size)]
+}
+
+// shuffle returns the base order of a pool for a pass
+func (s *Selector) shuffle(pool string, size int, epoch int64) []int {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d:%s:%d", s.Seed, pool, epoch)
+	return rand.New(rand.NewSource(int64(h.Sum64()))).Perm(size)
+}
+
+// order returns the order of a pool for a pass. Items at the end of the
+// previous pass are swapped out of the start of this one with items from
+// the middle, leaving the end of every pass as shuffled.
+func (s *Selector) order(pool string, size int, epoch int64, window int) []int {
+	order := s.shuffle(pool, size, epoch)
+	span := window * Hours
+	if span == 0 {
+		return order
+	}
+
+	recent := map[int]bool{}
+	for _, i := range s.shuffle(pool, size, epoch-1)[size-span:] {
+		recent[i] = true
+	}
+
+	j := span
+	for i := 0; i < span; i++ {
+		if !recent[order[i]] {
+			continue
+		}
+		for recent[order[j]] {
+			j++
+		}
+		order[i], order[j] = order[j], order[i]
+		j++
+	}
+
+	return order
+}
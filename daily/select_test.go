@@ -0,0 +1,99 @@
+package daily
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asim/reminder/hadith"
+	"github.com/asim/reminder/names"
+	"github.com/asim/reminder/quran"
+)
+
+func testSelector(seed int64, window int) *Selector {
+	n := names.Names{}
+	for i := 1; i <= 99; i++ {
+		n = append(n, &names.Name{Number: i})
+	}
+
+	q := &quran.Quran{}
+	for c := 1; c <= 10; c++ {
+		ch := &quran.Chapter{Number: c}
+		for v := 1; v <= 100; v++ {
+			ch.Verses = append(ch.Verses, &quran.Verse{Chapter: c, Number: v})
+		}
+		q.Chapters = append(q.Chapters, ch)
+	}
+
+	b := &hadith.Collection{}
+	for i := 1; i <= 5; i++ {
+		book := &hadith.Book{Number: i}
+		for h := 1; h <= 500; h++ {
+			book.Hadiths = append(book.Hadiths, &hadith.Hadith{Number: h})
+		}
+		b.Books = append(b.Books, book)
+	}
+
+	return NewSelector(seed, window, &n, q, b, nil)
+}
+
+func TestSelect(t *testing.T) {
+	// 1000 verses supports a window of 10 days
+	s := testSelector(1, 30)
+	if s.Window != 10 {
+		t.Fatalf("expected window limited to 10, got %d", s.Window)
+	}
+
+	date, _ := ParseDate("2024-03-10")
+	a, err := s.Select(date, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same seed always gives the same selection
+	b, _ := testSelector(1, 30).Select(date, 0)
+	if a.Slot != b.Slot || a.VerseKey != b.VerseKey || a.HadithKey != b.HadithKey || a.NameKey != b.NameKey {
+		t.Fatalf("expected %+v, got %+v", a, b)
+	}
+
+	// a different seed gives a different selection
+	c, _ := testSelector(2, 30).Select(date, 0)
+	if a.VerseKey == c.VerseKey && a.HadithKey == c.HadithKey {
+		t.Fatalf("expected seeds to differ, got %+v", c)
+	}
+
+	if _, err := s.Select(date, Hours); err == nil {
+		t.Fatal("expected invalid hour")
+	}
+}
+
+func TestSelectWindow(t *testing.T) {
+	s := testSelector(7, 10)
+	span := int64(s.Window * Hours)
+
+	verses := map[string]int64{}
+	hadith := map[string]int64{}
+
+	// several passes over the verses including dates before 1970
+	start, _ := ParseDate("1969-12-01")
+	for d := 0; d < 200; d++ {
+		date := start.Add(time.Duration(d) * 24 * time.Hour)
+		for hour := 0; hour < Hours; hour++ {
+			sel, err := s.Select(date, hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if last, ok := verses[sel.VerseKey]; ok && sel.Slot-last < span {
+				t.Fatalf("verse %s repeated after %d hours", sel.VerseKey, sel.Slot-last)
+			}
+			if last, ok := hadith[sel.HadithKey]; ok && sel.Slot-last < span {
+				t.Fatalf("hadith %s repeated after %d hours", sel.HadithKey, sel.Slot-last)
+			}
+			verses[sel.VerseKey] = sel.Slot
+			hadith[sel.HadithKey] = sel.Slot
+		}
+	}
+
+	if len(verses) != 1000 {
+		t.Fatalf("expected every verse to be selected, got %d", len(verses))
+	}
+}
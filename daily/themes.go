@@ -0,0 +1,197 @@
+package daily
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/asim/reminder/api"
+	"github.com/asim/reminder/quran"
+)
+
+//go:embed themes.json
+var defaultThemes []byte
+
+// ThemesFile overrides the embedded themes if it exists
+var ThemesFile = api.ReminderPath("themes.json")
+
+// Theme is a curated pool of content preferred on days of the Islamic
+// calendar such as Ramadan or Fridays
+type Theme struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	// Hijri month 1-12, or 0 for every month
+	Month int `json:"month,omitempty"`
+	// Days of the hijri month, or every day if empty
+	Days []int `json:"days,omitempty"`
+	// Day of the week e.g friday, or every day if empty
+	Weekday string `json:"weekday,omitempty"`
+	// Verses e.g 2:183 or 2:183-185
+	Verses []string `json:"verses,omitempty"`
+	// Hadith as book:number e.g 30:1904
+	Hadith []string `json:"hadith,omitempty"`
+	// Numbers of the names of Allah
+	Names []int `json:"names,omitempty"`
+}
+
+// Matches reports whether the theme applies to the day
+func (t *Theme) Matches(h Hijri, weekday time.Weekday) bool {
+	if t.Month > 0 && t.Month != h.Month {
+		return false
+	}
+	if len(t.Weekday) > 0 && !strings.EqualFold(t.Weekday, weekday.String()) {
+		return false
+	}
+	if len(t.Days) == 0 {
+		return true
+	}
+	for _, d := range t.Days {
+		if d == h.Day {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadThemes loads the themes from ThemesFile, or the embedded defaults.
+// Themes are in order of preference.
+func LoadThemes() ([]*Theme, error) {
+	b, err := os.ReadFile(ThemesFile)
+	if os.IsNotExist(err) {
+		b, err = defaultThemes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var themes []*Theme
+	if err := json.Unmarshal(b, &themes); err != nil {
+		return nil, fmt.Errorf("invalid themes: %v", err)
+	}
+	for _, t := range themes {
+		if len(t.Name) == 0 {
+			return nil, fmt.Errorf("invalid themes: theme without a name")
+		}
+		if t.Month < 0 || t.Month > 12 {
+			return nil, fmt.Errorf("invalid themes: %s has month %d", t.Name, t.Month)
+		}
+	}
+	return themes, nil
+}
+
+// themed is a theme with its content resolved
+type themed struct {
+	*Theme
+	names  []int
+	verses []verseRef
+	hadith []hadithRef
+}
+
+// SetThemes resolves the content of the themes for selection, returning
+// any references which weren't found
+func (s *Selector) SetThemes(themes []*Theme) []string {
+	var missing []string
+	var resolved []*themed
+
+	for _, t := range themes {
+		r := &themed{Theme: t}
+
+		for _, number := range t.Names {
+			found := false
+			for i, n := range s.names {
+				if n.Number == number {
+					r.names = append(r.names, i)
+					found = true
+					break
+				}
+			}
+			if !found {
+				missing = append(missing, fmt.Sprintf("%s: name %d", t.Name, number))
+			}
+		}
+
+		for _, key := range t.Verses {
+			refs := s.themeVerses(key)
+			if len(refs) == 0 {
+				missing = append(missing, fmt.Sprintf("%s: verse %s", t.Name, key))
+			}
+			r.verses = append(r.verses, refs...)
+		}
+
+		for _, key := range t.Hadith {
+			var book, number int
+			if _, err := fmt.Sscanf(key, "%d:%d", &book, &number); err == nil {
+				if bk := s.collection.Get(book); bk != nil {
+					if h := s.collection.Hadith(book, number); h != nil {
+						r.hadith = append(r.hadith, hadithRef{bk, h})
+						continue
+					}
+				}
+			}
+			missing = append(missing, fmt.Sprintf("%s: hadith %s", t.Name, key))
+		}
+
+		resolved = append(resolved, r)
+	}
+
+	s.themes = resolved
+	return missing
+}
+
+// themeVerses returns the selectable verses for a key such as 2:183-185
+func (s *Selector) themeVerses(key string) []verseRef {
+	var chapter, start, end int
+	if _, err := fmt.Sscanf(key, "%d:%d-%d", &chapter, &start, &end); err != nil {
+		if _, err := fmt.Sscanf(key, "%d:%d", &chapter, &start); err != nil {
+			return nil
+		}
+		end = start
+	}
+	if chapter < 1 || chapter > len(s.quran.Chapters) {
+		return nil
+	}
+
+	var refs []verseRef
+	ch := s.quran.Get(chapter)
+	for v := start; v <= end; v++ {
+		if verse := ch.Verse(v); verse != nil && s.selectable(verse) {
+			refs = append(refs, verseRef{ch, verse})
+		}
+	}
+	return refs
+}
+
+func (s *Selector) selectable(v *quran.Verse) bool {
+	return v.Number != 0 && (s.valid == nil || s.valid(v))
+}
+
+// Themes returns the themes which apply to the date in order of preference
+func (s *Selector) Themes(date time.Time) []*Theme {
+	var themes []*Theme
+	for _, t := range s.match(date) {
+		themes = append(themes, t.Theme)
+	}
+	return themes
+}
+
+func (s *Selector) match(date time.Time) []*themed {
+	if len(s.themes) == 0 {
+		return nil
+	}
+	date = date.UTC()
+	h, err := ToHijri(date, HijriOffset)
+	if err != nil {
+		return nil
+	}
+	var matched []*themed
+	for _, t := range s.themes {
+		if t.Matches(h, date.Weekday()) {
+			matched = append(matched, t)
+		}
+	}
+	return matched
+}
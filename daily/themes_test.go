@@ -0,0 +1,87 @@
+package daily
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/asim/reminder/hadith"
+	"github.com/asim/reminder/names"
+	"github.com/asim/reminder/quran"
+)
+
+func TestLoadThemes(t *testing.T) {
+	ThemesFile = filepath.Join(t.TempDir(), "themes.json")
+
+	themes, err := LoadThemes()
+	if err != nil || len(themes) == 0 {
+		t.Fatalf("expected the embedded themes, got %v %v", themes, err)
+	}
+
+	// the curated verses and names exist and start a sentence
+	s := NewSelector(0, 0, names.Load(), quran.Load(), &hadith.Collection{}, func(v *quran.Verse) bool {
+		r := []rune(v.Text)
+		return len(r) > 0 && unicode.IsUpper(r[0])
+	})
+	for _, m := range s.SetThemes(themes) {
+		if !strings.Contains(m, ": hadith ") {
+			t.Errorf("missing %s", m)
+		}
+	}
+}
+
+func TestThemes(t *testing.T) {
+	s := testSelector(1, 10)
+
+	missing := s.SetThemes([]*Theme{
+		{Name: "ramadan", Month: 9, Verses: []string{"2:5-6"}, Names: []int{3}},
+		{Name: "friday", Weekday: "Friday", Verses: []string{"3:1"}, Hadith: []string{"1:7", "9:9"}},
+	})
+	if len(missing) != 1 || missing[0] != "friday: hadith 9:9" {
+		t.Fatalf("expected hadith 9:9 to be missing, got %v", missing)
+	}
+
+	for date, expected := range map[string]string{
+		"2024-03-20": "ramadan", // 10 Ramadan 1445
+		"2024-03-22": "ramadan", // a Friday in Ramadan
+		"2024-04-19": "friday",
+		"2024-04-20": "",
+	} {
+		d, _ := ParseDate(date)
+		for hour := 0; hour < Hours; hour++ {
+			sel, err := s.Select(d, hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// only the daily reminder is themed
+			if hour > 0 {
+				if len(sel.Theme) > 0 {
+					t.Fatalf("%s: expected hour %d not to be themed, got %q", date, hour, sel.Theme)
+				}
+				continue
+			}
+			if sel.Theme != expected {
+				t.Fatalf("%s: expected theme %q, got %q", date, expected, sel.Theme)
+			}
+
+			switch expected {
+			case "ramadan":
+				if sel.VerseKey != "2:5" && sel.VerseKey != "2:6" {
+					t.Fatalf("%s: expected a ramadan verse, got %s", date, sel.VerseKey)
+				}
+				if sel.NameKey != 3 {
+					t.Fatalf("%s: expected name 3, got %d", date, sel.NameKey)
+				}
+				// friday content fills in what ramadan doesn't have
+				if date == "2024-03-22" && sel.HadithKey != "1:7" {
+					t.Fatalf("%s: expected hadith 1:7, got %s", date, sel.HadithKey)
+				}
+			case "friday":
+				if sel.VerseKey != "3:1" || sel.HadithKey != "1:7" {
+					t.Fatalf("%s: expected friday content, got %+v", date, sel)
+				}
+			}
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"embed"
 	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 //go:embed data/*.json
@@ -31,6 +32,10 @@ type Hadith struct {
 	English  string `json:"english"`
 	Arabic   string `json:"arabic"`
 	Chain    string `json:"chain,omitempty"`
+	// Parallel narrations of the same hadith
+	Parallels []*Parallel `json:"parallels,omitempty"`
+	// Quran verses cited e.g 2:255
+	Verses []string `json:"verses,omitempty"`
 	// Legacy fields for API compatibility
 	Info string `json:"info,omitempty"`
 	By   string `json:"by,omitempty"`
@@ -61,6 +66,20 @@ func (c *Collection) Get(book int) *Book {
 	return c.Books[book-1]
 }
 
+// Hadith returns the hadith with the given number in a book
+func (c *Collection) Hadith(book, number int) *Hadith {
+	bk := c.Get(book)
+	if bk == nil {
+		return nil
+	}
+	for _, h := range bk.Hadiths {
+		if h.Number == number {
+			return h
+		}
+	}
+	return nil
+}
+
 func (c *Collection) Index() *Collection {
 	cc := &Collection{
 		Name:   c.Name,
@@ -126,6 +145,13 @@ func Load() *Collection {
 		}
 	}
 
+	// attach parallel narrations if they've been generated
+	if f, err := files.ReadFile("data/parallels.json"); err == nil {
+		if err := attachParallels(f, collection); err != nil {
+			fmt.Println("Failed to load hadith parallels:", err)
+		}
+	}
+
 	return collection
 }
 
@@ -139,7 +165,7 @@ func (b *Book) HTML() string {
 
 	// Hadith entries
 	for _, hadith := range b.Hadiths {
-		hadithKey := fmt.Sprintf("%d:%d", b.Number, hadith.Number)
+		hadithKey := b.Key(hadith)
 		hadithLabel := fmt.Sprintf("%s - Hadith %d", b.Name, hadith.Number)
 		hadithURL := fmt.Sprintf("/hadith/%d#%d", b.Number, hadith.Number)
 
@@ -155,6 +181,31 @@ func (b *Book) HTML() string {
 
 		// English translation
 		data += fmt.Sprintf(`<div class="text-gray-700">%s</div>`, hadith.English)
+
+		// Cited verses
+		if len(hadith.Verses) > 0 {
+			data += `<div class="mt-4 pt-4 border-t border-gray-100 text-sm text-gray-500">Quran: `
+			for i, key := range hadith.Verses {
+				if i > 0 {
+					data += `, `
+				}
+				data += fmt.Sprintf(`<a href="/quran/%s" class="text-blue-600 hover:text-blue-800">%s</a>`, strings.Replace(key, ":", "/", 1), key)
+			}
+			data += `</div>`
+		}
+
+		// Parallel narrations
+		if len(hadith.Parallels) > 0 {
+			data += `<div class="mt-4 pt-4 border-t border-gray-100 text-sm text-gray-500">Parallels: `
+			for i, p := range hadith.Parallels {
+				if i > 0 {
+					data += `, `
+				}
+				data += fmt.Sprintf(`<a href="/hadith/%d#%d" class="text-blue-600 hover:text-blue-800">%d:%d</a>`, p.Book, p.Number, p.Book, p.Number)
+			}
+			data += `</div>`
+		}
+
 		data += `</div>`
 	}
 
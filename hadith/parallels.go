@@ -0,0 +1,202 @@
+package hadith
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// DefaultThreshold is the minimum similarity for two narrations to be
+// considered parallels of each other.
+var DefaultThreshold = 0.5
+
+// shingle size in words
+const shingleSize = 4
+
+// shingles shared by more narrations than this are treated as stock phrases
+// ("Allah's Messenger said") and ignored when looking for candidates
+const maxPostings = 64
+
+// Parallel references a narration in the same or another collection which
+// shares most of its wording with a hadith.
+type Parallel struct {
+	Collection string  `json:"collection"`
+	Book       int     `json:"book"`
+	Number     int     `json:"number"`
+	Score      float64 `json:"score"`
+}
+
+type narration struct {
+	collection string
+	book       int
+	hadith     *Hadith
+	shingles   map[uint64]bool
+}
+
+func parallelKey(collection string, book, number int) string {
+	return fmt.Sprintf("%s:%d:%d", collection, book, number)
+}
+
+// words lowercases the text and splits it on anything that isn't a letter or digit
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// shingles returns the set of hashed k-word sequences in the text
+func shingles(text string, k int) map[uint64]bool {
+	w := words(text)
+	set := make(map[uint64]bool)
+
+	for i := 0; i+k <= len(w); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(w[i:i+k], " ")))
+		set[h.Sum64()] = true
+	}
+
+	return set
+}
+
+// Link runs the similarity pass over the collections and records parallel
+// narrations on each hadith. Narrations are compared as sets of word
+// shingles and linked when their Jaccard similarity reaches threshold.
+// It returns the number of hadith which have at least one parallel.
+func Link(threshold float64, collections ...*Collection) int {
+	var docs []*narration
+	postings := make(map[uint64][]int)
+
+	for _, c := range collections {
+		for _, book := range c.Books {
+			for _, h := range book.Hadiths {
+				h.Parallels = nil
+
+				set := shingles(h.English, shingleSize)
+				if len(set) == 0 {
+					continue
+				}
+
+				id := len(docs)
+				docs = append(docs, &narration{
+					collection: c.Name,
+					book:       book.Number,
+					hadith:     h,
+					shingles:   set,
+				})
+
+				for s := range set {
+					postings[s] = append(postings[s], id)
+				}
+			}
+		}
+	}
+
+	var linked int
+
+	for id, doc := range docs {
+		shared := make(map[int]int)
+
+		for s := range doc.shingles {
+			ids := postings[s]
+			if len(ids) > maxPostings {
+				continue
+			}
+			for _, other := range ids {
+				if other != id {
+					shared[other]++
+				}
+			}
+		}
+
+		for other, count := range shared {
+			od := docs[other]
+			score := float64(count) / float64(len(doc.shingles)+len(od.shingles)-count)
+			if score < threshold {
+				continue
+			}
+
+			doc.hadith.Parallels = append(doc.hadith.Parallels, &Parallel{
+				Collection: od.collection,
+				Book:       od.book,
+				Number:     od.hadith.Number,
+				Score:      float64(int(score*1000)) / 1000,
+			})
+		}
+
+		if len(doc.hadith.Parallels) == 0 {
+			continue
+		}
+
+		sort.Slice(doc.hadith.Parallels, func(i, j int) bool {
+			pi, pj := doc.hadith.Parallels[i], doc.hadith.Parallels[j]
+			if pi.Score != pj.Score {
+				return pi.Score > pj.Score
+			}
+			if pi.Book != pj.Book {
+				return pi.Book < pj.Book
+			}
+			return pi.Number < pj.Number
+		})
+
+		linked++
+	}
+
+	return linked
+}
+
+// SaveParallels writes the parallels recorded on the collections to path
+// so they can be attached at load time without rerunning the pass.
+func SaveParallels(path string, collections ...*Collection) error {
+	links := make(map[string][]*Parallel)
+
+	for _, c := range collections {
+		for _, book := range c.Books {
+			for _, h := range book.Hadiths {
+				if len(h.Parallels) > 0 {
+					links[parallelKey(c.Name, book.Number, h.Number)] = h.Parallels
+				}
+			}
+		}
+	}
+
+	b, err := json.Marshal(links)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b, 0644)
+}
+
+// LoadParallels reads parallels written by SaveParallels and attaches them
+// to the matching hadith in the collections.
+func LoadParallels(path string, collections ...*Collection) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return attachParallels(b, collections...)
+}
+
+func attachParallels(b []byte, collections ...*Collection) error {
+	var links map[string][]*Parallel
+	if err := json.Unmarshal(b, &links); err != nil {
+		return err
+	}
+
+	for _, c := range collections {
+		for _, book := range c.Books {
+			for _, h := range book.Hadiths {
+				if p, ok := links[parallelKey(c.Name, book.Number, h.Number)]; ok {
+					h.Parallels = p
+				}
+			}
+		}
+	}
+
+	return nil
+}
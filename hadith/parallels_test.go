@@ -0,0 +1,68 @@
+package hadith
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func testCollection() *Collection {
+	return &Collection{
+		Name: "test",
+		Books: []*Book{
+			{Number: 1, Hadiths: []*Hadith{
+				{Number: 1, English: "The reward of deeds depends upon the intentions and every person will get the reward according to what he has intended."},
+				{Number: 2, English: "Whoever fasts during Ramadan out of sincere faith and hoping to attain the reward, all his past sins will be forgiven."},
+			}},
+			{Number: 2, Hadiths: []*Hadith{
+				{Number: 3, English: "The reward of deeds depends upon intentions and every person will get the reward according to what he intended."},
+				{Number: 4, English: "The best among you are those who learn the Quran and teach it."},
+			}},
+		},
+	}
+}
+
+func TestLink(t *testing.T) {
+	c := testCollection()
+
+	if linked := Link(DefaultThreshold, c); linked != 2 {
+		t.Fatalf("expected 2 linked hadith, got %d", linked)
+	}
+
+	h := c.Hadith(1, 1)
+	if len(h.Parallels) != 1 {
+		t.Fatalf("expected 1 parallel, got %d", len(h.Parallels))
+	}
+	if p := h.Parallels[0]; p.Book != 2 || p.Number != 3 || p.Collection != "test" {
+		t.Fatalf("unexpected parallel %+v", p)
+	}
+
+	if p := c.Hadith(2, 3).Parallels; len(p) != 1 || p[0].Number != 1 {
+		t.Fatalf("expected link back to hadith 1, got %+v", p)
+	}
+
+	if p := c.Hadith(1, 2).Parallels; len(p) != 0 {
+		t.Fatalf("expected no parallels for hadith 2, got %+v", p)
+	}
+	if p := c.Hadith(2, 4).Parallels; len(p) != 0 {
+		t.Fatalf("expected no parallels for hadith 4, got %+v", p)
+	}
+}
+
+func TestSaveLoadParallels(t *testing.T) {
+	c := testCollection()
+	Link(DefaultThreshold, c)
+
+	path := filepath.Join(t.TempDir(), "parallels.json")
+	if err := SaveParallels(path, c); err != nil {
+		t.Fatal(err)
+	}
+
+	fresh := testCollection()
+	if err := LoadParallels(path, fresh); err != nil {
+		t.Fatal(err)
+	}
+
+	if p := fresh.Hadith(1, 1).Parallels; len(p) != 1 || p[0].Number != 3 {
+		t.Fatalf("expected loaded parallel, got %+v", p)
+	}
+}
@@ -0,0 +1,152 @@
+package hadith
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/asim/reminder/quran"
+)
+
+// words per quoted sequence needed to match a verse
+const quoteSize = 5
+
+// sequences found in more verses than this are formulaic and can't be
+// attributed to a single verse
+const maxQuoteVerses = 3
+
+// explicit references as written in the translation e.g (2.255) or (2:255-256)
+var verseRef = regexp.MustCompile(`\((\d{1,3})[.:](\d{1,3})(?:\s*-\s*(\d{1,3}))?\)`)
+
+// arabicFold maps letter variants to a single form. Alef and hamza are
+// dropped entirely since the Uthmani script used by the Quran data spells
+// them differently from the script hadith are written in.
+var arabicFold = strings.NewReplacer(
+	"ٱ", "", "أ", "", "إ", "", "آ", "", "ا", "",
+	"ء", "", "ؤ", "و", "ئ", "ي",
+	"ى", "ي", "ة", "ه",
+)
+
+// normalizeArabic strips diacritics and Quranic annotation marks
+func normalizeArabic(text string) string {
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 0x064B && r <= 0x065F, r == 0x0670, r >= 0x06D6 && r <= 0x06ED, r == 0x0640:
+			return -1
+		}
+		return r
+	}, text)
+
+	return arabicFold.Replace(text)
+}
+
+func arabicWords(text string) []string {
+	return strings.Fields(normalizeArabic(text))
+}
+
+// Key returns the bookmark style key for a hadith in a book e.g 2:13
+func (b *Book) Key(h *Hadith) string {
+	return fmt.Sprintf("%d:%d", b.Number, h.Number)
+}
+
+// LinkVerses detects verses of the Quran which each hadith quotes, either
+// by an explicit reference in the translation or by the Arabic text of the
+// verse appearing in the hadith. The verses are recorded on the hadith and
+// the hadith on each verse. It returns the number of hadith linked.
+func (c *Collection) LinkVerses(q *quran.Quran) int {
+	verses := make(map[string]*quran.Verse)
+	quotes := make(map[string][]string)
+
+	for _, ch := range q.Chapters {
+		for _, v := range ch.Verses {
+			if v.Number == 0 {
+				continue
+			}
+
+			key := fmt.Sprintf("%d:%d", v.Chapter, v.Number)
+			verses[key] = v
+			v.Hadith = nil
+
+			w := arabicWords(v.Arabic)
+			seen := make(map[string]bool)
+
+			for i := 0; i+quoteSize <= len(w); i++ {
+				seq := strings.Join(w[i:i+quoteSize], " ")
+				if seen[seq] {
+					continue
+				}
+				seen[seq] = true
+				quotes[seq] = append(quotes[seq], key)
+			}
+		}
+	}
+
+	var linked int
+
+	for _, book := range c.Books {
+		for _, h := range book.Hadiths {
+			found := make(map[string]bool)
+
+			// explicit references
+			for _, m := range verseRef.FindAllStringSubmatch(h.English, -1) {
+				ch, _ := strconv.Atoi(m[1])
+				start, _ := strconv.Atoi(m[2])
+				end := start
+				if len(m[3]) > 0 {
+					end, _ = strconv.Atoi(m[3])
+				}
+				// guard against long or malformed ranges
+				if end < start || end-start > 20 {
+					end = start
+				}
+				for ve := start; ve <= end; ve++ {
+					key := fmt.Sprintf("%d:%d", ch, ve)
+					if _, ok := verses[key]; ok {
+						found[key] = true
+					}
+				}
+			}
+
+			// embedded quotations
+			w := arabicWords(h.Arabic)
+			for i := 0; i+quoteSize <= len(w); i++ {
+				keys := quotes[strings.Join(w[i:i+quoteSize], " ")]
+				if len(keys) == 0 || len(keys) > maxQuoteVerses {
+					continue
+				}
+				for _, key := range keys {
+					found[key] = true
+				}
+			}
+
+			h.Verses = nil
+
+			if len(found) == 0 {
+				continue
+			}
+
+			for key := range found {
+				h.Verses = append(h.Verses, key)
+			}
+
+			sort.Slice(h.Verses, func(i, j int) bool {
+				vi, vj := verses[h.Verses[i]], verses[h.Verses[j]]
+				if vi.Chapter != vj.Chapter {
+					return vi.Chapter < vj.Chapter
+				}
+				return vi.Number < vj.Number
+			})
+
+			for _, key := range h.Verses {
+				v := verses[key]
+				v.Hadith = append(v.Hadith, book.Key(h))
+			}
+
+			linked++
+		}
+	}
+
+	return linked
+}
@@ -0,0 +1,47 @@
+package hadith
+
+import (
+	"testing"
+
+	"github.com/asim/reminder/quran"
+)
+
+func TestLinkVerses(t *testing.T) {
+	q := &quran.Quran{Chapters: []*quran.Chapter{
+		{Number: 112, Verses: []*quran.Verse{
+			{Chapter: 112, Number: 1, Arabic: "قُلۡ هُوَ ٱللَّهُ أَحَدٌ"},
+			{Chapter: 112, Number: 2, Arabic: "ٱللَّهُ ٱلصَّمَدُ"},
+		}},
+		{Number: 2, Verses: []*quran.Verse{
+			{Chapter: 2, Number: 0, Arabic: quran.Bismillah},
+			{Chapter: 2, Number: 1, Arabic: "الٓمٓ"},
+			{Chapter: 2, Number: 2, Arabic: "ذَٰلِكَ ٱلۡكِتَٰبُ لَا رَيۡبَۛ فِيهِۛ هُدٗى لِّلۡمُتَّقِينَ"},
+		}},
+	}}
+
+	c := &Collection{Books: []*Book{
+		{Number: 1, Hadiths: []*Hadith{
+			{Number: 1, English: "Say: 'He is Allah, (the) One.' (112.1-2)"},
+			{Number: 2, Arabic: "قال رسول الله ذلك الكتاب لا ريب فيه هدى للمتقين"},
+			{Number: 3, English: "The reward of deeds depends upon the intentions (999.1)"},
+		}},
+	}}
+
+	if linked := c.LinkVerses(q); linked != 2 {
+		t.Fatalf("expected 2 linked hadith, got %d", linked)
+	}
+
+	if v := c.Hadith(1, 1).Verses; len(v) != 2 || v[0] != "112:1" || v[1] != "112:2" {
+		t.Fatalf("expected explicit references 112:1 and 112:2, got %v", v)
+	}
+	if v := c.Hadith(1, 2).Verses; len(v) != 1 || v[0] != "2:2" {
+		t.Fatalf("expected quotation of 2:2, got %v", v)
+	}
+	if v := c.Hadith(1, 3).Verses; len(v) != 0 {
+		t.Fatalf("expected no verses, got %v", v)
+	}
+
+	if h := q.Get(2).Verse(2).Hadith; len(h) != 1 || h[0] != "1:2" {
+		t.Fatalf("expected reverse link to 1:2, got %v", h)
+	}
+}
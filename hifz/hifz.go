@@ -0,0 +1,307 @@
+package hifz
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	mrand "math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asim/reminder/api"
+	"github.com/asim/reminder/quran"
+)
+
+// Review is a self graded recitation of a range
+type Review struct {
+	Time  time.Time `json:"time"`
+	Grade int       `json:"grade"`
+}
+
+// Item is a range of verses memorised by a user
+type Item struct {
+	ID      string `json:"id"`
+	User    string `json:"user"`
+	Chapter int    `json:"chapter"`
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	Schedule
+	Reviews []Review  `json:"reviews,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// Key describes the range e.g 2:255-257
+func (i *Item) Key() string {
+	if i.Start == i.End {
+		return fmt.Sprintf("%d:%d", i.Chapter, i.Start)
+	}
+	return fmt.Sprintf("%d:%d-%d", i.Chapter, i.Start, i.End)
+}
+
+// ParseRange parses a range such as 2:255 or 2:255-257
+func ParseRange(key string) (chapter, start, end int, err error) {
+	ch, verses, ok := strings.Cut(key, ":")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid range %q", key)
+	}
+	if _, err := fmt.Sscanf(ch, "%d", &chapter); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid range %q", key)
+	}
+	from, to, isRange := strings.Cut(verses, "-")
+	if _, err := fmt.Sscanf(from, "%d", &start); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid range %q", key)
+	}
+	end = start
+	if isRange {
+		if _, err := fmt.Sscanf(to, "%d", &end); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid range %q", key)
+		}
+	}
+	return chapter, start, end, nil
+}
+
+// verses returns the verses of the range
+func verses(q *quran.Quran, chapter, start, end int) ([]*quran.Verse, error) {
+	if chapter < 1 || chapter > len(q.Chapters) {
+		return nil, fmt.Errorf("invalid chapter %d", chapter)
+	}
+	ch := q.Get(chapter)
+	if start < 1 || end < start || ch.Verse(end) == nil {
+		return nil, fmt.Errorf("invalid verses %d-%d of chapter %d", start, end, chapter)
+	}
+	var result []*quran.Verse
+	for v := start; v <= end; v++ {
+		result = append(result, ch.Verse(v))
+	}
+	return result, nil
+}
+
+var hifzFile = api.ReminderPath("hifz.json")
+var mtx sync.RWMutex
+var items = map[string]*Item{}
+
+func Load() error {
+	f, err := os.Open(hifzFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	mtx.Lock()
+	defer mtx.Unlock()
+	return json.NewDecoder(f).Decode(&items)
+}
+
+// save writes the items, the caller must hold the lock
+func save() error {
+	b, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	return api.WriteFile(hifzFile, b, 0600)
+}
+
+func copyItem(i *Item) *Item {
+	c := *i
+	c.Reviews = append([]Review{}, i.Reviews...)
+	return &c
+}
+
+// Add marks a range as memorised by the user
+func Add(q *quran.Quran, user string, chapter, start, end int, now time.Time) (*Item, error) {
+	if _, err := verses(q, chapter, start, end); err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 8)
+	rand.Read(b)
+
+	item := &Item{
+		ID:       hex.EncodeToString(b),
+		User:     user,
+		Chapter:  chapter,
+		Start:    start,
+		End:      end,
+		Schedule: NewSchedule(now),
+		Created:  now,
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	items[item.ID] = item
+	return copyItem(item), save()
+}
+
+// Get returns an item
+func Get(id string) (*Item, bool) {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	i, ok := items[id]
+	if !ok {
+		return nil, false
+	}
+	return copyItem(i), true
+}
+
+// List returns the user's items in Quran order
+func List(user string) []*Item {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	result := []*Item{}
+	for _, i := range items {
+		if i.User == user {
+			result = append(result, copyItem(i))
+		}
+	}
+	sort.Slice(result, func(a, b int) bool {
+		if result[a].Chapter != result[b].Chapter {
+			return result[a].Chapter < result[b].Chapter
+		}
+		return result[a].Start < result[b].Start
+	})
+	return result
+}
+
+// Due returns the user's items due for review on the day, most overdue first
+func Due(user string, day time.Time) []*Item {
+	date := day.Format("2006-01-02")
+	var due []*Item
+	for _, i := range List(user) {
+		if i.Due <= date {
+			due = append(due, i)
+		}
+	}
+	sort.SliceStable(due, func(a, b int) bool {
+		return due[a].Due < due[b].Due
+	})
+	return due
+}
+
+// Grade records a review of an item and reschedules it
+func Grade(id string, grade int, now time.Time) (*Item, error) {
+	mtx.Lock()
+	defer mtx.Unlock()
+	i, ok := items[id]
+	if !ok {
+		return nil, fmt.Errorf("item not found")
+	}
+	s, err := i.Schedule.Review(grade, now)
+	if err != nil {
+		return nil, err
+	}
+	i.Schedule = s
+	i.Reviews = append(i.Reviews, Review{Time: now, Grade: grade})
+	return copyItem(i), save()
+}
+
+// Delete removes an item
+func Delete(id string) error {
+	mtx.Lock()
+	defer mtx.Unlock()
+	delete(items, id)
+	return save()
+}
+
+// Word is a word of a verse
+type Word struct {
+	Arabic          string `json:"arabic"`
+	Transliteration string `json:"transliteration"`
+	English         string `json:"english"`
+}
+
+// Drill asks for the word which follows a prompt
+type Drill struct {
+	Verse string `json:"verse"`
+	// Preceding words in Arabic
+	Prompt string `json:"prompt"`
+	Answer Word   `json:"answer"`
+	// Answer and distractors from the same range in random order
+	Choices []Word `json:"choices"`
+}
+
+// How many preceding words to show in a drill
+const promptWords = 5
+
+// Drills generates fill the next word drills for a range. The same seed
+// gives the same drills.
+func Drills(q *quran.Quran, chapter, start, end, count int, seed int64) ([]*Drill, error) {
+	vs, err := verses(q, chapter, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	type position struct {
+		verse *quran.Verse
+		word  int
+	}
+
+	// every word with at least one word before it can be asked
+	var positions []position
+	var words []Word
+	seen := map[string]bool{}
+	for _, v := range vs {
+		for i, w := range v.Words {
+			if i > 0 {
+				positions = append(positions, position{v, i})
+			}
+			if !seen[w.Arabic] {
+				seen[w.Arabic] = true
+				words = append(words, Word{w.Arabic, w.Transliteration, w.English})
+			}
+		}
+	}
+	if len(positions) == 0 {
+		return nil, fmt.Errorf("no words to drill in %d:%d-%d", chapter, start, end)
+	}
+
+	rnd := mrand.New(mrand.NewSource(seed))
+	rnd.Shuffle(len(positions), func(i, j int) {
+		positions[i], positions[j] = positions[j], positions[i]
+	})
+	if count <= 0 || count > len(positions) {
+		count = len(positions)
+	}
+
+	var drills []*Drill
+	for _, p := range positions[:count] {
+		from := p.word - promptWords
+		if from < 0 {
+			from = 0
+		}
+		var prompt []string
+		for _, w := range p.verse.Words[from:p.word] {
+			prompt = append(prompt, w.Arabic)
+		}
+
+		w := p.verse.Words[p.word]
+		answer := Word{w.Arabic, w.Transliteration, w.English}
+
+		choices := []Word{answer}
+		for _, i := range rnd.Perm(len(words)) {
+			if len(choices) == 4 {
+				break
+			}
+			if words[i].Arabic != answer.Arabic {
+				choices = append(choices, words[i])
+			}
+		}
+		rnd.Shuffle(len(choices), func(i, j int) {
+			choices[i], choices[j] = choices[j], choices[i]
+		})
+
+		drills = append(drills, &Drill{
+			Verse:   fmt.Sprintf("%d:%d", p.verse.Chapter, p.verse.Number),
+			Prompt:  strings.Join(prompt, " "),
+			Answer:  answer,
+			Choices: choices,
+		})
+	}
+
+	return drills, nil
+}
@@ -0,0 +1,82 @@
+package hifz
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/asim/reminder/quran"
+)
+
+func TestSchedule(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewSchedule(day)
+	if s.Due != "2024-01-02" {
+		t.Fatalf("expected first review the next day, got %s", s.Due)
+	}
+
+	// grade 4 leaves the easiness unchanged: intervals 1, 6, 15, 38
+	for _, want := range []int{1, 6, 15, 38} {
+		var err error
+		if s, err = s.Review(4, day); err != nil {
+			t.Fatal(err)
+		}
+		if s.Interval != want || s.Easiness != DefaultEasiness {
+			t.Fatalf("expected interval %d, got %+v", want, s)
+		}
+	}
+	if s.Due != "2024-02-08" {
+		t.Fatalf("expected due in 38 days, got %s", s.Due)
+	}
+
+	// a failed review starts again without changing the easiness
+	s, _ = s.Review(1, day)
+	if s.Interval != 1 || s.Repetitions != 0 || s.Easiness != DefaultEasiness {
+		t.Fatalf("expected reset, got %+v", s)
+	}
+
+	// hard recalls lower the easiness to the minimum
+	for i := 0; i < 10; i++ {
+		s, _ = s.Review(3, day)
+	}
+	if s.Easiness != MinEasiness {
+		t.Fatalf("expected minimum easiness, got %v", s.Easiness)
+	}
+
+	if _, err := s.Review(6, day); err == nil {
+		t.Fatal("expected invalid grade to be rejected")
+	}
+}
+
+func TestDrills(t *testing.T) {
+	q := quran.Load()
+
+	drills, err := Drills(q, 112, 1, 4, 5, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drills) != 5 {
+		t.Fatalf("expected 5 drills, got %d", len(drills))
+	}
+	for _, d := range drills {
+		if len(d.Prompt) == 0 || len(d.Choices) < 2 {
+			t.Fatalf("unexpected drill %+v", d)
+		}
+		var found bool
+		for _, c := range d.Choices {
+			found = found || c == d.Answer
+		}
+		if !found {
+			t.Fatalf("answer missing from choices %+v", d)
+		}
+	}
+
+	again, _ := Drills(q, 112, 1, 4, 5, 42)
+	if !reflect.DeepEqual(drills, again) {
+		t.Fatal("expected the same seed to give the same drills")
+	}
+
+	if _, err := Drills(q, 112, 1, 9, 5, 42); err == nil {
+		t.Fatal("expected invalid range to be rejected")
+	}
+}
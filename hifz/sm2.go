@@ -0,0 +1,76 @@
+// Package hifz tracks memorisation of the Quran with spaced repetition
+package hifz
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Initial easiness factor of a new item
+const DefaultEasiness = 2.5
+
+// Lowest easiness factor allowed by SM-2
+const MinEasiness = 1.3
+
+// Grades of recall from 0 (blackout) to 5 (perfect). Grades below
+// PassGrade restart the repetitions.
+const (
+	MinGrade  = 0
+	MaxGrade  = 5
+	PassGrade = 3
+)
+
+// Schedule is the SM-2 state of a memorised range
+type Schedule struct {
+	Easiness    float64 `json:"easiness"`
+	Interval    int     `json:"interval"`
+	Repetitions int     `json:"repetitions"`
+	// Date the next review is due as YYYY-MM-DD
+	Due string `json:"due"`
+}
+
+// NewSchedule returns the schedule of a range memorised on the day,
+// first due for review the next day
+func NewSchedule(day time.Time) Schedule {
+	return Schedule{
+		Easiness: DefaultEasiness,
+		Interval: 1,
+		Due:      day.AddDate(0, 0, 1).Format("2006-01-02"),
+	}
+}
+
+// Review returns the schedule after a review graded on the day
+// following the SM-2 algorithm
+func (s Schedule) Review(grade int, day time.Time) (Schedule, error) {
+	if grade < MinGrade || grade > MaxGrade {
+		return s, fmt.Errorf("grade must be between %d and %d", MinGrade, MaxGrade)
+	}
+
+	// failed recall starts the repetitions again leaving the easiness
+	if grade < PassGrade {
+		s.Repetitions = 0
+		s.Interval = 1
+		s.Due = day.AddDate(0, 0, s.Interval).Format("2006-01-02")
+		return s, nil
+	}
+
+	s.Repetitions++
+	switch s.Repetitions {
+	case 1:
+		s.Interval = 1
+	case 2:
+		s.Interval = 6
+	default:
+		s.Interval = int(math.Round(float64(s.Interval) * s.Easiness))
+	}
+
+	q := float64(MaxGrade - grade)
+	s.Easiness += 0.1 - q*(0.08+q*0.02)
+	if s.Easiness < MinEasiness {
+		s.Easiness = MinEasiness
+	}
+
+	s.Due = day.AddDate(0, 0, s.Interval).Format("2006-01-02")
+	return s, nil
+}
@@ -0,0 +1,143 @@
+// Package ics writes iCalendar (RFC 5545) feeds which calendar apps can
+// subscribe to
+package ics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Event is an entry in a calendar
+type Event struct {
+	// Globally unique id, stable across refreshes of the feed
+	UID         string
+	Summary     string
+	Description string
+	URL         string
+	Start       time.Time
+	// End of the event, exclusive. Defaults to the start, or the next day
+	// for all day events.
+	End time.Time
+	// The event is for the whole date of the start
+	AllDay bool
+}
+
+// Calendar is a feed of events
+type Calendar struct {
+	Name        string
+	Description string
+	// How often apps should refresh the feed, defaults to a day
+	Refresh time.Duration
+	Events  []*Event
+	// Time the feed was generated, defaults to now
+	Updated time.Time
+}
+
+// ContentType of a calendar
+const ContentType = "text/calendar; charset=utf-8"
+
+// maximum length of a line in octets, excluding the line break
+const lineLength = 75
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// Write writes the calendar in iCalendar format
+func (c *Calendar) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	updated := c.Updated
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+	refresh := c.Refresh
+	if refresh <= 0 {
+		refresh = 24 * time.Hour
+	}
+
+	line := func(name, value string) {
+		fold(bw, name+":"+value)
+	}
+	text := func(name, value string) {
+		if len(value) > 0 {
+			line(name, escaper.Replace(value))
+		}
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//Reminder//Reminder//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	text("X-WR-CALNAME", c.Name)
+	text("X-WR-CALDESC", c.Description)
+	line("REFRESH-INTERVAL;VALUE=DURATION", duration(refresh))
+	line("X-PUBLISHED-TTL", duration(refresh))
+
+	for _, e := range c.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", e.UID)
+		line("DTSTAMP", timestamp(updated))
+		if e.AllDay {
+			end := e.End
+			if end.IsZero() {
+				end = e.Start.AddDate(0, 0, 1)
+			}
+			line("DTSTART;VALUE=DATE", e.Start.Format("20060102"))
+			line("DTEND;VALUE=DATE", end.Format("20060102"))
+		} else {
+			end := e.End
+			if end.IsZero() {
+				end = e.Start
+			}
+			line("DTSTART", timestamp(e.Start))
+			line("DTEND", timestamp(end))
+		}
+		text("SUMMARY", e.Summary)
+		text("DESCRIPTION", e.Description)
+		if len(e.URL) > 0 {
+			line("URL", e.URL)
+		}
+		line("TRANSP", "TRANSPARENT")
+		line("END", "VEVENT")
+	}
+
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+// fold splits the line into lines of at most 75 octets without breaking
+// utf-8 characters, continuing each with a space
+func fold(w *bufio.Writer, s string) {
+	limit := lineLength
+	for len(s) > limit {
+		i := limit
+		// back up to the start of a character
+		for i > 0 && s[i]&0xC0 == 0x80 {
+			i--
+		}
+		w.WriteString(s[:i])
+		w.WriteString("\r\n ")
+		s = s[i:]
+		// the leading space counts towards the next line
+		limit = lineLength - 1
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+func duration(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("P%dD", d/(24*time.Hour))
+	}
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("PT%dH", d/time.Hour)
+	}
+	return fmt.Sprintf("PT%dM", int(d.Minutes()))
+}
@@ -0,0 +1,56 @@
+package ics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestWrite(t *testing.T) {
+	updated := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	fajr := time.Date(2024, 3, 11, 5, 2, 0, 0, time.UTC)
+
+	c := &Calendar{
+		Name:    "Reminder",
+		Updated: updated,
+		Events: []*Event{
+			{UID: "ramadan-1445@reminder", Summary: "Start of Ramadan", Start: time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC), AllDay: true},
+			{UID: "fajr-2024-03-11@reminder", Summary: "Fajr", Start: fajr, End: fajr.Add(15 * time.Minute)},
+			{UID: "daily-2024-03-11@reminder", Summary: "Daily reminder", Description: strings.Repeat("Verily, with hardship; comes ease ۝ ", 5), Start: fajr, AllDay: true},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := c.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, expected := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"X-WR-CALNAME:Reminder\r\n",
+		"REFRESH-INTERVAL;VALUE=DURATION:P1D\r\n",
+		"DTSTAMP:20240301T120000Z\r\n",
+		"DTSTART;VALUE=DATE:20240311\r\nDTEND;VALUE=DATE:20240312\r\n",
+		"DTSTART:20240311T050200Z\r\nDTEND:20240311T051700Z\r\n",
+		`Verily\, with hardship\; comes ease`,
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected %q in:\n%s", expected, out)
+		}
+	}
+
+	// lines are folded at 75 octets on character boundaries
+	for _, l := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(l) > 75 || !utf8.ValidString(l) {
+			t.Fatalf("invalid line %q", l)
+		}
+	}
+	unfolded := strings.ReplaceAll(out, "\r\n ", "")
+	if !strings.Contains(unfolded, "DESCRIPTION:"+strings.Repeat(`Verily\, with hardship\; comes ease ۝ `, 5)) {
+		t.Fatalf("expected the description to unfold, got:\n%s", unfolded)
+	}
+}
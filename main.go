@@ -1,44 +1,57 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
-	"math/rand"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode"
 
+	"github.com/asim/reminder/anki"
 	"github.com/asim/reminder/api"
 	"github.com/asim/reminder/app"
 	"github.com/asim/reminder/daily"
 	"github.com/asim/reminder/hadith"
+	"github.com/asim/reminder/hifz"
+	"github.com/asim/reminder/ics"
 	"github.com/asim/reminder/names"
+	"github.com/asim/reminder/plans"
+	"github.com/asim/reminder/prayer"
+	"github.com/asim/reminder/quiz"
 	"github.com/asim/reminder/quran"
+	"github.com/asim/reminder/scheduler"
 	"github.com/asim/reminder/search"
 	"github.com/google/uuid"
 )
 
 var (
-	IndexFlag  = flag.Bool("index", false, "Index data for search. Stored at $HOME/reminder.idx")
-	ExportFlag = flag.Bool("export", false, "Export the index data to $HOME/reminder.idx.gob.gz")
-	ImportFlag = flag.Bool("import", false, "Import the index data from $HOME/reminder.idx.gob.gz")
-	ServerFlag = flag.Bool("serve", false, "Run the server")
-	EnvFlag    = flag.String("env", "dev", "Set the environment")
-	WebFlag    = flag.Bool("web", false, "Without this flag, the lite version will be served")
+	IndexFlag     = flag.Bool("index", false, "Index data for search. Stored at $HOME/reminder.idx")
+	ExportFlag    = flag.Bool("export", false, "Export the index data to $HOME/reminder.idx.gob.gz")
+	ImportFlag    = flag.Bool("import", false, "Import the index data from $HOME/reminder.idx.gob.gz")
+	ServerFlag    = flag.Bool("serve", false, "Run the server")
+	EnvFlag       = flag.String("env", "dev", "Set the environment")
+	WebFlag       = flag.Bool("web", false, "Without this flag, the lite version will be served")
+	ParallelsFlag = flag.Bool("parallels", false, "Link parallel hadith narrations. Stored at $HOME/.reminder/parallels.json")
+	AnkiFlag      = flag.String("anki", "", "Export an Anki deck of names, words or verses to the current directory")
+	RangeFlag     = flag.String("range", "", "Chapter or verses for the Anki deck e.g 2:255-257")
+	BackfillFlag  = flag.String("backfill", "", "Archive computed daily reminders for missing dates from the given date e.g 2024-01-01")
 )
 
 var mtx sync.RWMutex
 var history = map[string][]string{}
-var dailyName, dailyVerse, dailyHadith, dailyMessage string
-var links = map[string]string{}
-var dailyUpdated = time.Time{}
+
+// latest is the hourly reminder, swapped atomically so readers never wait
+var latest atomic.Pointer[snapshot]
 var reminderDir = api.ReminderDir
 var lastPushDateFile = api.ReminderPath("last_push_date.txt")
 var lastPushDate = loadLastPushDate()
@@ -79,14 +92,13 @@ func isAPIClient(r *http.Request) bool {
 
 func registerLiteRoutes(q *quran.Quran, n *names.Names, b *hadith.Collection, a *api.Api) {
 	http.HandleFunc("/home", func(w http.ResponseWriter, r *http.Request) {
-		mtx.RLock()
-		verseLink := links["verse"]
-		hadithLink := links["hadith"]
-		nameLink := links["name"]
-		verse := dailyVerse
-		hadith := dailyHadith
-		name := dailyName
-		mtx.RUnlock()
+		cur := current()
+		verseLink := cur.links["verse"]
+		hadithLink := cur.links["hadith"]
+		nameLink := cur.links["name"]
+		verse := cur.verse
+		hadith := cur.hadith
+		name := cur.name
 
 		// Populate Index template with actual data
 		indexContent := strings.ReplaceAll(app.Index, "{verse_link}", verseLink)
@@ -120,72 +132,86 @@ func registerLiteRoutes(q *quran.Quran, n *names.Names, b *hadith.Collection, a
   <div id="names-list" class="space-y-3"></div>
 </div>
 
+<div id="bookmarks-sync" class="mb-8 p-4 bg-white border border-gray-200 rounded-lg text-sm text-gray-700">
+  <h2 class="text-lg font-semibold mb-2">Sync</h2>
+  <p id="sync-status" class="mb-3 text-gray-500">Bookmarks are saved in this browser only.</p>
+  <div class="flex flex-wrap gap-2">
+    <button onclick="newSyncCode()" class="px-3 py-1 bg-black text-white rounded hover:bg-gray-800 transition-colors">Create sync code</button>
+    <input id="sync-code" placeholder="Enter sync code" class="px-3 py-1 border border-gray-300 rounded">
+    <button onclick="useSyncCode()" class="px-3 py-1 border border-gray-300 rounded hover:bg-gray-100 transition-colors">Use code</button>
+  </div>
+</div>
+
 <script>
-  function loadBookmarks() {
-    const bookmarks = JSON.parse(localStorage.getItem('reminder_bookmarks') || '{"quran":{},"hadith":{},"names":{}}');
-    
-    // Quran bookmarks
-    const quranList = document.getElementById('quran-list');
-    const quranKeys = Object.keys(bookmarks.quran || {});
-    if (quranKeys.length > 0) {
-      quranList.innerHTML = quranKeys.map(key => {
-        const bookmark = bookmarks.quran[key];
-        return ` + "`" + `
-        <div class="flex items-center justify-between p-4 bg-white border border-gray-200 rounded-lg hover:border-gray-400 transition-colors">
-          <a href="${bookmark.url}" class="flex-grow text-blue-600 hover:text-blue-800">${bookmark.label}</a>
-          <button onclick="removeBookmark('quran', '${key}')" class="px-3 py-1 bg-red-500 text-white rounded hover:bg-red-600 transition-colors text-sm">Remove</button>
-        </div>
-      ` + "`" + `;
-      }).join('');
-    } else {
-      quranList.innerHTML = '<p class="text-gray-500">No bookmarks yet</p>';
-    }
-    
-    // Hadith bookmarks
-    const hadithList = document.getElementById('hadith-list');
-    const hadithKeys = Object.keys(bookmarks.hadith || {});
-    if (hadithKeys.length > 0) {
-      hadithList.innerHTML = hadithKeys.map(key => {
-        const bookmark = bookmarks.hadith[key];
-        return ` + "`" + `
-        <div class="flex items-center justify-between p-4 bg-white border border-gray-200 rounded-lg hover:border-gray-400 transition-colors">
-          <a href="${bookmark.url}" class="flex-grow text-blue-600 hover:text-blue-800">${bookmark.label}</a>
-          <button onclick="removeBookmark('hadith', '${key}')" class="px-3 py-1 bg-red-500 text-white rounded hover:bg-red-600 transition-colors text-sm">Remove</button>
-        </div>
-      ` + "`" + `;
-      }).join('');
-    } else {
-      hadithList.innerHTML = '<p class="text-gray-500">No bookmarks yet</p>';
+  async function refreshBookmarks() {
+    const synced = await syncBookmarks();
+    const status = document.getElementById('sync-status');
+    if (synced) {
+      const code = getSyncCode();
+      status.textContent = code ? 'Synced with code ' + code + '. Enter it on another device to share bookmarks.' : 'Synced with your account.';
     }
-    
-    // Names bookmarks
-    const namesList = document.getElementById('names-list');
-    const namesKeys = Object.keys(bookmarks.names || {});
-    if (namesKeys.length > 0) {
-      namesList.innerHTML = namesKeys.map(key => {
-        const bookmark = bookmarks.names[key];
-        return ` + "`" + `
-        <div class="flex items-center justify-between p-4 bg-white border border-gray-200 rounded-lg hover:border-gray-400 transition-colors">
-          <a href="${bookmark.url}" class="flex-grow text-blue-600 hover:text-blue-800">${bookmark.label}</a>
-          <button onclick="removeBookmark('names', '${key}')" class="px-3 py-1 bg-red-500 text-white rounded hover:bg-red-600 transition-colors text-sm">Remove</button>
-        </div>
-      ` + "`" + `;
-      }).join('');
-    } else {
-      namesList.innerHTML = '<p class="text-gray-500">No bookmarks yet</p>';
+    loadBookmarks(synced || getBookmarks());
+  }
+
+  async function newSyncCode() {
+    await createSyncCode();
+    refreshBookmarks();
+  }
+
+  function useSyncCode() {
+    const code = document.getElementById('sync-code').value.trim();
+    if (code) {
+      setSyncCode(code);
+      refreshBookmarks();
     }
   }
+
+  // Build each list from elements so labels and links from a shared sync
+  // code are treated as text, never markup
+  function loadBookmarks(bookmarks) {
+    ['quran', 'hadith', 'names'].forEach(type => {
+      const list = document.getElementById(type + '-list');
+      const entries = bookmarks[type] || {};
+      const keys = Object.keys(entries);
+      list.replaceChildren();
+
+      if (keys.length === 0) {
+        const empty = document.createElement('p');
+        empty.className = 'text-gray-500';
+        empty.textContent = 'No bookmarks yet';
+        list.appendChild(empty);
+        return;
+      }
+
+      keys.forEach(key => {
+        const bookmark = entries[key];
+        const row = document.createElement('div');
+        row.className = 'flex items-center justify-between p-4 bg-white border border-gray-200 rounded-lg hover:border-gray-400 transition-colors';
+
+        const link = document.createElement('a');
+        link.className = 'flex-grow text-blue-600 hover:text-blue-800';
+        link.textContent = bookmark.label;
+        if (typeof bookmark.url === 'string' && bookmark.url.startsWith('/' + type + '/')) {
+          link.href = bookmark.url;
+        }
+
+        const remove = document.createElement('button');
+        remove.className = 'px-3 py-1 bg-red-500 text-white rounded hover:bg-red-600 transition-colors text-sm';
+        remove.textContent = 'Remove';
+        remove.addEventListener('click', () => removeBookmark(type, key));
+
+        row.append(link, remove);
+        list.appendChild(row);
+      });
+    });
+  }
   
   function removeBookmark(type, key) {
-    const bookmarks = JSON.parse(localStorage.getItem('reminder_bookmarks') || '{"quran":{},"hadith":{},"names":{}}');
-    if (bookmarks[type] && bookmarks[type][key]) {
-      delete bookmarks[type][key];
-      localStorage.setItem('reminder_bookmarks', JSON.stringify(bookmarks));
-      loadBookmarks();
-    }
+    deleteBookmark(type, key);
+    loadBookmarks(getBookmarks());
   }
   
-  loadBookmarks();
+  refreshBookmarks();
 </script>
 `
 
@@ -240,13 +266,8 @@ func registerLiteRoutes(q *quran.Quran, n *names.Names, b *hadith.Collection, a
 <a href="%s" class="block p-4 bg-white border border-gray-200 rounded-lg hover:border-gray-400 transition-colors mb-4">%s</a>
 <p class="text-sm text-gray-500 mt-4">Updated %s</p>
 `
-		mtx.RLock()
-		verseLink := links["verse"]
-		hadithLink := links["hadith"]
-		nameLink := links["name"]
-
-		data := fmt.Sprintf(template, verseLink, dailyVerse, hadithLink, dailyHadith, nameLink, dailyName, dailyUpdated.Format(time.RFC3339))
-		mtx.RUnlock()
+		cur := current()
+		data := fmt.Sprintf(template, cur.links["verse"], cur.verse, cur.links["hadith"], cur.hadith, cur.links["name"], cur.name, cur.updated.Format(time.RFC3339))
 
 		html := app.RenderHTML("Daily Reminder", "Daily reminder from the quran, hadith and names of Allah", data)
 		w.Write([]byte(html))
@@ -417,6 +438,10 @@ func loadDailyIndex() map[string]interface{} {
 }
 
 func saveDaily(date string, data map[string]interface{}) {
+	saveDailies(map[string]map[string]interface{}{date: data})
+}
+
+func saveDailies(entries map[string]map[string]interface{}) {
 	// Save to daily.json
 	dailyFile := api.ReminderPath("daily.json")
 	var allDaily map[string]interface{}
@@ -426,7 +451,9 @@ func saveDaily(date string, data map[string]interface{}) {
 	if allDaily == nil {
 		allDaily = make(map[string]interface{})
 	}
-	allDaily[date] = data
+	for date, data := range entries {
+		allDaily[date] = data
+	}
 	b, _ := json.MarshalIndent(allDaily, "", "  ")
 	_ = os.MkdirAll(api.ReminderDir, 0700)
 	_ = os.WriteFile(dailyFile, b, 0644)
@@ -458,11 +485,56 @@ func loadHourlyReminders(date string) []interface{} {
 	return hourlyReminders
 }
 
+// defaultMessage is used until the LLM message is ready or if it fails
+const defaultMessage = "In the Name of Allah—the Most Beneficent, Most Merciful"
+
+// messageTimeout bounds generating a message so a slow LLM gives up with
+// the default rather than hanging
+const messageTimeout = 2 * time.Minute
+
+// slotMessages are the messages generated by selection slot, so the
+// midnight refresh and the daily archive, which share the selection for
+// hour 0, only ask the LLM once
+var slotMessagesMtx sync.Mutex
+var slotMessages = map[int64]*slotMessage{}
+
+// slotMessage is a message being generated, done is closed once it's set
+type slotMessage struct {
+	done    chan struct{}
+	message string
+}
+
+// messageFor returns the message for the content of the slot, generating
+// it once with a timeout or waiting on the generation in progress
+func messageFor(slot int64, verse, hadith, name string) string {
+	slotMessagesMtx.Lock()
+	m, ok := slotMessages[slot]
+	if ok {
+		slotMessagesMtx.Unlock()
+		<-m.done
+		return m.message
+	}
+	m = &slotMessage{done: make(chan struct{})}
+	slotMessages[slot] = m
+	// a day of slots is enough to share the midnight message
+	for s := range slotMessages {
+		if s < slot-daily.Hours {
+			delete(slotMessages, s)
+		}
+	}
+	slotMessagesMtx.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), messageTimeout)
+	defer cancel()
+	m.message = generateMessage(ctx, verse, hadith, name)
+	close(m.done)
+	return m.message
+}
+
 // generateMessage generates an LLM-based message using the verse, hadith, and name
 // The askLLM function panics on errors, which is why we use panic recovery here.
 func generateMessage(ctx context.Context, verse, hadith, name string) (message string) {
 	// Fallback message in case LLM fails
-	defaultMessage := "In the Name of Allah—the Most Beneficent, Most Merciful"
 	message = defaultMessage // Set default in case of panic
 
 	// Build context for the LLM
@@ -494,16 +566,689 @@ func generateMessage(ctx context.Context, verse, hadith, name string) (message s
 	return message
 }
 
-func main() {
-	fmt.Println("New rand source")
-	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+// truncate shortens text to max characters, appending an ellipsis
+func truncate(text string, max int) string {
+	r := []rune(text)
+	if len(r) <= max {
+		return text
+	}
+	return string(r[:max]) + "..."
+}
+
+// pushPayload builds the notification for a subscriber from the current
+// reminder. Daily and weekly notifications carry today's archived reminder
+// and return nil until it has been saved just after midnight UTC.
+func pushPayload(sub api.PushSubscription) []byte {
+	prefs := sub.Preferences.WithDefaults()
+	today := time.Now().UTC().Format("2006-01-02")
+
+	content := current().content()
+	url := "/home"
+
+	mtx.RLock()
+	defer mtx.RUnlock()
+
+	if prefs.Frequency != api.FrequencyHourly {
+		entry, ok := dailyIndex[today].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		// wait for the generated message rather than send the default
+		if ready, ok := entry["message_ready"].(bool); ok && !ready && prefs.Includes("message") {
+			return nil
+		}
+		for k := range content {
+			content[k], _ = entry[k].(string)
+		}
+		url = "/daily/" + today
+	}
+
+	var parts []string
+	for _, c := range prefs.Content {
+		if text := content[c]; len(text) > 0 {
+			parts = append(parts, truncate(text, 250))
+		}
+	}
+
+	// reading plans delivered to this subscription
+	for _, plan := range plans.ForEndpoint(sub.Endpoint) {
+		if portion := plan.Today(time.Now()); portion != nil {
+			parts = append(parts, portion.Text())
+		}
+	}
+
+	return []byte(api.NewPushPayload("Reminder", strings.Join(parts, "\n\n"), url))
+}
+
+// sendScheduledPush delivers notifications to subscribers due at now
+func sendScheduledPush(now time.Time) {
+	var msgs []api.PushMessage
+	var delivered []string
+
+	for _, sub := range api.DuePushSubscriptions(now) {
+		payload := pushPayload(sub)
+		if payload == nil {
+			// not ready yet, try again next minute
+			continue
+		}
+
+		msgs = append(msgs, api.PushMessage{Subscription: sub, Payload: string(payload)})
+		delivered = append(delivered, sub.Endpoint)
+	}
+
+	for _, d := range api.DefaultDispatcher.Dispatch(msgs) {
+		if len(d.Error) > 0 {
+			fmt.Printf("Failed to send push to %s: %s\n", d.Endpoint, d.Error)
+		}
+	}
+
+	if err := api.SetPushDelivered(delivered, now); err != nil {
+		fmt.Println("Failed to save push subscriptions:", err)
+	}
+}
+
+// sendTopicPush broadcasts to a topic and logs any failures
+func sendTopicPush(topic, payload string) {
+	for _, d := range api.SendPushToTopic(topic, payload) {
+		if len(d.Error) > 0 {
+			fmt.Printf("Failed to send %s push to %s: %s\n", topic, d.Endpoint, d.Error)
+		}
+	}
+}
+
+// topicPayload returns the message for a daily topic on the subscriber's
+// local date, or an empty string if there's none that day
+func topicPayload(topic string, date time.Time) string {
+	switch topic {
+	case api.TopicJumuah:
+		if date.Weekday() == time.Friday {
+			return api.NewPushPayload(
+				"Jumuah",
+				"It's Friday. Remember to read Surah Al-Kahf and send blessings upon the Prophet ﷺ.",
+				"/quran/18",
+			)
+		}
+	case api.TopicRamadan:
+		h, err := daily.ToHijri(date, daily.HijriOffset)
+		if err != nil || h.Month != 9 {
+			return ""
+		}
+		day := date.Format("2006-01-02")
+		body := fmt.Sprintf("Day %d of Ramadan.", h.Day)
+
+		mtx.RLock()
+		if entry, ok := dailyIndex[day].(map[string]interface{}); ok {
+			if verse, _ := entry["verse"].(string); len(verse) > 0 {
+				body += " " + truncate(verse, 200)
+			}
+		}
+		mtx.RUnlock()
+
+		return api.NewPushPayload("Ramadan", body, "/daily/"+day)
+	}
+	return ""
+}
+
+// sendTopicMessages sends the daily topic messages due at now, at each
+// subscriber's local delivery time
+func sendTopicMessages(now time.Time) {
+	for _, topic := range []string{api.TopicJumuah, api.TopicRamadan} {
+		var msgs []api.PushMessage
+		delivered := make(map[string]string)
+
+		for _, p := range api.DueTopicPushes(topic, now) {
+			date := p.Date.Format("2006-01-02")
+			// recorded even when there's nothing to send so it's only
+			// checked once a day
+			delivered[p.Subscription.Endpoint] = date
+			if payload := topicPayload(topic, p.Date); len(payload) > 0 {
+				msgs = append(msgs, api.PushMessage{Subscription: p.Subscription, Payload: payload})
+			}
+		}
+
+		for _, d := range api.DefaultDispatcher.Dispatch(msgs) {
+			if len(d.Error) > 0 {
+				fmt.Printf("Failed to send %s push to %s: %s\n", topic, d.Endpoint, d.Error)
+			}
+		}
+
+		if err := api.SetTopicDelivered(topic, delivered); err != nil {
+			fmt.Println("Failed to save push subscriptions:", err)
+		}
+	}
+}
+
+// dhikr for notifications anchored to each prayer
+var dhikr = map[string]string{
+	"fajr":    "Morning remembrance: SubhanAllahi wa bihamdihi (Glory and praise be to Allah), 100 times.",
+	"sunrise": "Remember Allah as the day begins: La ilaha illallah wahdahu la sharika lah (None has the right to be worshipped but Allah alone, without partner).",
+	"dhuhr":   "Astaghfirullah (I seek the forgiveness of Allah).",
+	"asr":     "Evening remembrance: SubhanAllahi wa bihamdihi (Glory and praise be to Allah), 100 times.",
+	"maghrib": "A'udhu bikalimatillahit-tammati min sharri ma khalaq (I seek refuge in the perfect words of Allah from the evil of what He has created).",
+	"isha":    "Bismika Allahumma amutu wa ahya (In Your name O Allah, I die and I live).",
+}
+
+// prayerPayload builds a notification anchored to a prayer time
+func prayerPayload(p api.PrayerPush) string {
+	name := strings.ToUpper(p.Anchor.Prayer[:1]) + p.Anchor.Prayer[1:]
+	title := name
+	switch {
+	case p.Anchor.Offset > 0:
+		title = "After " + name
+	case p.Anchor.Offset < 0:
+		title = "Before " + name
+	}
+
+	if p.Anchor.Content == api.AnchorDhikr {
+		return api.NewPushPayload(title, dhikr[p.Anchor.Prayer], "/home")
+	}
+
+	content := current().content()
+
+	var parts []string
+	for _, c := range p.Subscription.Preferences.WithDefaults().Content {
+		if text := content[c]; len(text) > 0 {
+			parts = append(parts, truncate(text, 250))
+		}
+	}
+	return api.NewPushPayload(title, strings.Join(parts, "\n\n"), "/home")
+}
+
+// sendPrayerPush delivers notifications anchored to prayer times due at now
+func sendPrayerPush(now time.Time) {
+	var msgs []api.PushMessage
+	delivered := make(map[string]time.Time)
+
+	for _, p := range api.DuePrayerPushes(now) {
+		msgs = append(msgs, api.PushMessage{Subscription: p.Subscription, Payload: prayerPayload(p)})
+		delivered[p.Subscription.Endpoint] = p.At
+	}
+
+	for _, d := range api.DefaultDispatcher.Dispatch(msgs) {
+		if len(d.Error) > 0 {
+			fmt.Printf("Failed to send prayer push to %s: %s\n", d.Endpoint, d.Error)
+		}
+	}
+
+	if err := api.SetAnchorDelivered(delivered); err != nil {
+		fmt.Println("Failed to save push subscriptions:", err)
+	}
+}
+
+// ankiFile names an exported deck e.g reminder-verses-2-255-257.apkg
+func ankiFile(typ string, r anki.Range) string {
+	name := "reminder-" + typ
+	if s := r.String(); len(s) > 0 && typ != "names" {
+		name += "-" + strings.NewReplacer(":", "-").Replace(s)
+	}
+	return name + ".apkg"
+}
+
+// ankiPackages caches the packages of decks without a range by type, as
+// they're the same for every request and the whole Quran is slow to build
+var ankiMtx sync.RWMutex
+var ankiPackages = map[string][]byte{}
+
+// cachedAnki returns the cached package of the deck if it has no range
+func cachedAnki(typ string, r anki.Range) ([]byte, bool) {
+	if r.Chapter != 0 {
+		return nil, false
+	}
+	ankiMtx.RLock()
+	defer ankiMtx.RUnlock()
+	pkg, ok := ankiPackages[typ]
+	return pkg, ok
+}
+
+// cacheAnki caches the package of the deck if it has no range
+func cacheAnki(typ string, r anki.Range, pkg []byte) {
+	if r.Chapter != 0 {
+		return
+	}
+	ankiMtx.Lock()
+	ankiPackages[typ] = pkg
+	ankiMtx.Unlock()
+}
+
+// exportAnki writes a deck to the current directory
+func exportAnki(typ, rng string, n *names.Names, q *quran.Quran) (string, error) {
+	r, err := anki.ParseRange(rng)
+	if err != nil {
+		return "", err
+	}
+	deck, err := anki.New(typ, n, q, r)
+	if err != nil {
+		return "", err
+	}
+	file := ankiFile(typ, r)
+	f, err := os.Create(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err := deck.Write(f); err != nil {
+		return "", err
+	}
+	return file, f.Close()
+}
+
+// maxCalendarDays limits the days covered by a calendar feed
+const maxCalendarDays = 366
+
+// calendarDays returns the days query param, or the default
+func calendarDays(r *http.Request, def int) (int, error) {
+	v := r.URL.Query().Get("days")
+	if len(v) == 0 {
+		return def, nil
+	}
+	days, err := strconv.Atoi(v)
+	if err != nil || days < 1 || days > maxCalendarDays {
+		return 0, fmt.Errorf("days must be between 1 and %d", maxCalendarDays)
+	}
+	return days, nil
+}
+
+// eventsCalendar is a feed of the events of the hijri calendar
+func eventsCalendar(start time.Time, days, offset int) (*ics.Calendar, error) {
+	occurrences, err := daily.Upcoming(start, days, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	cal := &ics.Calendar{
+		Name:        "Islamic Dates",
+		Description: "Events of the Islamic calendar",
+	}
+	for _, o := range occurrences {
+		cal.Events = append(cal.Events, &ics.Event{
+			UID:         fmt.Sprintf("%s-%d@reminder", o.Hijri, o.Date.Unix()),
+			Summary:     o.Name,
+			Description: o.Hijri.Display(),
+			Start:       o.Date,
+			AllDay:      true,
+		})
+	}
+	return cal, nil
+}
+
+// prayerCalendar is a feed of the prayer times at a location
+func prayerCalendar(lat, lon float64, start time.Time, days int, opts prayer.Options) (*ics.Calendar, error) {
+	cal := &ics.Calendar{
+		Name:        "Prayer Times",
+		Description: fmt.Sprintf("Prayer times for %v,%v", lat, lon),
+	}
+
+	var err error
+	for d := 0; d < days; d++ {
+		date := start.AddDate(0, 0, d)
+		var times *prayer.Times
+		// days without times are skipped e.g the sun doesn't set in the
+		// arctic summer
+		if times, err = prayer.Calculate(lat, lon, date, opts); err != nil {
+			continue
+		}
+		for _, name := range prayer.Prayers {
+			if name == "sunrise" {
+				continue
+			}
+			t, _ := times.Get(name)
+			cal.Events = append(cal.Events, &ics.Event{
+				UID:     fmt.Sprintf("%s-%s-%v-%v@reminder", name, date.Format("20060102"), lat, lon),
+				Summary: strings.ToUpper(name[:1]) + name[1:],
+				Start:   t,
+			})
+		}
+	}
+
+	if len(cal.Events) == 0 {
+		return nil, err
+	}
+	return cal, nil
+}
+
+// dailyCalendar is a feed of the most recent daily reminders
+func dailyCalendar(days int) *ics.Calendar {
+	mtx.RLock()
+	defer mtx.RUnlock()
+
+	var dates []string
+	for date := range dailyIndex {
+		if _, err := daily.ParseDate(date); err == nil {
+			dates = append(dates, date)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dates)))
+	if len(dates) > days {
+		dates = dates[:days]
+	}
+
+	cal := &ics.Calendar{
+		Name:        "Daily Reminder",
+		Description: "A verse from the Quran, hadith and name of Allah every day",
+		Refresh:     6 * time.Hour,
+	}
+	for _, date := range dates {
+		entry, ok := dailyIndex[date].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		var parts []string
+		for _, k := range []string{"verse", "hadith", "name"} {
+			if text, _ := entry[k].(string); len(text) > 0 {
+				parts = append(parts, text)
+			}
+		}
+		summary := "Daily Reminder"
+		if hijri, _ := entry["hijri"].(string); len(hijri) > 0 {
+			summary += " - " + hijri
+		}
+
+		start, _ := daily.ParseDate(date)
+		cal.Events = append(cal.Events, &ics.Event{
+			UID:         "daily-" + date + "@reminder",
+			Summary:     summary,
+			Description: strings.Join(parts, "\n\n"),
+			URL:         api.BaseURL + "/daily/" + date,
+			Start:       start,
+			AllDay:      true,
+		})
+	}
+	return cal
+}
+
+// writeCalendar writes the calendar as a subscribable feed
+func writeCalendar(w http.ResponseWriter, cal *ics.Calendar, file string) {
+	w.Header().Set("Content-Type", ics.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", file))
+	if err := cal.Write(w); err != nil {
+		fmt.Println("Failed to write calendar:", err)
+	}
+}
+
+// prayerTimes calculates the prayer times for the API and MCP tool. The
+// date defaults to today in the timezone which defaults to UTC.
+func prayerTimes(lat, lon float64, date, method, asr, highLatitude, tz string) (map[string]interface{}, error) {
+	loc := time.UTC
+	if len(tz) > 0 {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q", tz)
+		}
+		loc = l
+	}
+
+	if len(date) == 0 {
+		date = time.Now().In(loc).Format("2006-01-02")
+	}
+	day, err := daily.ParseDate(date)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := prayer.Options{Method: method, Asr: asr, HighLatitude: highLatitude, Location: loc}
+	times, err := prayer.Calculate(lat, lon, day, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(method) == 0 {
+		method = prayer.DefaultMethod
+	}
+	if len(asr) == 0 {
+		asr = prayer.Shafii
+	}
+	if len(highLatitude) == 0 {
+		highLatitude = prayer.HighLatitudeAngle
+	}
+
+	return map[string]interface{}{
+		"latitude":      lat,
+		"longitude":     lon,
+		"date":          date,
+		"timezone":      loc.String(),
+		"method":        method,
+		"method_name":   prayer.Methods[method].Name,
+		"asr":           asr,
+		"high_latitude": highLatitude,
+		"times":         times,
+	}, nil
+}
+
+// hijriOffset returns the offset query param or the configured offset
+func hijriOffset(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("offset")
+	if len(v) == 0 {
+		return daily.HijriOffset, nil
+	}
+	offset, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid offset %q", v)
+	}
+	return offset, nil
+}
+
+// getVerse formats the verse continuing to the end of the sentence
+func getVerse(ch *quran.Chapter, ve *quran.Verse) (string, int, int, string) {
+	verseText := ve.Text
+	verseStart := ve.Number
+	verseEnd := ve.Number
 
+	for i := 0; i < 10; i++ {
+		// done
+		if x := verseText[len(verseText)-1]; x == '.' || x == '!' || x == '"' {
+			break
+		}
+
+		idx := ve.Number + 1
+
+		if ch.Number == 1 || ch.Number == 9 {
+			idx = ve.Number
+		}
+
+		// bail out - check bounds
+		if idx < 0 || idx >= len(ch.Verses) {
+			break
+		}
+
+		// increment
+		ve = ch.Verses[idx]
+
+		if v := verseText[len(verseText)-1]; v == ',' || string(v) == "—" || unicode.IsLetter(rune(v)) || v == ';' {
+			verseText += " " + ve.Text
+		} else {
+			verseText += "\n\n" + ve.Text
+		}
+		verseEnd = ve.Number
+	}
+
+	verseNumber := fmt.Sprintf("%d", verseStart)
+
+	if verseStart != verseEnd {
+		verseNumber += fmt.Sprintf("-%d", verseEnd)
+	}
+
+	formatted := fmt.Sprintf("%s - %s - %d:%s\n\n%s", ch.Name, ch.English, ch.Number, verseNumber, verseText)
+	return formatted, verseStart, verseEnd, verseText
+}
+
+// snapshot is the latest reminder. It's never modified once stored, a new
+// one is swapped in instead.
+type snapshot struct {
+	name, verse, hadith, message string
+	links                        map[string]string
+	updated                      time.Time
+}
+
+// current returns the latest reminder
+func current() *snapshot {
+	if s := latest.Load(); s != nil {
+		return s
+	}
+	return &snapshot{links: map[string]string{}}
+}
+
+// data returns the reminder as served by the API
+func (s *snapshot) data() map[string]interface{} {
+	return map[string]interface{}{
+		"name":    s.name,
+		"hadith":  s.hadith,
+		"verse":   s.verse,
+		"links":   s.links,
+		"updated": s.updated.Format(time.RFC3339),
+		"message": s.message,
+	}
+}
+
+// content returns the text of each type of content
+func (s *snapshot) content() map[string]string {
+	return map[string]string{
+		"verse":   s.verse,
+		"hadith":  s.hadith,
+		"name":    s.name,
+		"message": s.message,
+	}
+}
+
+// reminder is the formatted content of a selection
+type reminder struct {
+	name, verse, hadith string
+	links               map[string]string
+	// metadata saved with the hourly reminders
+	meta map[string]interface{}
+}
+
+func newReminder(sel *daily.Selection) *reminder {
+	nam, chap, book, had := sel.Name, sel.Chapter, sel.Book, sel.Hadith
+
+	verseFormatted, verseStart, verseEnd, verseText := getVerse(chap, sel.Verse)
+	// Use new hadith format fields with fallback to legacy
+	hadithNarrator := had.Narrator
+	if hadithNarrator == "" {
+		hadithNarrator = had.By
+	}
+	hadithText := had.English
+	if hadithText == "" {
+		hadithText = had.Text
+	}
+	hadithNum := had.Number
+	if hadithNum == 0 {
+		hadithNum = 1
+	}
+
+	return &reminder{
+		name:   fmt.Sprintf("%s - %s - %s\n\n%s", nam.English, nam.Arabic, nam.Meaning, nam.Summary),
+		verse:  verseFormatted,
+		hadith: fmt.Sprintf("%s - %s\n\n%s", book.Name, hadithNarrator, hadithText),
+		links: map[string]string{
+			"verse":  fmt.Sprintf("/quran/%d#%d", chap.Number, verseStart),
+			"hadith": fmt.Sprintf("/hadith/%d#%d", book.Number, hadithNum),
+			"name":   fmt.Sprintf("/names/%d", nam.Number),
+		},
+		meta: map[string]interface{}{
+			"verse_meta": map[string]interface{}{
+				"chapter":      chap.Number,
+				"chapter_name": chap.English,
+				"verse_start":  verseStart,
+				"verse_end":    verseEnd,
+				"text":         verseText,
+			},
+			"hadith_meta": map[string]interface{}{
+				"book":      book.Number,
+				"book_name": book.Name,
+				"narrator":  hadithNarrator,
+				"number":    hadithNum,
+				"text":      hadithText,
+			},
+			"name_meta": map[string]interface{}{
+				"number":  nam.Number,
+				"english": nam.English,
+				"arabic":  nam.Arabic,
+				"meaning": nam.Meaning,
+				"summary": nam.Summary,
+			},
+		},
+	}
+}
+
+// dailyEntry computes the daily reminder for a date from its selection
+// for hour 0, as archived in daily.json
+func dailyEntry(selector *daily.Selector, date string) (map[string]interface{}, error) {
+	t, err := daily.ParseDate(date)
+	if err != nil {
+		return nil, err
+	}
+	sel, err := selector.Select(t, 0)
+	if err != nil {
+		return nil, err
+	}
+	rem := newReminder(sel)
+	return map[string]interface{}{
+		"verse":     rem.verse,
+		"hadith":    rem.hadith,
+		"name":      rem.name,
+		"hijri":     daily.DateOf(t).Display,
+		"date":      date,
+		"links":     rem.links,
+		"updated":   t.Format(time.RFC3339),
+		"message":   defaultMessage,
+		"selection": sel,
+	}, nil
+}
+
+// backfillDaily archives computed reminders for the dates from start until
+// today which haven't been recorded
+func backfillDaily(selector *daily.Selector, start string) (int, error) {
+	from, err := daily.ParseDate(start)
+	if err != nil {
+		return 0, err
+	}
+	today, _ := daily.ParseDate(time.Now().UTC().Format("2006-01-02"))
+
+	entries := map[string]map[string]interface{}{}
+	for t := from; !t.After(today); t = t.AddDate(0, 0, 1) {
+		date := t.Format("2006-01-02")
+		if _, ok := dailyIndex[date]; ok {
+			continue
+		}
+		entry, err := dailyEntry(selector, date)
+		if err != nil {
+			return 0, err
+		}
+		entries[date] = entry
+	}
+
+	saveDailies(entries)
+	return len(entries), nil
+}
+
+func main() {
 	flag.Parse()
 
 	// Load push subscriptions
 	fmt.Println("Loading subscriptions")
 	_ = api.LoadPushSubscriptions()
 
+	// Load email subscribers
+	fmt.Println("Loading users")
+	_ = api.LoadUsers()
+
+	// Load synced bookmarks
+	fmt.Println("Loading bookmarks")
+	_ = api.LoadBookmarks()
+
+	// Load notes
+	fmt.Println("Loading notes")
+	_ = api.LoadNotes()
+
+	// Load reading plans
+	fmt.Println("Loading plans")
+	_ = plans.Load()
+
+	// Load memorisation
+	fmt.Println("Loading hifz")
+	_ = hifz.Load()
+
 	// Load or generate VAPID keys
 	fmt.Println("Loading VAPID keys")
 	_ = api.LoadOrGenerateVAPIDKeys()
@@ -530,8 +1275,94 @@ func main() {
 	fmt.Println("Loaded Names")
 	b := hadith.Load()
 	fmt.Println("Loaded Hadith")
-	a := api.Load()
-	fmt.Println("Loaded API")
+
+	// run the offline similarity pass for parallel narrations
+	parallelsFile := api.ReminderPath("parallels.json")
+
+	if *ParallelsFlag {
+		fmt.Println("Linking parallel hadith")
+		linked := hadith.Link(hadith.DefaultThreshold, b)
+		if err := hadith.SaveParallels(parallelsFile, b); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("Linked %d hadith, saved to %s\n", linked, parallelsFile)
+		return
+	}
+
+	if err := hadith.LoadParallels(parallelsFile, b); err != nil && !os.IsNotExist(err) {
+		fmt.Println("Failed to load parallels:", err)
+	}
+
+	// link hadith to the verses they cite
+	fmt.Printf("Linked %d hadith to verses\n", b.LinkVerses(q))
+
+	// resolve the verses each name appears in
+	n.Link(q)
+
+	// pronunciation audio for the names
+	namesAudioDir := os.Getenv("NAMES_AUDIO_DIR")
+	if namesAudioDir == "" {
+		namesAudioDir = api.ReminderPath("audio/names")
+	}
+	n.SetAudio(namesAudioDir, "/audio/names/")
+
+	// quizzes over the loaded data
+	qz := quiz.New(n, q, b)
+
+	// local moon sighting adjustment of hijri dates
+	if v := os.Getenv("REMINDER_HIJRI_OFFSET"); len(v) > 0 {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < -daily.MaxHijriOffset || offset > daily.MaxHijriOffset {
+			fmt.Println("Invalid REMINDER_HIJRI_OFFSET:", v)
+		} else {
+			daily.HijriOffset = offset
+		}
+	}
+
+	// deterministic selection of the daily and hourly reminders
+	seed, _ := strconv.ParseInt(os.Getenv("REMINDER_SEED"), 10, 64)
+	window := 30
+	if v, err := strconv.Atoi(os.Getenv("REMINDER_REPEAT_DAYS")); err == nil {
+		window = v
+	}
+	selector := daily.NewSelector(seed, window, n, q, b, func(v *quran.Verse) bool {
+		return isCapital(v.Text)
+	})
+	fmt.Printf("Selecting with seed %d, no repeats within %d days\n", seed, selector.Window)
+
+	// content preferred on days of the islamic calendar
+	themes, err := daily.LoadThemes()
+	if err != nil {
+		fmt.Println("Failed to load themes:", err)
+	}
+	for _, missing := range selector.SetThemes(themes) {
+		fmt.Println("Theme content not found:", missing)
+	}
+	fmt.Printf("Loaded %d themes\n", len(themes))
+
+	if len(*BackfillFlag) > 0 {
+		count, err := backfillDaily(selector, *BackfillFlag)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("Backfilled %d days\n", count)
+		return
+	}
+
+	if len(*AnkiFlag) > 0 {
+		file, err := exportAnki(*AnkiFlag, *RangeFlag, n, q)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println("Exported Anki deck to", file)
+		return
+	}
+
+	a := api.Load()
+	fmt.Println("Loaded API")
 
 	// generate json
 	qjson := q.JSON()
@@ -688,125 +1519,678 @@ func main() {
 			w.Write([]byte("{}"))
 			return
 		}
-
-		chapter, _ := strconv.Atoi(ch)
-		if chapter < 1 || chapter > 114 {
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte("{}"))
+
+		chapter, _ := strconv.Atoi(ch)
+		if chapter < 1 || chapter > 114 {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("{}"))
+			return
+		}
+
+		b := q.Get(chapter).JSON()
+		w.Write(b)
+	})
+
+	http.HandleFunc("/api/quran/chapters", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		b, _ := json.Marshal(q.Index().Chapters)
+		w.Write(b)
+	})
+
+	http.HandleFunc("/api/quran/{chapter}/{verse}", func(w http.ResponseWriter, r *http.Request) {
+		ch := r.PathValue("chapter")
+		if len(ch) == 0 {
+			return
+		}
+
+		chapter, _ := strconv.Atoi(ch)
+		if chapter < 1 || chapter > 114 {
+			return
+		}
+
+		ve := r.PathValue("verse")
+		if len(ch) == 0 {
+			return
+		}
+
+		cc := q.Get(chapter)
+
+		verse, _ := strconv.Atoi(ve)
+		if verse < 1 || verse > len(cc.Verses) {
+			return
+		}
+
+		vee := cc.Verses[verse-1]
+		b := vee.JSON()
+
+		w.Write(b)
+	})
+
+	http.HandleFunc("/api/quran/{chapter}/{verse}/hadith", func(w http.ResponseWriter, r *http.Request) {
+		chapter, _ := strconv.Atoi(r.PathValue("chapter"))
+		if chapter < 1 || chapter > 114 {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("[]"))
+			return
+		}
+
+		verse, _ := strconv.Atoi(r.PathValue("verse"))
+
+		vee := q.Get(chapter).Verse(verse)
+		if vee == nil || verse < 1 {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("[]"))
+			return
+		}
+
+		resp := []map[string]interface{}{}
+
+		for _, key := range vee.Hadith {
+			var book, number int
+			fmt.Sscanf(key, "%d:%d", &book, &number)
+
+			h := b.Hadith(book, number)
+			if h == nil {
+				continue
+			}
+
+			resp = append(resp, map[string]interface{}{
+				"book":      book,
+				"book_name": b.Get(book).Name,
+				"number":    h.Number,
+				"narrator":  h.Narrator,
+				"english":   h.English,
+				"arabic":    h.Arabic,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	http.HandleFunc("/api/quran/{chapter}/{verse}/names", func(w http.ResponseWriter, r *http.Request) {
+		chapter, _ := strconv.Atoi(r.PathValue("chapter"))
+		if chapter < 1 || chapter > 114 {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("[]"))
+			return
+		}
+
+		verse, _ := strconv.Atoi(r.PathValue("verse"))
+
+		vee := q.Get(chapter).Verse(verse)
+		if vee == nil || verse < 1 {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("[]"))
+			return
+		}
+
+		resp := []map[string]interface{}{}
+
+		for _, number := range vee.Names {
+			name := n.Get(number)
+			resp = append(resp, map[string]interface{}{
+				"number":  name.Number,
+				"english": name.English,
+				"arabic":  name.Arabic,
+				"meaning": name.Meaning,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	http.HandleFunc("/api/plans", api.RequireUser(func(w http.ResponseWriter, r *http.Request) {
+		u, _ := api.UserFromContext(r.Context())
+
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(plans.List(u.Email))
+		case http.MethodPost:
+			var req struct {
+				plans.Options
+				Name     string `json:"name"`
+				TimeZone string `json:"timezone"`
+				Endpoint string `json:"endpoint"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if len(req.TimeZone) == 0 {
+				req.TimeZone = "UTC"
+			}
+			plan, err := plans.Create(q, u.Email, req.Name, req.TimeZone, req.Endpoint, req.Options)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(plan)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+
+	// Today's portion and progress of each of the user's plans
+	http.HandleFunc("/api/plans/today", api.RequireUser(func(w http.ResponseWriter, r *http.Request) {
+		u, _ := api.UserFromContext(r.Context())
+
+		resp := []map[string]interface{}{}
+		for _, plan := range plans.List(u.Email) {
+			resp = append(resp, map[string]interface{}{
+				"id":       plan.ID,
+				"name":     plan.Name,
+				"progress": plan.Progress(q, time.Now()),
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+
+	// userPlan returns the plan from the path if it belongs to the user
+	userPlan := func(r *http.Request) (*plans.Plan, bool) {
+		u, _ := api.UserFromContext(r.Context())
+		plan, ok := plans.Get(r.PathValue("id"))
+		if !ok || plan.User != u.Email {
+			return nil, false
+		}
+		return plan, true
+	}
+
+	http.HandleFunc("/api/plans/{id}", api.RequireUser(func(w http.ResponseWriter, r *http.Request) {
+		plan, ok := userPlan(r)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(plan)
+		case http.MethodDelete:
+			if err := plans.Delete(plan.ID); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+
+	http.HandleFunc("/api/plans/{id}/progress", api.RequireUser(func(w http.ResponseWriter, r *http.Request) {
+		plan, ok := userPlan(r)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			var req struct {
+				Verse string `json:"verse"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			ref, err := plans.ParseRef(req.Verse)
+			if err == nil {
+				err = plans.SetPosition(q, plan.ID, ref)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			plan, _ = plans.Get(plan.ID)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(plan.Progress(q, time.Now()))
+	}))
+
+	http.HandleFunc("/api/hifz", api.RequireUser(func(w http.ResponseWriter, r *http.Request) {
+		u, _ := api.UserFromContext(r.Context())
+
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(hifz.List(u.Email))
+		case http.MethodPost:
+			var req struct {
+				Range string `json:"range"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			chapter, start, end, err := hifz.ParseRange(req.Range)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			item, err := hifz.Add(q, u.Email, chapter, start, end, time.Now())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(item)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+
+	http.HandleFunc("/api/hifz/due", api.RequireUser(func(w http.ResponseWriter, r *http.Request) {
+		u, _ := api.UserFromContext(r.Context())
+		due := hifz.Due(u.Email, time.Now())
+		if due == nil {
+			due = []*hifz.Item{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(due)
+	}))
+
+	// userHifz returns the item from the path if it belongs to the user
+	userHifz := func(r *http.Request) (*hifz.Item, bool) {
+		u, _ := api.UserFromContext(r.Context())
+		item, ok := hifz.Get(r.PathValue("id"))
+		if !ok || item.User != u.Email {
+			return nil, false
+		}
+		return item, true
+	}
+
+	http.HandleFunc("/api/hifz/{id}", api.RequireUser(func(w http.ResponseWriter, r *http.Request) {
+		item, ok := userHifz(r)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(item)
+		case http.MethodDelete:
+			if err := hifz.Delete(item.ID); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+
+	http.HandleFunc("/api/hifz/{id}/review", api.RequireUser(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		item, ok := userHifz(r)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var req struct {
+			Grade *int `json:"grade"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Grade == nil {
+			http.Error(w, "grade is required", http.StatusBadRequest)
+			return
+		}
+		item, err := hifz.Grade(item.ID, *req.Grade, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(item)
+	}))
+
+	// Fill the next word drills for a memorised range
+	http.HandleFunc("/api/hifz/{id}/drill", api.RequireUser(func(w http.ResponseWriter, r *http.Request) {
+		item, ok := userHifz(r)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+		if count <= 0 {
+			count = 10
+		}
+		seed, err := strconv.ParseInt(r.URL.Query().Get("seed"), 10, 64)
+		if err != nil {
+			seed = time.Now().UnixNano()
+		}
+		drills, err := hifz.Drills(q, item.Chapter, item.Start, item.End, count, seed)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(drills)
+	}))
+
+	http.HandleFunc("/api/quiz", func(w http.ResponseWriter, r *http.Request) {
+		typ := r.URL.Query().Get("type")
+		if typ == "" {
+			typ = quiz.TypeNames
+		}
+		count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+		if count == 0 {
+			count = 10
+		}
+		seed, err := strconv.ParseInt(r.URL.Query().Get("seed"), 10, 64)
+		if err != nil {
+			seed = time.Now().UnixNano()
+		}
+
+		questions, err := qz.Questions(typ, count, seed)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"type":      typ,
+			"seed":      seed,
+			"questions": questions,
+		})
+	})
+
+	http.HandleFunc("/api/quiz/answer", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			ID     string `json:"id"`
+			Answer string `json:"answer"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		result, err := qz.Check(req.ID, req.Answer)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	})
+
+	http.HandleFunc("/api/export/anki", func(w http.ResponseWriter, r *http.Request) {
+		typ := r.URL.Query().Get("deck")
+		rng, err := anki.ParseRange(r.URL.Query().Get("range"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		pkg, ok := cachedAnki(typ, rng)
+		if !ok {
+			deck, err := anki.New(typ, n, q, rng)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			var buf bytes.Buffer
+			if err := deck.Write(&buf); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			pkg = buf.Bytes()
+			cacheAnki(typ, rng, pkg)
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, ankiFile(typ, rng)))
+		w.Write(pkg)
+	})
+
+	http.HandleFunc("/api/daily", func(w http.ResponseWriter, r *http.Request) {
+		// GET: today's archived daily (saved at midnight UTC)
+		today := time.Now().UTC().Format("2006-01-02")
+
+		mtx.RLock()
+		var resp interface{}
+		if entry, ok := dailyIndex[today]; ok {
+			resp = entry
+		} else {
+			// If today's archived daily doesn't exist yet, return the hourly updated content
+			data := current().data()
+			data["message"] = defaultMessage
+			data["hijri"] = daily.Date().Display
+			data["date"] = today
+			resp = data
+		}
+		mtx.RUnlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	// Add /api/latest endpoint for the hourly updated reminder
+	http.HandleFunc("/api/latest", func(w http.ResponseWriter, r *http.Request) {
+		resp := current().data()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	http.HandleFunc("/api/daily/{date}", func(w http.ResponseWriter, r *http.Request) {
+		date := r.PathValue("date")
+		if len(date) == 0 {
+			date = time.Now().Format("2006-01-02")
+		}
+
+		mtx.RLock()
+		entry, ok := dailyIndex[date]
+		mtx.RUnlock()
+
+		// dates which weren't archived are computed, or the archived
+		// entry can be audited against the computed one
+		var resp interface{} = entry
+		if !ok || r.URL.Query().Get("computed") == "true" {
+			computed, err := dailyEntry(selector, date)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+				return
+			}
+			resp = computed
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	http.HandleFunc("/api/themes", func(w http.ResponseWriter, r *http.Request) {
+		result := themes
+		if date := r.URL.Query().Get("date"); len(date) > 0 {
+			t, err := daily.ParseDate(date)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			result = selector.Themes(t)
+		}
+		if result == nil {
+			result = []*daily.Theme{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	})
+
+	http.HandleFunc("/api/hijri/convert", func(w http.ResponseWriter, r *http.Request) {
+		offset, err := hijriOffset(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		date := r.URL.Query().Get("date")
+		if len(date) == 0 {
+			date = time.Now().UTC().Format("2006-01-02")
+		}
+
+		// hijri dates are detected by the year unless from is set
+		from := r.URL.Query().Get("from")
+		if len(from) == 0 {
+			from = "gregorian"
+			if h, err := daily.ParseHijri(date); err == nil && h.Year < 1900 {
+				from = "hijri"
+			}
+		}
+
+		var day *daily.Day
+		switch from {
+		case "gregorian":
+			t, perr := daily.ParseDate(date)
+			if perr != nil {
+				http.Error(w, perr.Error(), http.StatusBadRequest)
+				return
+			}
+			day, err = daily.ConvertGregorian(t, offset)
+		case "hijri":
+			h, perr := daily.ParseHijri(date)
+			if perr != nil {
+				http.Error(w, perr.Error(), http.StatusBadRequest)
+				return
+			}
+			day, err = daily.ConvertHijri(h, offset)
+		default:
+			http.Error(w, "from must be gregorian or hijri", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		b := q.Get(chapter).JSON()
-		w.Write(b)
-	})
-
-	http.HandleFunc("/api/quran/chapters", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		b, _ := json.Marshal(q.Index().Chapters)
-		w.Write(b)
+		json.NewEncoder(w).Encode(day)
 	})
 
-	http.HandleFunc("/api/quran/{chapter}/{verse}", func(w http.ResponseWriter, r *http.Request) {
-		ch := r.PathValue("chapter")
-		if len(ch) == 0 {
+	http.HandleFunc("/api/hijri/month/{year}/{month}", func(w http.ResponseWriter, r *http.Request) {
+		offset, err := hijriOffset(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		chapter, _ := strconv.Atoi(ch)
-		if chapter < 1 || chapter > 114 {
+		year, err1 := strconv.Atoi(r.PathValue("year"))
+		month, err2 := strconv.Atoi(r.PathValue("month"))
+		if err1 != nil || err2 != nil {
+			http.Error(w, "invalid year or month", http.StatusBadRequest)
 			return
 		}
-
-		ve := r.PathValue("verse")
-		if len(ch) == 0 {
+		m, err := daily.CalendarMonth(year, month, offset)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(m)
+	})
 
-		cc := q.Get(chapter)
-
-		verse, _ := strconv.Atoi(ve)
-		if verse < 1 || verse > len(cc.Verses) {
+	http.HandleFunc("/api/calendar/events.ics", func(w http.ResponseWriter, r *http.Request) {
+		offset, err := hijriOffset(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		vee := cc.Verses[verse-1]
-		b := vee.JSON()
-
-		w.Write(b)
+		days, err := calendarDays(r, 365)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cal, err := eventsCalendar(time.Now().UTC(), days, offset)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeCalendar(w, cal, "events.ics")
 	})
 
-	http.HandleFunc("/api/daily", func(w http.ResponseWriter, r *http.Request) {
-		// GET: today's archived daily (saved at midnight UTC)
-		today := time.Now().UTC().Format("2006-01-02")
-
-		mtx.RLock()
-		var resp interface{}
-		if entry, ok := dailyIndex[today]; ok {
-			resp = entry
-		} else {
-			// If today's archived daily doesn't exist yet, return the hourly updated content
-			message := "In the Name of Allah—the Most Beneficent, Most Merciful"
-			resp = map[string]interface{}{
-				"name":    dailyName,
-				"hadith":  dailyHadith,
-				"verse":   dailyVerse,
-				"links":   links,
-				"updated": dailyUpdated.Format(time.RFC3339),
-				"message": message,
-				"hijri":   daily.Date().Display,
-				"date":    today,
+	http.HandleFunc("/api/calendar/prayer.ics", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		lat, err1 := strconv.ParseFloat(q.Get("lat"), 64)
+		lon, err2 := strconv.ParseFloat(q.Get("lon"), 64)
+		if err1 != nil || err2 != nil {
+			http.Error(w, "lat and lon are required", http.StatusBadRequest)
+			return
+		}
+		days, err := calendarDays(r, 30)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		loc := time.UTC
+		if tz := q.Get("tz"); len(tz) > 0 {
+			if loc, err = time.LoadLocation(tz); err != nil {
+				http.Error(w, fmt.Sprintf("invalid timezone %q", tz), http.StatusBadRequest)
+				return
 			}
 		}
-		mtx.RUnlock()
+		now := time.Now().In(loc)
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(resp)
+		opts := prayer.Options{Method: q.Get("method"), Asr: q.Get("asr"), HighLatitude: q.Get("high_latitude"), Location: loc}
+		cal, err := prayerCalendar(lat, lon, start, days, opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeCalendar(w, cal, "prayer.ics")
 	})
 
-	// Add /api/latest endpoint for the hourly updated reminder
-	http.HandleFunc("/api/latest", func(w http.ResponseWriter, r *http.Request) {
-		mtx.RLock()
-		verse := dailyVerse
-		hadith := dailyHadith
-		name := dailyName
-		message := dailyMessage
-		updated := dailyUpdated
-		currentLinks := links
-		mtx.RUnlock()
+	http.HandleFunc("/api/calendar/daily.ics", func(w http.ResponseWriter, r *http.Request) {
+		days, err := calendarDays(r, 30)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeCalendar(w, dailyCalendar(days), "daily.ics")
+	})
 
-		resp := map[string]interface{}{
-			"name":    name,
-			"hadith":  hadith,
-			"verse":   verse,
-			"links":   currentLinks,
-			"updated": updated.Format(time.RFC3339),
-			"message": message,
+	http.HandleFunc("/api/prayer", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		lat, err1 := strconv.ParseFloat(q.Get("lat"), 64)
+		lon, err2 := strconv.ParseFloat(q.Get("lon"), 64)
+		if err1 != nil || err2 != nil {
+			http.Error(w, "lat and lon are required", http.StatusBadRequest)
+			return
+		}
+		resp, err := prayerTimes(lat, lon, q.Get("date"), q.Get("method"), q.Get("asr"), q.Get("high_latitude"), q.Get("tz"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(resp)
 	})
 
-	http.HandleFunc("/api/daily/{date}", func(w http.ResponseWriter, r *http.Request) {
-		date := r.PathValue("date")
-		if len(date) == 0 {
-			date = time.Now().Format("2006-01-02")
+	http.HandleFunc("/api/qibla", func(w http.ResponseWriter, r *http.Request) {
+		lat, err1 := strconv.ParseFloat(r.URL.Query().Get("lat"), 64)
+		lon, err2 := strconv.ParseFloat(r.URL.Query().Get("lon"), 64)
+		if err1 != nil || err2 != nil {
+			http.Error(w, "lat and lon are required", http.StatusBadRequest)
+			return
 		}
-
-		mtx.RLock()
-
-		var resp interface{}
-		if entry, ok := dailyIndex[date]; ok {
-			resp = entry
-		} else {
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte(`{"error":"Not found"}`))
-			mtx.RUnlock()
+		q, err := prayer.Direction(lat, lon)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		mtx.RUnlock()
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(resp)
+		json.NewEncoder(w).Encode(q)
 	})
 
 	// Add daily index API endpoint
@@ -1023,9 +2407,26 @@ func main() {
 	})
 
 	http.HandleFunc("/api/names", func(w http.ResponseWriter, r *http.Request) {
+		if category := r.URL.Query().Get("category"); len(category) > 0 {
+			cat, ok := n.Category(category)
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(cat.JSON())
+			return
+		}
 		w.Write([]byte(njson))
 	})
 
+	http.HandleFunc("/api/names/categories", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		b, _ := json.Marshal(names.Categories())
+		w.Write(b)
+	})
+
+	http.Handle("/audio/names/", http.StripPrefix("/audio/names/", http.FileServer(http.Dir(namesAudioDir))))
+
 	http.HandleFunc("/api/names/{id}", func(w http.ResponseWriter, r *http.Request) {
 		id := r.PathValue("id")
 		if len(id) == 0 {
@@ -1072,6 +2473,54 @@ func main() {
 		w.Write(b)
 	})
 
+	http.HandleFunc("/api/hadith/{book}/{number}", func(w http.ResponseWriter, r *http.Request) {
+		book, _ := strconv.Atoi(r.PathValue("book"))
+		number, _ := strconv.Atoi(r.PathValue("number"))
+
+		h := b.Hadith(book, number)
+		if h == nil {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("{}"))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(h)
+	})
+
+	http.HandleFunc("/api/hadith/{book}/{number}/parallels", func(w http.ResponseWriter, r *http.Request) {
+		book, _ := strconv.Atoi(r.PathValue("book"))
+		number, _ := strconv.Atoi(r.PathValue("number"))
+
+		h := b.Hadith(book, number)
+		if h == nil {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("[]"))
+			return
+		}
+
+		parallels := []map[string]interface{}{}
+		for _, p := range h.Parallels {
+			entry := map[string]interface{}{
+				"collection": p.Collection,
+				"book":       p.Book,
+				"number":     p.Number,
+				"score":      p.Score,
+			}
+			if p.Collection == b.Name {
+				if ph := b.Hadith(p.Book, p.Number); ph != nil {
+					entry["narrator"] = ph.Narrator
+					entry["english"] = ph.English
+					entry["arabic"] = ph.Arabic
+				}
+			}
+			parallels = append(parallels, entry)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(parallels)
+	})
+
 	http.HandleFunc("/api/explain", func(w http.ResponseWriter, r *http.Request) {
 		b, _ := io.ReadAll(r.Body)
 		var data map[string]interface{}
@@ -1235,6 +2684,10 @@ func main() {
 
 	fmt.Println("Registering routes")
 	httpMux := http.DefaultServeMux
+	api.PushPayload = pushPayload
+	api.RenderMarkdown = func(md string) string {
+		return string(app.RenderSafe([]byte(md)))
+	}
 	api.RegisterRoutes(httpMux)
 
 	// Register MCP server
@@ -1243,17 +2696,7 @@ func main() {
 	mcpServer.AddTool("get_latest", "Get the latest reminder (updated hourly) with a verse, hadith and name of Allah", api.InputSchema{
 		Type: "object",
 	}, func(args map[string]interface{}) (string, error) {
-		mtx.RLock()
-		resp := map[string]interface{}{
-			"name":    dailyName,
-			"hadith":  dailyHadith,
-			"verse":   dailyVerse,
-			"links":   links,
-			"updated": dailyUpdated.Format(time.RFC3339),
-			"message": dailyMessage,
-		}
-		mtx.RUnlock()
-		b, _ := json.Marshal(resp)
+		b, _ := json.Marshal(current().data())
 		return string(b), nil
 	})
 
@@ -1267,17 +2710,10 @@ func main() {
 			b, _ := json.Marshal(entry)
 			return string(b), nil
 		}
-		message := "In the Name of Allah—the Most Beneficent, Most Merciful"
-		resp := map[string]interface{}{
-			"name":    dailyName,
-			"hadith":  dailyHadith,
-			"verse":   dailyVerse,
-			"links":   links,
-			"updated": dailyUpdated.Format(time.RFC3339),
-			"message": message,
-			"hijri":   daily.Date().Display,
-			"date":    today,
-		}
+		resp := current().data()
+		resp["message"] = defaultMessage
+		resp["hijri"] = daily.Date().Display
+		resp["date"] = today
 		b, _ := json.Marshal(resp)
 		return string(b), nil
 	})
@@ -1294,12 +2730,17 @@ func main() {
 			return "", fmt.Errorf("date is required")
 		}
 		mtx.RLock()
-		defer mtx.RUnlock()
-		if entry, ok := dailyIndex[date]; ok {
-			b, _ := json.Marshal(entry)
-			return string(b), nil
+		entry, ok := dailyIndex[date]
+		mtx.RUnlock()
+		if !ok {
+			computed, err := dailyEntry(selector, date)
+			if err != nil {
+				return "", err
+			}
+			entry = computed
 		}
-		return "", fmt.Errorf("not found")
+		b, _ := json.Marshal(entry)
+		return string(b), nil
 	})
 
 	mcpServer.AddTool("get_quran_chapters", "Get a list of all Quran chapters", api.InputSchema{
@@ -1380,13 +2821,23 @@ func main() {
 		return string(b.Get(book).JSON()), nil
 	})
 
-	mcpServer.AddTool("get_names", "Get all 99 Names of Allah", api.InputSchema{
+	mcpServer.AddTool("get_names", "Get all 99 Names of Allah, optionally filtered by category", api.InputSchema{
 		Type: "object",
+		Properties: map[string]api.Property{
+			"category": {Type: "string", Description: "Attribute category e.g mercy, power, knowledge"},
+		},
 	}, func(args map[string]interface{}) (string, error) {
+		if category, _ := args["category"].(string); len(category) > 0 {
+			cat, ok := n.Category(category)
+			if !ok {
+				return "", fmt.Errorf("category not found")
+			}
+			return string(cat.JSON()), nil
+		}
 		return string(njson), nil
 	})
 
-	mcpServer.AddTool("get_name", "Get a specific Name of Allah with description and Quran references", api.InputSchema{
+	mcpServer.AddTool("get_name", "Get a specific Name of Allah with description and the Quran verses it appears in", api.InputSchema{
 		Type: "object",
 		Properties: map[string]api.Property{
 			"id": {Type: "number", Description: "Name number (1-99)"},
@@ -1405,6 +2856,101 @@ func main() {
 		return string(byt), nil
 	})
 
+	mcpServer.AddTool("get_quiz", "Get multiple choice quiz questions on names of Allah, surahs, Quran word meanings or hadith narrators", api.InputSchema{
+		Type: "object",
+		Properties: map[string]api.Property{
+			"type":  {Type: "string", Description: "One of names, surah, words or narrator"},
+			"count": {Type: "number", Description: "Number of questions (1-50). Defaults to 10"},
+			"seed":  {Type: "number", Description: "Optional seed to repeat the same questions"},
+		},
+		Required: []string{"type"},
+	}, func(args map[string]interface{}) (string, error) {
+		typ, _ := args["type"].(string)
+		count := 10
+		if c, ok := args["count"].(float64); ok {
+			count = int(c)
+		}
+		seed := time.Now().UnixNano()
+		if s, ok := args["seed"].(float64); ok {
+			seed = int64(s)
+		}
+		questions, err := qz.Questions(typ, count, seed)
+		if err != nil {
+			return "", err
+		}
+		byt, _ := json.Marshal(questions)
+		return string(byt), nil
+	})
+
+	mcpServer.AddTool("check_quiz_answer", "Check the answer to a quiz question", api.InputSchema{
+		Type: "object",
+		Properties: map[string]api.Property{
+			"id":     {Type: "string", Description: "ID of the question"},
+			"answer": {Type: "string", Description: "The chosen answer"},
+		},
+		Required: []string{"id", "answer"},
+	}, func(args map[string]interface{}) (string, error) {
+		id, _ := args["id"].(string)
+		answer, _ := args["answer"].(string)
+		result, err := qz.Check(id, answer)
+		if err != nil {
+			return "", err
+		}
+		byt, _ := json.Marshal(result)
+		return string(byt), nil
+	})
+
+	mcpServer.AddTool("get_prayer_times", "Get the prayer times for a location and date", api.InputSchema{
+		Type: "object",
+		Properties: map[string]api.Property{
+			"lat":           {Type: "number", Description: "Latitude"},
+			"lon":           {Type: "number", Description: "Longitude"},
+			"date":          {Type: "string", Description: "Date in YYYY-MM-DD format, defaults to today"},
+			"method":        {Type: "string", Description: "mwl (default), isna, egyptian, karachi or umm_al_qura"},
+			"asr":           {Type: "string", Description: "shafii (default) or hanafi"},
+			"high_latitude": {Type: "string", Description: "Rule for high latitudes: angle (default), middle, seventh or none"},
+			"tz":            {Type: "string", Description: "IANA timezone of the times e.g Europe/London, defaults to UTC"},
+		},
+		Required: []string{"lat", "lon"},
+	}, func(args map[string]interface{}) (string, error) {
+		lat, ok1 := args["lat"].(float64)
+		lon, ok2 := args["lon"].(float64)
+		if !ok1 || !ok2 {
+			return "", fmt.Errorf("lat and lon are required")
+		}
+		str := func(k string) string {
+			v, _ := args[k].(string)
+			return v
+		}
+		resp, err := prayerTimes(lat, lon, str("date"), str("method"), str("asr"), str("high_latitude"), str("tz"))
+		if err != nil {
+			return "", err
+		}
+		byt, _ := json.Marshal(resp)
+		return string(byt), nil
+	})
+
+	mcpServer.AddTool("get_qibla", "Get the Qibla bearing and distance to the Kaaba from a location", api.InputSchema{
+		Type: "object",
+		Properties: map[string]api.Property{
+			"lat": {Type: "number", Description: "Latitude"},
+			"lon": {Type: "number", Description: "Longitude"},
+		},
+		Required: []string{"lat", "lon"},
+	}, func(args map[string]interface{}) (string, error) {
+		lat, ok1 := args["lat"].(float64)
+		lon, ok2 := args["lon"].(float64)
+		if !ok1 || !ok2 {
+			return "", fmt.Errorf("lat and lon are required")
+		}
+		q, err := prayer.Direction(lat, lon)
+		if err != nil {
+			return "", err
+		}
+		byt, _ := json.Marshal(q)
+		return string(byt), nil
+	})
+
 	mcpServer.AddTool("search", "Search Islamic content and get AI-summarised answers from the Quran, Hadith and Names of Allah", api.InputSchema{
 		Type: "object",
 		Properties: map[string]api.Property{
@@ -1455,238 +3001,157 @@ func main() {
 
 	http.Handle("/mcp", mcpServer)
 
-	getVerse := func(ch *quran.Chapter, ve *quran.Verse) (string, int, int, string) {
-		verseText := ve.Text
-		verseStart := ve.Number
-		verseEnd := ve.Number
-
-		for i := 0; i < 10; i++ {
-			// done
-			if x := verseText[len(verseText)-1]; x == '.' || x == '!' || x == '"' {
-				break
-			}
-
-			idx := ve.Number + 1
-
-			if ch.Number == 1 || ch.Number == 9 {
-				idx = ve.Number
-			}
-
-			// bail out - check bounds
-			if idx < 0 || idx >= len(ch.Verses) {
-				break
-			}
-
-			// increment
-			ve = ch.Verses[idx]
+	// hourly refreshes the reminder from the selection for the hour
+	hourly := func(now time.Time) error {
+		now = now.UTC()
 
-			if v := verseText[len(verseText)-1]; v == ',' || string(v) == "—" || unicode.IsLetter(rune(v)) || v == ';' {
-				verseText += " " + ve.Text
-			} else {
-				verseText += "\n\n" + ve.Text
-			}
-			verseEnd = ve.Number
+		// The selection is derived from the hour so restarts don't
+		// change the content
+		sel, err := selector.Select(now, now.Hour())
+		if err != nil {
+			return fmt.Errorf("could not select content: %v", err)
 		}
+		rem := newReminder(sel)
 
-		verseNumber := fmt.Sprintf("%d", verseStart)
-
-		if verseStart != verseEnd {
-			verseNumber += fmt.Sprintf("-%d", verseEnd)
+		// Serve the new reminder straight away, the message is patched in
+		// once generated
+		snap := &snapshot{
+			name:    rem.name,
+			verse:   rem.verse,
+			hadith:  rem.hadith,
+			message: defaultMessage,
+			links:   rem.links,
+			updated: time.Now(),
 		}
+		latest.Store(snap)
 
-		formatted := fmt.Sprintf("%s - %s - %d:%s\n\n%s", ch.Name, ch.English, ch.Number, verseNumber, verseText)
-		return formatted, verseStart, verseEnd, verseText
-	}
-
-	daily := func() {
-		// Check if we're within the first 5 minutes after midnight UTC
-		// This handles server restarts that happen right at midnight
-		isWithinGracePeriod := func() bool {
-			now := time.Now().UTC()
-			midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
-			sinceMidnight := now.Sub(midnight)
-			return sinceMidnight >= 0 && sinceMidnight < 5*time.Minute
-		}
+		mtx.Lock()
 
-		for {
-			func() {
-				defer func() {
-					if r := recover(); r != nil {
-						fmt.Printf("Daily loop recovered from panic: %v\n", r)
-					}
-				}()
-			fmt.Println("Running daily")
+		today := now.Format("2006-01-02")
+		timestamp := now.Format(time.RFC3339)
 
-			mtx.Lock()
+		// Save hourly reminder with metadata
+		hourlyData := map[string]interface{}{
+			"timestamp": timestamp,
+			"selection": sel,
+		}
+		for k, v := range rem.meta {
+			hourlyData[k] = v
+		}
+		saveHourlyReminder(today, timestamp, hourlyData)
 
-			var nam *names.Name
-			var book *hadith.Book
-			var chap *quran.Chapter
-			var ver *quran.Verse
-			var had *hadith.Hadith
-
-			// Retry random selection up to 50 times to avoid skipping
-			// an entire hour when we pick a bad combination.
-			found := false
-			for attempt := 0; attempt < 50; attempt++ {
-				nam = (*n)[rnd.Int()%len((*n))]
-				bookIdx := rnd.Int() % len(b.Books)
-				book = b.Books[bookIdx]
-
-				if len(book.Hadiths) == 0 {
-					continue
-				}
+		mtx.Unlock()
 
-				chap = q.Chapters[rnd.Int()%len(q.Chapters)]
+		go sendTopicPush(api.TopicHourly, api.NewPushPayload("Reminder", truncate(rem.verse, 250), "/home"))
 
-				if len(chap.Verses) == 0 {
-					continue
-				}
+		// Generate the contextual message in the background so neither
+		// readers nor the next run wait on the LLM. It's only swapped in if
+		// the reminder hasn't changed since.
+		go func() {
+			patched := *snap
+			patched.message = messageFor(sel.Slot, rem.verse, rem.hadith, rem.name)
+			latest.CompareAndSwap(snap, &patched)
+		}()
+		return nil
+	}
 
-				ver = chap.Verses[rnd.Int()%len(chap.Verses)]
-				had = book.Hadiths[rnd.Int()%len(book.Hadiths)]
+	// archive saves the reminder for the day and sends the email digest. The
+	// daily reminder is always the selection for hour 0 so it's the same
+	// however late in the day it's archived.
+	archive := func(now time.Time) error {
+		now = now.UTC()
+		today := now.Format("2006-01-02")
 
-				if ver.Number == 0 || !isCapital(ver.Text) {
-					continue
-				}
+		mtx.RLock()
+		entry, archived := dailyIndex[today].(map[string]interface{})
+		sent := lastPushDate == today
+		mtx.RUnlock()
 
-				found = true
-				break
-			}
+		// the message is still to be generated if a run was interrupted
+		pending := archived && entry["message_ready"] == false
 
-			if !found {
-				fmt.Println("Could not find valid content after 50 attempts, will retry next hour")
-				mtx.Unlock()
-				return
-			}
+		if archived && !pending && sent {
+			return nil
+		}
 
-			dailyName = fmt.Sprintf("%s - %s - %s\n\n%s", nam.English, nam.Arabic, nam.Meaning, nam.Summary)
-			verseFormatted, verseStart, verseEnd, verseText := getVerse(chap, ver)
-			dailyVerse = verseFormatted
-			// Use new hadith format fields with fallback to legacy
-			hadithNarrator := had.Narrator
-			if hadithNarrator == "" {
-				hadithNarrator = had.By
-			}
-			hadithText := had.English
-			if hadithText == "" {
-				hadithText = had.Text
+		if !archived {
+			var err error
+			if entry, err = dailyEntry(selector, today); err != nil {
+				return fmt.Errorf("could not select daily content: %v", err)
 			}
-			hadithNum := had.Number
-			if hadithNum == 0 {
-				hadithNum = 1
-			}
-			dailyHadith = fmt.Sprintf("%s - %s\n\n%s", book.Name, hadithNarrator, hadithText)
-
-			// Generate the contextual message once and cache it with the daily data
-			dailyMessage = generateMessage(context.Background(), dailyVerse, dailyHadith, dailyName)
+			entry["updated"] = time.Now().UTC().Format(time.RFC3339)
+			entry["message_ready"] = false
 
-			links = map[string]string{
-				"verse":  fmt.Sprintf("/quran/%d#%d", chap.Number, verseStart),
-				"hadith": fmt.Sprintf("/hadith/%d#%d", book.Number, hadithNum),
-				"name":   fmt.Sprintf("/names/%d", nam.Number),
-			}
+			// Archive with the default message so readers don't wait on
+			// the LLM, then replace the entry with a copy including it.
+			// Pushes including the message wait for it to be ready.
+			mtx.Lock()
+			saveDaily(today, entry)
+			mtx.Unlock()
+		}
 
-			dailyUpdated = time.Now()
-			hijriDate := daily.Date().Display
-			today := time.Now().UTC().Format("2006-01-02")
-			timestamp := time.Now().UTC().Format(time.RFC3339)
-
-			// Save hourly reminder with metadata
-			hourlyData := map[string]interface{}{
-				"timestamp": timestamp,
-				"verse_meta": map[string]interface{}{
-					"chapter":      chap.Number,
-					"chapter_name": chap.English,
-					"verse_start":  verseStart,
-					"verse_end":    verseEnd,
-					"text":         verseText,
-				},
-				"hadith_meta": map[string]interface{}{
-					"book":      book.Number,
-					"book_name": book.Name,
-					"narrator":  hadithNarrator,
-					"number":    hadithNum,
-					"text":      hadithText,
-				},
-				"name_meta": map[string]interface{}{
-					"number":  nam.Number,
-					"english": nam.English,
-					"arabic":  nam.Arabic,
-					"meaning": nam.Meaning,
-					"summary": nam.Summary,
-				},
+		if !archived || pending {
+			patched := make(map[string]interface{}, len(entry))
+			for k, v := range entry {
+				patched[k] = v
 			}
-			saveHourlyReminder(today, timestamp, hourlyData)
+			patched["message"] = messageFor(daily.Slot(now, 0), entry["verse"].(string), entry["hadith"].(string), entry["name"].(string))
+			patched["message_ready"] = true
 
+			mtx.Lock()
+			saveDaily(today, patched)
 			mtx.Unlock()
 
-			// Check if we should send push notification (new day or within grace period after midnight)
-			if lastPushDate != today || (lastPushDate == today && isWithinGracePeriod()) {
-				mtx.Lock()
-
-				// Compose a user-friendly notification message
-				notifyVerse := dailyVerse
-
-				if len(dailyVerse) > 250 {
-					notifyVerse = notifyVerse[:250] + "..."
-				}
-
-				dailyData := map[string]interface{}{
-					"verse":   dailyVerse,
-					"hadith":  dailyHadith,
-					"name":    dailyName,
-					"hijri":   hijriDate,
-					"date":    today,
-					"links":   links,
-					"updated": dailyUpdated.Format(time.RFC3339),
-					"message": dailyMessage,
-				}
-
-				// Save to daily.json
-				saveDaily(today, dailyData)
-
-				payload := map[string]interface{}{
-					"title": "Reminder",
-					"body":  notifyVerse,
-					"data": map[string]interface{}{
-						"url": "/daily/" + today,
-					},
-				}
-
-				b, _ := json.Marshal(payload)
-
-				// Only send if we haven't already sent today
-				if lastPushDate != today {
-					fmt.Println("Sending push notification at midnight UTC")
+			entry = patched
+		}
 
-					errors := api.SendPushToAll(string(b))
-					if len(errors) > 0 {
-						fmt.Println("Push notification errors:")
-						for _, err := range errors {
-							fmt.Println(err)
-						}
-					}
+		mtx.Lock()
+		defer mtx.Unlock()
 
-					lastPushDate = today
-					saveLastPushDate(today)
-				} else {
-					fmt.Println("Push notification already sent today, skipping")
-				}
+		// Push notifications are sent per subscriber by the push job once
+		// today's reminder has been archived with its message
+		if lastPushDate != today {
+			lastPushDate = today
+			saveLastPushDate(today)
 
-				mtx.Unlock()
-			}
+			// Email the digest to confirmed subscribers
+			go api.SendDigest(entry)
+		}
 
-			}() // end of panic-recovery wrapper
+		return nil
+	}
 
-			// Update hourly for /api/latest
-			time.Sleep(time.Hour)
+	// Jobs run on a schedule with their state saved across restarts
+	jobs := scheduler.New(api.ReminderPath("jobs.json"))
+	if err := jobs.Load(); err != nil {
+		fmt.Println("Failed to load job state:", err)
+	}
+	for _, job := range []scheduler.Job{
+		// refresh the reminder on the hour and on start
+		{Name: "hourly", Spec: "@hourly", OnStart: true, Run: hourly},
+		// archive at midnight UTC or on start if it was missed
+		{Name: "archive", Spec: "@daily", CatchUp: true, Run: archive},
+		// deliver push notifications due each minute
+		{Name: "push", Spec: "* * * * *", Run: func(now time.Time) error {
+			sendScheduledPush(now)
+			sendTopicMessages(now)
+			sendPrayerPush(now)
+			return nil
+		}},
+	} {
+		if err := jobs.Add(job); err != nil {
+			fmt.Println("Failed to add job:", err)
 		}
 	}
 
+	http.HandleFunc("/api/admin/jobs", api.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(jobs.Status())
+	}))
+
 	if *ServerFlag {
-		fmt.Println("Starting daily")
-		go daily()
+		fmt.Println("Starting scheduler")
+		jobs.Start()
 
 		fmt.Println("Starting server :8080")
 		if err := http.ListenAndServe(":8080", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -4,7 +4,13 @@ import (
 	"embed"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/asim/reminder/quran"
 )
 
 //go:embed data/*.json
@@ -20,6 +26,28 @@ type Name struct {
 	Description string   `json:"description"`
 	Summary     string   `json:"summary"`
 	Location    []string `json:"location"`
+	// Verses resolved from the location
+	Verses []*Reference `json:"verses,omitempty"`
+	// Attribute category e.g mercy
+	Category string `json:"category,omitempty"`
+	// Pronunciation audio URL
+	Audio string `json:"audio,omitempty"`
+}
+
+// Category groups names by the attribute they describe
+type Category struct {
+	Name  string `json:"name"`
+	Title string `json:"title"`
+	Names []int  `json:"names"`
+}
+
+// Reference is a verse of the Quran in which a name appears
+type Reference struct {
+	Key     string `json:"key"`
+	Chapter int    `json:"chapter"`
+	Verse   int    `json:"verse"`
+	Arabic  string `json:"arabic"`
+	Text    string `json:"text"`
 }
 
 type Names []*Name
@@ -41,6 +69,12 @@ func (name *Name) HTML() string {
 	data += `<div class="mb-6 p-6 bg-white border border-gray-200 rounded-lg shadow-sm">`
 	data += `<div class="arabic text-center text-4xl mb-4">` + name.Arabic + `</div>`
 	data += `<h3 class="text-xl font-semibold text-center text-gray-700">` + name.English + `</h3>`
+	if len(name.Audio) > 0 {
+		data += fmt.Sprintf(`<div class="flex justify-center mt-4"><audio controls preload="none" src="%s"></audio></div>`, name.Audio)
+	}
+	if len(name.Category) > 0 {
+		data += fmt.Sprintf(`<p class="text-sm text-center text-gray-500 mt-2"><a href="/names#%s" class="hover:underline">%s</a></p>`, name.Category, name.Category)
+	}
 	data += `</div>`
 
 	// Description card
@@ -65,21 +99,148 @@ func (name *Name) HTML() string {
 		data += fmt.Sprintf(`<a href="%s" class="px-3 py-1 bg-blue-50 text-blue-600 rounded hover:bg-blue-100 transition-colors" hx-get="%s" hx-target="#main" hx-swap="innerHTML" hx-push-url="true">%s</a>`, uri, uri, loc)
 	}
 	data += `</div>`
+
+	// Verse text
+	for _, ref := range name.Verses {
+		uri := fmt.Sprintf("/quran/%d#%d", ref.Chapter, ref.Verse)
+		data += `<div class="mt-4 pt-4 border-t border-gray-100">`
+		data += fmt.Sprintf(`<a href="%s" class="text-sm font-semibold text-gray-700 hover:text-blue-600">%s</a>`, uri, ref.Key)
+		data += `<div class="arabic text-right text-2xl my-2 leading-relaxed">` + ref.Arabic + `</div>`
+		data += `<div class="text-gray-700">` + ref.Text + `</div>`
+		data += `</div>`
+	}
 	data += `</div>`
 
 	return data
 }
 
+// parseLocation parses a location such as 2:255 or 82:10-12 into a chapter
+// and range of verses
+func parseLocation(loc string) (chapter, start, end int, err error) {
+	parts := strings.SplitN(loc, ":", 2)
+	if len(parts) != 2 {
+		return 0, 0, 0, fmt.Errorf("invalid location %q", loc)
+	}
+
+	chapter, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid location %q", loc)
+	}
+
+	verses := strings.SplitN(parts[1], "-", 2)
+	start, err = strconv.Atoi(verses[0])
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid location %q", loc)
+	}
+
+	end = start
+	if len(verses) == 2 {
+		end, err = strconv.Atoi(verses[1])
+		if err != nil || end < start {
+			return 0, 0, 0, fmt.Errorf("invalid location %q", loc)
+		}
+	}
+
+	return chapter, start, end, nil
+}
+
+// Link resolves the location of each name against the Quran, recording the
+// verses on the name and the name on each verse, once however many times
+// the location lists the verse.
+func (n *Names) Link(q *quran.Quran) {
+	for _, ch := range q.Chapters {
+		for _, v := range ch.Verses {
+			v.Names = nil
+		}
+	}
+
+	for _, name := range *n {
+		name.Verses = nil
+		seen := make(map[*quran.Verse]bool)
+
+		for _, loc := range name.Location {
+			chapter, start, end, err := parseLocation(loc)
+			if err != nil || chapter < 1 || chapter > len(q.Chapters) {
+				continue
+			}
+
+			ch := q.Get(chapter)
+
+			for ve := start; ve <= end; ve++ {
+				v := ch.Verse(ve)
+				if v == nil || ve < 1 || seen[v] {
+					continue
+				}
+				seen[v] = true
+
+				name.Verses = append(name.Verses, &Reference{
+					Key:     fmt.Sprintf("%d:%d", chapter, ve),
+					Chapter: chapter,
+					Verse:   ve,
+					Arabic:  v.Arabic,
+					Text:    v.Text,
+				})
+
+				v.Names = append(v.Names, name.Number)
+			}
+		}
+	}
+}
+
 func (n *Names) Get(id int) *Name {
 	return (*n)[id-1]
 }
 
+// Category returns the names in a category, or false if there's no such
+// category
+func (n *Names) Category(category string) (*Names, bool) {
+	if !hasCategory(category) {
+		return nil, false
+	}
+	names := &Names{}
+	for _, name := range *n {
+		if name.Category == category {
+			*names = append(*names, name)
+		}
+	}
+	return names, true
+}
+
+func hasCategory(name string) bool {
+	for _, cat := range Categories() {
+		if cat.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SetAudio sets the pronunciation audio for each name which has a file
+// named by its number e.g 1.mp3 in dir, served under the url prefix.
+func (n *Names) SetAudio(dir, prefix string) {
+	for _, name := range *n {
+		file := fmt.Sprintf("%d.mp3", name.Number)
+		if _, err := os.Stat(filepath.Join(dir, file)); err == nil {
+			name.Audio = prefix + file
+		} else {
+			name.Audio = ""
+		}
+	}
+}
+
 func (n *Names) TOC() string {
 	var data string
 
 	data += `<div id="contents" class="space-y-2">`
-	for _, name := range *n {
-		data += fmt.Sprintf(`<a href="/names/%d" hx-get="/names/%d" hx-target="#main" hx-swap="innerHTML" hx-push-url="true" class="block p-3 bg-white border border-gray-200 rounded-lg hover:border-gray-400 transition-colors">%d: %s</a>`, name.Number, name.Number, name.Number, name.Meaning)
+	for _, cat := range Categories() {
+		data += fmt.Sprintf(`<h3 id="%s" class="text-lg font-semibold pt-4">%s</h3>`, cat.Name, cat.Title)
+		for _, number := range cat.Names {
+			if number < 1 || number > len(*n) {
+				continue
+			}
+			name := n.Get(number)
+			data += fmt.Sprintf(`<a href="/names/%d" hx-get="/names/%d" hx-target="#main" hx-swap="innerHTML" hx-push-url="true" class="block p-3 bg-white border border-gray-200 rounded-lg hover:border-gray-400 transition-colors">%d: %s</a>`, name.Number, name.Number, name.Number, name.Meaning)
+		}
 	}
 	data += `</div>`
 
@@ -145,7 +306,7 @@ func Load() *Names {
 		fnd := strings.Replace(n["found"].(string), " ", "", -1)
 		fnd = strings.Replace(fnd, "(", " ", -1)
 		fnd = strings.Replace(fnd, ")", " ", -1)
-		loc := strings.Split(strings.TrimSpace(fnd), " ")
+		loc := strings.Fields(fnd)
 
 		name := &Name{
 			Number:      int(n["number"].(float64)),
@@ -170,9 +331,40 @@ func Load() *Names {
 		*names = append(*names, name)
 	}
 
+	// set the categories
+	for _, cat := range Categories() {
+		for _, number := range cat.Names {
+			if number >= 1 && number <= len(*names) {
+				names.Get(number).Category = cat.Name
+			}
+		}
+	}
+
 	return names
 }
 
+// Categories returns the attribute categories of the names
+func Categories() []*Category {
+	return categories()
+}
+
+// categories are parsed from the embedded data once
+var categories = sync.OnceValue(func() []*Category {
+	f, err := files.ReadFile("data/categories.json")
+	if err != nil {
+		return nil
+	}
+
+	var data struct {
+		Categories []*Category `json:"categories"`
+	}
+	if err := json.Unmarshal(f, &data); err != nil {
+		return nil
+	}
+
+	return data.Categories
+})
+
 func Markdown() string {
 	return Load().Markdown()
 }
@@ -0,0 +1,113 @@
+package names
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/asim/reminder/quran"
+)
+
+func TestCategory(t *testing.T) {
+	n := Load()
+
+	mercy, ok := n.Category("mercy")
+	if !ok || len(*mercy) == 0 {
+		t.Fatalf("expected names in mercy, got %v %v", mercy, ok)
+	}
+	for _, name := range *mercy {
+		if name.Category != "mercy" {
+			t.Fatalf("expected %d to be in mercy, got %q", name.Number, name.Category)
+		}
+	}
+
+	if _, ok := n.Category("unknown"); ok {
+		t.Fatal("expected an unknown category to be reported")
+	}
+
+	// every name listed in a category is assigned it
+	for _, cat := range Categories() {
+		names, _ := n.Category(cat.Name)
+		if len(*names) != len(cat.Names) {
+			t.Fatalf("expected %d names in %s, got %d", len(cat.Names), cat.Name, len(*names))
+		}
+	}
+}
+
+func TestParseLocation(t *testing.T) {
+	for _, c := range []struct {
+		loc                 string
+		chapter, start, end int
+	}{
+		{"2:255", 2, 255, 255},
+		{"82:10-12", 82, 10, 12},
+	} {
+		chapter, start, end, err := parseLocation(c.loc)
+		if err != nil || chapter != c.chapter || start != c.start || end != c.end {
+			t.Fatalf("%s: expected %d:%d-%d, got %d:%d-%d %v", c.loc, c.chapter, c.start, c.end, chapter, start, end, err)
+		}
+	}
+
+	for _, loc := range []string{"", "2", "x:1", "2:x", "2:5-3", "2:1-x"} {
+		if _, _, _, err := parseLocation(loc); err == nil {
+			t.Fatalf("%q: expected error", loc)
+		}
+	}
+}
+
+func TestLink(t *testing.T) {
+	q := &quran.Quran{Chapters: []*quran.Chapter{
+		{Number: 1, Verses: []*quran.Verse{{Chapter: 1, Number: 0}, {Chapter: 1, Number: 1}, {Chapter: 1, Number: 2}, {Chapter: 1, Number: 3}}},
+		{Number: 2, Verses: []*quran.Verse{{Chapter: 2, Number: 1, Text: "Alif, Lam, Meem"}}},
+	}}
+	n := &Names{
+		// listed twice at the same verse and in overlapping ranges
+		{Number: 1, Location: []string{"1:1", "1:1", "1:1-2", "2:1"}},
+		// out of range and invalid locations are skipped
+		{Number: 2, Location: []string{"1:0", "1:3-5", "3:1", "bad"}},
+	}
+	n.Link(q)
+
+	var keys []string
+	for _, ref := range n.Get(1).Verses {
+		keys = append(keys, ref.Key)
+	}
+	if !reflect.DeepEqual(keys, []string{"1:1", "1:2", "2:1"}) {
+		t.Fatalf("expected each verse once, got %v", keys)
+	}
+	if ref := n.Get(1).Verses[2]; ref.Text != "Alif, Lam, Meem" {
+		t.Fatalf("expected the verse text, got %+v", ref)
+	}
+	if got := q.Get(1).Verse(1).Names; !reflect.DeepEqual(got, []int{1}) {
+		t.Fatalf("expected the name once on the verse, got %v", got)
+	}
+	if got := n.Get(2).Verses; len(got) != 1 || got[0].Key != "1:3" {
+		t.Fatalf("expected only 1:3, got %+v", got)
+	}
+	if got := q.Get(1).Verse(0).Names; len(got) != 0 {
+		t.Fatalf("expected no names on the bismillah, got %v", got)
+	}
+
+	// linking again replaces rather than adds
+	n.Link(q)
+	if got := q.Get(1).Verse(3).Names; !reflect.DeepEqual(got, []int{2}) {
+		t.Fatalf("expected relinking to replace names, got %v", got)
+	}
+}
+
+func TestSetAudio(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "2.mp3"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	n := &Names{{Number: 1, Audio: "/old/1.mp3"}, {Number: 2}}
+	n.SetAudio(dir, "/audio/names/")
+
+	if got := n.Get(1).Audio; got != "" {
+		t.Fatalf("expected no audio without a file, got %q", got)
+	}
+	if got := n.Get(2).Audio; got != "/audio/names/2.mp3" {
+		t.Fatalf("expected audio url, got %q", got)
+	}
+}
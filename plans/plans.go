@@ -0,0 +1,230 @@
+// Package plans computes reading plans to complete the Quran (khatm)
+package plans
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/asim/reminder/quran"
+)
+
+// Methods of dividing the Quran into daily portions
+const (
+	// Equal portions by word count over a number of days
+	MethodDays = "days"
+	// Whole juz per day
+	MethodJuz = "juz"
+	// A number of pages per day
+	MethodPages = "pages"
+	// Equal portions over chosen dates
+	MethodCustom = "custom"
+)
+
+var Methods = []string{MethodDays, MethodJuz, MethodPages, MethodCustom}
+
+// Pages in the standard Madinah mushaf, used to approximate pages by words
+const Pages = 604
+
+// JuzStart is the first verse of each of the 30 juz
+var JuzStart = []Ref{
+	{1, 1}, {2, 142}, {2, 253}, {3, 93}, {4, 24}, {4, 148}, {5, 82}, {6, 111}, {7, 88}, {8, 41},
+	{9, 93}, {11, 6}, {12, 53}, {15, 1}, {17, 1}, {18, 75}, {21, 1}, {23, 1}, {25, 21}, {27, 56},
+	{29, 46}, {33, 31}, {36, 28}, {39, 32}, {41, 47}, {46, 1}, {51, 31}, {58, 1}, {67, 1}, {78, 1},
+}
+
+// Ref is a verse reference
+type Ref struct {
+	Chapter int `json:"chapter"`
+	Verse   int `json:"verse"`
+}
+
+func (r Ref) String() string {
+	return fmt.Sprintf("%d:%d", r.Chapter, r.Verse)
+}
+
+// ParseRef parses a chapter:verse key
+func ParseRef(key string) (Ref, error) {
+	var r Ref
+	if _, err := fmt.Sscanf(key, "%d:%d", &r.Chapter, &r.Verse); err != nil {
+		return r, fmt.Errorf("invalid verse %q", key)
+	}
+	return r, nil
+}
+
+// Portion is the reading for one day of a plan
+type Portion struct {
+	Day    int    `json:"day"`
+	Date   string `json:"date"`
+	Start  Ref    `json:"start"`
+	End    Ref    `json:"end"`
+	Verses int    `json:"verses"`
+	Words  int    `json:"words"`
+}
+
+// Text describes the portion e.g Read 2:142 to 2:252
+func (p *Portion) Text() string {
+	return fmt.Sprintf("Day %d: read %s to %s", p.Day, p.Start, p.End)
+}
+
+// Options to create a plan
+type Options struct {
+	Method string `json:"method"`
+	// First day of the plan as YYYY-MM-DD, defaults to today
+	Start string `json:"start"`
+	// Number of days for the days and juz methods, 30 by default
+	Days int `json:"days"`
+	// Pages per day for the pages method, 20 by default
+	Pages int `json:"pages"`
+	// Reading dates as YYYY-MM-DD for the custom method
+	Dates []string `json:"dates"`
+}
+
+// index of the verses of the Quran in order with their word counts
+type index struct {
+	refs  []Ref
+	words []int
+	pos   map[Ref]int
+}
+
+func newIndex(q *quran.Quran) *index {
+	idx := &index{pos: map[Ref]int{}}
+	for _, ch := range q.Chapters {
+		for _, v := range ch.Verses {
+			// skip the bismillah prepended to chapters
+			if v.Number == 0 {
+				continue
+			}
+			ref := Ref{ch.Number, v.Number}
+			idx.pos[ref] = len(idx.refs)
+			idx.refs = append(idx.refs, ref)
+			w := len(v.Words)
+			if w == 0 {
+				w = 1
+			}
+			idx.words = append(idx.words, w)
+		}
+	}
+	return idx
+}
+
+// split divides verses [from, to) into n ranges of roughly equal words
+// returning the start index of each range
+func (idx *index) split(from, to, n int) []int {
+	var total int
+	for i := from; i < to; i++ {
+		total += idx.words[i]
+	}
+
+	starts := []int{from}
+	var sum int
+	next := 1
+	for i := from; i < to && next < n; i++ {
+		sum += idx.words[i]
+		// start the next portion once this one has its share
+		if float64(sum) >= float64(total)*float64(next)/float64(n) && i+1 < to {
+			starts = append(starts, i+1)
+			next++
+		}
+	}
+	return starts
+}
+
+// portions builds portions from start indexes over the dates
+func (idx *index) portions(starts []int, dates []string) []*Portion {
+	var result []*Portion
+	for i, s := range starts {
+		e := len(idx.refs)
+		if i+1 < len(starts) {
+			e = starts[i+1]
+		}
+		p := &Portion{
+			Day:    i + 1,
+			Date:   dates[i],
+			Start:  idx.refs[s],
+			End:    idx.refs[e-1],
+			Verses: e - s,
+		}
+		for j := s; j < e; j++ {
+			p.Words += idx.words[j]
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+func consecutive(start time.Time, n int) []string {
+	dates := make([]string, n)
+	for i := range dates {
+		dates[i] = start.AddDate(0, 0, i).Format("2006-01-02")
+	}
+	return dates
+}
+
+// Portions divides the Quran into daily portions using the options
+func Portions(q *quran.Quran, opts Options, today time.Time) ([]*Portion, error) {
+	idx := newIndex(q)
+
+	start := today
+	if len(opts.Start) > 0 {
+		t, err := time.Parse("2006-01-02", opts.Start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start date %q", opts.Start)
+		}
+		start = t
+	}
+
+	days := opts.Days
+	if days == 0 {
+		days = 30
+	}
+
+	switch opts.Method {
+	case MethodDays, "":
+		if days < 1 || days > len(idx.refs) {
+			return nil, fmt.Errorf("days must be between 1 and %d", len(idx.refs))
+		}
+		return idx.portions(idx.split(0, len(idx.refs), days), consecutive(start, days)), nil
+	case MethodJuz:
+		if days < 1 || days > len(JuzStart) {
+			return nil, fmt.Errorf("days must be between 1 and %d for juz", len(JuzStart))
+		}
+		// group whole juz as evenly as possible
+		var starts []int
+		for i := 0; i < days; i++ {
+			juz := int(math.Round(float64(i) * float64(len(JuzStart)) / float64(days)))
+			starts = append(starts, idx.pos[JuzStart[juz]])
+		}
+		return idx.portions(starts, consecutive(start, days)), nil
+	case MethodPages:
+		pages := opts.Pages
+		if pages == 0 {
+			pages = 20
+		}
+		if pages < 1 || pages > Pages {
+			return nil, fmt.Errorf("pages must be between 1 and %d", Pages)
+		}
+		days = (Pages + pages - 1) / pages
+		return idx.portions(idx.split(0, len(idx.refs), days), consecutive(start, days)), nil
+	case MethodCustom:
+		if len(opts.Dates) == 0 {
+			return nil, fmt.Errorf("dates are required for custom plans")
+		}
+		dates := append([]string{}, opts.Dates...)
+		for _, d := range dates {
+			if _, err := time.Parse("2006-01-02", d); err != nil {
+				return nil, fmt.Errorf("invalid date %q", d)
+			}
+		}
+		sort.Strings(dates)
+		for i := 1; i < len(dates); i++ {
+			if dates[i] == dates[i-1] {
+				return nil, fmt.Errorf("duplicate date %q", dates[i])
+			}
+		}
+		return idx.portions(idx.split(0, len(idx.refs), len(dates)), dates), nil
+	default:
+		return nil, fmt.Errorf("invalid method %q", opts.Method)
+	}
+}
@@ -0,0 +1,100 @@
+package plans
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asim/reminder/quran"
+)
+
+var q = quran.Load()
+
+func TestPortions(t *testing.T) {
+	today := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+
+	juz, err := Portions(q, Options{Method: MethodJuz}, today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(juz) != 30 {
+		t.Fatalf("expected 30 portions, got %d", len(juz))
+	}
+	for i, p := range juz {
+		if p.Start != JuzStart[i] {
+			t.Fatalf("day %d: expected start %s, got %s", p.Day, JuzStart[i], p.Start)
+		}
+	}
+	if last := juz[29]; last.End != (Ref{114, 6}) || last.Date != "2024-04-09" {
+		t.Fatalf("unexpected last portion %+v", last)
+	}
+
+	// two juz a day
+	fifteen, _ := Portions(q, Options{Method: MethodJuz, Days: 15}, today)
+	if len(fifteen) != 15 || fifteen[1].Start != JuzStart[2] {
+		t.Fatalf("unexpected 15 day juz plan %+v", fifteen[1])
+	}
+
+	days, err := Portions(q, Options{Method: MethodDays, Days: 30}, today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var verses, words int
+	for i, p := range days {
+		verses += p.Verses
+		words += p.Words
+		if i > 0 && days[i-1].End == p.Start {
+			t.Fatalf("portions overlap at %s", p.Start)
+		}
+	}
+	if verses != 6236 {
+		t.Fatalf("expected every verse to be covered, got %d", verses)
+	}
+	// word balanced portions are within a verse or so of the average
+	for _, p := range days {
+		if p.Words < words/30-200 || p.Words > words/30+200 {
+			t.Fatalf("day %d unbalanced: %d words of %d average", p.Day, p.Words, words/30)
+		}
+	}
+
+	pages, _ := Portions(q, Options{Method: MethodPages, Pages: 10}, today)
+	if len(pages) != 61 {
+		t.Fatalf("expected 61 days at 10 pages a day, got %d", len(pages))
+	}
+
+	custom, err := Portions(q, Options{Method: MethodCustom, Dates: []string{"2024-03-15", "2024-03-12", "2024-03-20"}}, today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(custom) != 3 || custom[0].Date != "2024-03-12" || custom[2].Date != "2024-03-20" {
+		t.Fatalf("unexpected custom portions %+v", custom)
+	}
+
+	if _, err := Portions(q, Options{Method: MethodJuz, Days: 60}, today); err == nil {
+		t.Fatal("expected more days than juz to be rejected")
+	}
+}
+
+func TestProgress(t *testing.T) {
+	start := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	portions, _ := Portions(q, Options{Method: MethodJuz}, start)
+	p := &Plan{TimeZone: "UTC", Portions: portions}
+
+	// third day with nothing read
+	now := start.AddDate(0, 0, 2).Add(12 * time.Hour)
+	pr := p.Progress(q, now)
+	if pr.Resume != (Ref{1, 1}) || pr.Behind != 3 || pr.Today.Day != 3 {
+		t.Fatalf("unexpected progress %+v", pr)
+	}
+
+	// read to the end of the second juz
+	p.Position = Ref{2, 252}
+	pr = p.Progress(q, now)
+	if pr.Resume != (Ref{2, 253}) || pr.Behind != 1 {
+		t.Fatalf("unexpected progress %+v", pr)
+	}
+
+	p.Position = Ref{114, 6}
+	if pr = p.Progress(q, now); !pr.Complete || pr.Percent != 100 || pr.Behind != -27 {
+		t.Fatalf("unexpected progress %+v", pr)
+	}
+}
@@ -0,0 +1,245 @@
+package plans
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asim/reminder/api"
+	"github.com/asim/reminder/quran"
+)
+
+// Plan is a user's plan to complete the Quran
+type Plan struct {
+	ID     string `json:"id"`
+	User   string `json:"user"`
+	Name   string `json:"name"`
+	Method string `json:"method"`
+	// IANA time zone used to decide the current day
+	TimeZone string     `json:"timezone,omitempty"`
+	Portions []*Portion `json:"portions"`
+	// Last verse read, zero before starting
+	Position Ref `json:"position"`
+	// Push subscription endpoint to deliver the day's portion to
+	Endpoint string    `json:"endpoint,omitempty"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+}
+
+// Progress of a plan on a day
+type Progress struct {
+	Position Ref `json:"position"`
+	// Next verse to read
+	Resume Ref `json:"resume"`
+	// Portion of the plan for today, nil if there's no reading today
+	Today    *Portion `json:"today"`
+	Percent  float64  `json:"percent"`
+	Complete bool     `json:"complete"`
+	// Days behind schedule, negative when ahead
+	Behind int `json:"behind"`
+}
+
+var plansFile = api.ReminderPath("plans.json")
+var mtx sync.RWMutex
+var plans = map[string]*Plan{}
+
+func Load() error {
+	f, err := os.Open(plansFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	mtx.Lock()
+	defer mtx.Unlock()
+	return json.NewDecoder(f).Decode(&plans)
+}
+
+// save writes the plans, the caller must hold the lock
+func save() error {
+	b, err := json.Marshal(plans)
+	if err != nil {
+		return err
+	}
+	return api.WriteFile(plansFile, b, 0600)
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func location(tz string) *time.Location {
+	if loc, err := time.LoadLocation(tz); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// Create computes and saves a new plan for the user
+func Create(q *quran.Quran, user, name, tz, endpoint string, opts Options) (*Plan, error) {
+	if _, err := time.LoadLocation(tz); err != nil {
+		return nil, fmt.Errorf("invalid timezone %q", tz)
+	}
+
+	portions, err := Portions(q, opts, time.Now().In(location(tz)))
+	if err != nil {
+		return nil, err
+	}
+
+	method := opts.Method
+	if len(method) == 0 {
+		method = MethodDays
+	}
+	if len(name) == 0 {
+		name = fmt.Sprintf("Complete the Quran in %d days", len(portions))
+	}
+
+	p := &Plan{
+		ID:       newID(),
+		User:     user,
+		Name:     name,
+		Method:   method,
+		TimeZone: tz,
+		Portions: portions,
+		Endpoint: endpoint,
+		Created:  time.Now(),
+		Updated:  time.Now(),
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	plans[p.ID] = p
+	return copyPlan(p), save()
+}
+
+func copyPlan(p *Plan) *Plan {
+	c := *p
+	return &c
+}
+
+// Get returns a plan
+func Get(id string) (*Plan, bool) {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	p, ok := plans[id]
+	if !ok {
+		return nil, false
+	}
+	return copyPlan(p), true
+}
+
+// List returns the user's plans, newest first
+func List(user string) []*Plan {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	result := []*Plan{}
+	for _, p := range plans {
+		if p.User == user {
+			result = append(result, copyPlan(p))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Created.After(result[j].Created)
+	})
+	return result
+}
+
+// ForEndpoint returns the plans delivered to a push subscription
+func ForEndpoint(endpoint string) []*Plan {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	var result []*Plan
+	for _, p := range plans {
+		if len(endpoint) > 0 && p.Endpoint == endpoint {
+			result = append(result, copyPlan(p))
+		}
+	}
+	return result
+}
+
+// Delete removes a plan
+func Delete(id string) error {
+	mtx.Lock()
+	defer mtx.Unlock()
+	delete(plans, id)
+	return save()
+}
+
+// SetPosition records the last verse read
+func SetPosition(q *quran.Quran, id string, ref Ref) error {
+	if _, ok := newIndex(q).pos[ref]; !ok {
+		return fmt.Errorf("invalid verse %s", ref)
+	}
+	mtx.Lock()
+	defer mtx.Unlock()
+	p, ok := plans[id]
+	if !ok {
+		return fmt.Errorf("plan not found")
+	}
+	p.Position = ref
+	p.Updated = time.Now()
+	return save()
+}
+
+// Today returns the portion for the current day in the plan's time zone
+func (p *Plan) Today(now time.Time) *Portion {
+	date := now.In(location(p.TimeZone)).Format("2006-01-02")
+	for _, portion := range p.Portions {
+		if portion.Date == date {
+			return portion
+		}
+	}
+	return nil
+}
+
+// Progress returns the progress of the plan at now
+func (p *Plan) Progress(q *quran.Quran, now time.Time) *Progress {
+	idx := newIndex(q)
+
+	// index of the next verse to read
+	next := 0
+	if i, ok := idx.pos[p.Position]; ok {
+		next = i + 1
+	}
+
+	pr := &Progress{
+		Position: p.Position,
+		Today:    p.Today(now),
+		Percent:  percent(next, len(idx.refs)),
+		Complete: next >= len(idx.refs),
+	}
+	if !pr.Complete {
+		pr.Resume = idx.refs[next]
+	}
+
+	// portions which should have been read by the end of today
+	date := now.In(location(p.TimeZone)).Format("2006-01-02")
+	var due, done int
+	for _, portion := range p.Portions {
+		if portion.Date <= date {
+			due++
+		}
+		if idx.pos[portion.End] < next {
+			done++
+		}
+	}
+	pr.Behind = due - done
+
+	return pr
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(int(float64(n)/float64(total)*1000)) / 10
+}
@@ -0,0 +1,313 @@
+// Package prayer calculates prayer times from the position of the sun
+// using the standard methods, based on the PrayTimes.org algorithm
+package prayer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Method is a convention for the angle of the sun at Fajr and Isha
+type Method struct {
+	Name string `json:"name"`
+	// Angle of the sun below the horizon at Fajr
+	Fajr float64 `json:"fajr"`
+	// Angle of the sun below the horizon at Isha
+	Isha float64 `json:"isha,omitempty"`
+	// Minutes after Maghrib for Isha, used instead of the angle
+	IshaMinutes float64 `json:"isha_minutes,omitempty"`
+}
+
+// Methods of calculation by id
+var Methods = map[string]*Method{
+	"mwl":         {Name: "Muslim World League", Fajr: 18, Isha: 17},
+	"isna":        {Name: "Islamic Society of North America", Fajr: 15, Isha: 15},
+	"egyptian":    {Name: "Egyptian General Authority of Survey", Fajr: 19.5, Isha: 17.5},
+	"karachi":     {Name: "University of Islamic Sciences, Karachi", Fajr: 18, Isha: 18},
+	"umm_al_qura": {Name: "Umm al-Qura University, Makkah", Fajr: 18.5, IshaMinutes: 90},
+}
+
+// DefaultMethod is used when none is given
+const DefaultMethod = "mwl"
+
+// Juristic methods for Asr
+const (
+	// The shadow of an object equals its length plus its noon shadow
+	Shafii = "shafii"
+	// The shadow is twice the length
+	Hanafi = "hanafi"
+)
+
+// Rules for Fajr and Isha at high latitudes where the sun may not reach
+// the angle of the method
+const (
+	// No adjustment
+	HighLatitudeNone = "none"
+	// Fajr and Isha are no further than half the night from sunrise and sunset
+	HighLatitudeMiddle = "middle"
+	// No further than a seventh of the night
+	HighLatitudeSeventh = "seventh"
+	// No further than the angle divided by 60 of the night
+	HighLatitudeAngle = "angle"
+)
+
+// Options for the calculation, defaults are used for empty fields
+type Options struct {
+	// Method id e.g mwl
+	Method string
+	// Asr method, shafii or hanafi
+	Asr string
+	// High latitude rule e.g angle
+	HighLatitude string
+	// Timezone of the returned times, defaults to UTC
+	Location *time.Location
+}
+
+// Times of the prayers on a day
+type Times struct {
+	Fajr    time.Time `json:"fajr"`
+	Sunrise time.Time `json:"sunrise"`
+	Dhuhr   time.Time `json:"dhuhr"`
+	Asr     time.Time `json:"asr"`
+	Maghrib time.Time `json:"maghrib"`
+	Isha    time.Time `json:"isha"`
+}
+
+// Prayers names the times in order
+var Prayers = []string{"fajr", "sunrise", "dhuhr", "asr", "maghrib", "isha"}
+
+// Get returns a time by name e.g fajr
+func (t *Times) Get(name string) (time.Time, bool) {
+	switch strings.ToLower(name) {
+	case "fajr":
+		return t.Fajr, true
+	case "sunrise":
+		return t.Sunrise, true
+	case "dhuhr":
+		return t.Dhuhr, true
+	case "asr":
+		return t.Asr, true
+	case "maghrib":
+		return t.Maghrib, true
+	case "isha":
+		return t.Isha, true
+	}
+	return time.Time{}, false
+}
+
+// MethodIDs returns the ids of the methods in order
+func MethodIDs() []string {
+	var ids []string
+	for id := range Methods {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (o *Options) defaults() error {
+	if len(o.Method) == 0 {
+		o.Method = DefaultMethod
+	}
+	if _, ok := Methods[o.Method]; !ok {
+		return fmt.Errorf("method must be one of %s", strings.Join(MethodIDs(), ", "))
+	}
+	switch o.Asr {
+	case "":
+		o.Asr = Shafii
+	case Shafii, Hanafi:
+	default:
+		return fmt.Errorf("asr must be %s or %s", Shafii, Hanafi)
+	}
+	switch o.HighLatitude {
+	case "":
+		o.HighLatitude = HighLatitudeAngle
+	case HighLatitudeNone, HighLatitudeMiddle, HighLatitudeSeventh, HighLatitudeAngle:
+	default:
+		return fmt.Errorf("high latitude rule must be none, middle, seventh or angle")
+	}
+	if o.Location == nil {
+		o.Location = time.UTC
+	}
+	return nil
+}
+
+// validCoordinates reports whether the latitude and longitude are in range.
+// NaN fails every comparison so it's checked for explicitly.
+func validCoordinates(lat, lon float64) bool {
+	if math.IsNaN(lat) || math.IsNaN(lon) || math.IsInf(lat, 0) || math.IsInf(lon, 0) {
+		return false
+	}
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
+
+// Calculate returns the prayer times at the latitude and longitude on the
+// date. Times the sun doesn't reach are adjusted by the high latitude rule,
+// or an error is returned if they can't be.
+func Calculate(lat, lon float64, date time.Time, opts Options) (*Times, error) {
+	if !validCoordinates(lat, lon) {
+		return nil, fmt.Errorf("invalid coordinates %v,%v", lat, lon)
+	}
+	if err := opts.defaults(); err != nil {
+		return nil, err
+	}
+
+	method := Methods[opts.Method]
+	c := &calc{lat: lat, jdate: julian(date.Year(), int(date.Month()), date.Day()) - lon/(15*24)}
+
+	asrFactor := 1.0
+	if opts.Asr == Hanafi {
+		asrFactor = 2
+	}
+
+	// initial estimates in hours, refined by the calculation
+	fajr := c.sunAngleTime(method.Fajr, 5.0/24, true)
+	sunrise := c.sunAngleTime(riseSetAngle, 6.0/24, true)
+	dhuhr := c.midDay(12.0 / 24)
+	asr := c.asrTime(asrFactor, 13.0/24)
+	sunset := c.sunAngleTime(riseSetAngle, 18.0/24, false)
+	isha := math.NaN()
+	if method.IshaMinutes == 0 {
+		isha = c.sunAngleTime(method.Isha, 18.0/24, false)
+	}
+
+	if math.IsNaN(sunrise) || math.IsNaN(sunset) {
+		return nil, fmt.Errorf("the sun doesn't rise or set at %v,%v on %s", lat, lon, date.Format("2006-01-02"))
+	}
+
+	// adjust fajr and isha where the sun doesn't reach the angle or they
+	// are too far into the night
+	if opts.HighLatitude != HighLatitudeNone {
+		night := fixHour(sunrise - sunset)
+
+		portion := func(angle float64) float64 {
+			switch opts.HighLatitude {
+			case HighLatitudeMiddle:
+				return night / 2
+			case HighLatitudeSeventh:
+				return night / 7
+			}
+			return angle / 60 * night
+		}
+
+		if p := portion(method.Fajr); math.IsNaN(fajr) || fixHour(sunrise-fajr) > p {
+			fajr = sunrise - p
+		}
+		if method.IshaMinutes == 0 {
+			if p := portion(method.Isha); math.IsNaN(isha) || fixHour(isha-sunset) > p {
+				isha = sunset + p
+			}
+		}
+	}
+
+	if method.IshaMinutes > 0 {
+		isha = sunset + method.IshaMinutes/60
+	}
+
+	if math.IsNaN(fajr) || math.IsNaN(isha) || math.IsNaN(asr) {
+		return nil, fmt.Errorf("the sun doesn't reach the angles of the %s method at %v,%v on %s, use a high latitude rule", opts.Method, lat, lon, date.Format("2006-01-02"))
+	}
+
+	// hours are local solar time, converted to UTC from midnight UTC
+	midnight := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	at := func(hours float64) time.Time {
+		hours -= lon / 15
+		t := midnight.Add(time.Duration(hours * float64(time.Hour)))
+		return t.Round(time.Minute).In(opts.Location)
+	}
+
+	return &Times{
+		Fajr:    at(fajr),
+		Sunrise: at(sunrise),
+		Dhuhr:   at(dhuhr),
+		Asr:     at(asr),
+		Maghrib: at(sunset),
+		Isha:    at(isha),
+	}, nil
+}
+
+// angle of the sun below the horizon at sunrise and sunset accounting for
+// refraction and the radius of the sun
+const riseSetAngle = 0.833
+
+type calc struct {
+	lat   float64
+	jdate float64
+}
+
+// midDay is the time of the sun's transit in hours for the day portion
+func (c *calc) midDay(portion float64) float64 {
+	_, eqt := sunPosition(c.jdate + portion)
+	return fixHour(12 - eqt)
+}
+
+// sunAngleTime is the time in hours the sun is the angle below the horizon,
+// before noon if ccw otherwise after, or NaN if it doesn't reach it
+func (c *calc) sunAngleTime(angle, portion float64, ccw bool) float64 {
+	decl, _ := sunPosition(c.jdate + portion)
+	noon := c.midDay(portion)
+	t := arccos((-sin(angle)-sin(decl)*sin(c.lat))/(cos(decl)*cos(c.lat))) / 15
+	if ccw {
+		return noon - t
+	}
+	return noon + t
+}
+
+// asrTime is the time shadows are the factor times the length of objects
+// plus their noon shadow
+func (c *calc) asrTime(factor, portion float64) float64 {
+	decl, _ := sunPosition(c.jdate + portion)
+	angle := -arccot(factor + tan(math.Abs(c.lat-decl)))
+	return c.sunAngleTime(angle, portion, false)
+}
+
+// sunPosition returns the declination of the sun and the equation of time
+// for the julian date
+func sunPosition(jd float64) (decl, eqt float64) {
+	d := jd - 2451545.0
+	g := fixAngle(357.529 + 0.98560028*d)
+	q := fixAngle(280.459 + 0.98564736*d)
+	l := fixAngle(q + 1.915*sin(g) + 0.020*sin(2*g))
+	e := 23.439 - 0.00000036*d
+
+	ra := fixHour(arctan2(cos(e)*sin(l), cos(l)) / 15)
+	eqt = q/15 - ra
+	decl = arcsin(sin(e) * sin(l))
+	return decl, eqt
+}
+
+// julian returns the julian date at midnight
+func julian(year, month, day int) float64 {
+	if month <= 2 {
+		year--
+		month += 12
+	}
+	a := math.Floor(float64(year) / 100)
+	b := 2 - a + math.Floor(a/4)
+	return math.Floor(365.25*float64(year+4716)) + math.Floor(30.6001*float64(month+1)) + float64(day) + b - 1524.5
+}
+
+func rad(d float64) float64 { return d * math.Pi / 180 }
+func deg(r float64) float64 { return r * 180 / math.Pi }
+
+func sin(d float64) float64        { return math.Sin(rad(d)) }
+func cos(d float64) float64        { return math.Cos(rad(d)) }
+func tan(d float64) float64        { return math.Tan(rad(d)) }
+func arcsin(x float64) float64     { return deg(math.Asin(x)) }
+func arccos(x float64) float64     { return deg(math.Acos(x)) }
+func arccot(x float64) float64     { return deg(math.Atan(1 / x)) }
+func arctan2(y, x float64) float64 { return deg(math.Atan2(y, x)) }
+
+func fix(a, b float64) float64 {
+	a = a - b*math.Floor(a/b)
+	if a < 0 {
+		return a + b
+	}
+	return a
+}
+
+func fixAngle(a float64) float64 { return fix(a, 360) }
+func fixHour(a float64) float64  { return fix(a, 24) }
@@ -0,0 +1,223 @@
+package prayer
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+// minutes between the time and the clock time e.g 04:43
+func diff(t time.Time, clock string) float64 {
+	c, _ := time.ParseInLocation("2006-01-02 15:04", t.Format("2006-01-02 ")+clock, t.Location())
+	return t.Sub(c).Abs().Minutes()
+}
+
+func TestPublishedTables(t *testing.T) {
+	london, _ := time.LoadLocation("Europe/London")
+	newYork, _ := time.LoadLocation("America/New_York")
+
+	// sunrise, solar noon and sunset from timeanddate.com
+	for _, c := range []struct {
+		lat, lon float64
+		loc      *time.Location
+		date     string
+		sunrise  string
+		noon     string
+		sunset   string
+	}{
+		{51.5074, -0.1278, london, "2024-06-21", "04:43", "13:02", "21:21"},
+		{51.5074, -0.1278, london, "2024-12-21", "08:03", "11:58", "15:53"},
+		{40.7128, -74.0060, newYork, "2024-12-21", "07:16", "11:54", "16:32"},
+	} {
+		times, err := Calculate(c.lat, c.lon, date(c.date), Options{Location: c.loc})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, check := range []struct {
+			got      time.Time
+			expected string
+		}{
+			{times.Sunrise, c.sunrise},
+			{times.Dhuhr, c.noon},
+			{times.Maghrib, c.sunset},
+		} {
+			if diff(check.got, check.expected) > 2 {
+				t.Errorf("%s %v,%v: expected %s, got %s", c.date, c.lat, c.lon, check.expected, check.got.Format("15:04"))
+			}
+		}
+	}
+}
+
+func TestMethods(t *testing.T) {
+	makkah, _ := time.LoadLocation("Asia/Riyadh")
+
+	for _, id := range MethodIDs() {
+		times, err := Calculate(21.4225, 39.8262, date("2024-01-01"), Options{Method: id, Location: makkah})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var last time.Time
+		for _, name := range Prayers {
+			tm, _ := times.Get(name)
+			if !tm.After(last) {
+				t.Fatalf("%s: expected %s after %s", id, name, last)
+			}
+			last = tm
+		}
+		if tm := times.Fajr; tm.Location() != makkah || tm.Hour() != 5 {
+			t.Fatalf("%s: expected fajr around 5am in Makkah, got %s", id, tm)
+		}
+	}
+
+	times, _ := Calculate(21.4225, 39.8262, date("2024-01-01"), Options{Method: "umm_al_qura"})
+	if times.Isha.Sub(times.Maghrib) != 90*time.Minute {
+		t.Fatalf("expected isha 90 minutes after maghrib, got %s", times.Isha.Sub(times.Maghrib))
+	}
+
+	hanafi, _ := Calculate(21.4225, 39.8262, date("2024-01-01"), Options{Method: "umm_al_qura", Asr: Hanafi})
+	if d := hanafi.Asr.Sub(times.Asr); d < 30*time.Minute {
+		t.Fatalf("expected hanafi asr well after shafii, got %s", d)
+	}
+
+	if _, err := Calculate(0, 0, date("2024-01-01"), Options{Method: "x"}); err == nil {
+		t.Fatal("expected invalid method")
+	}
+	for _, c := range [][2]float64{{91, 0}, {math.NaN(), 0}, {0, math.NaN()}, {math.Inf(-1), 0}, {0, math.Inf(1)}} {
+		if _, err := Calculate(c[0], c[1], date("2024-01-01"), Options{}); err == nil {
+			t.Fatalf("%v,%v: expected invalid coordinates", c[0], c[1])
+		}
+	}
+}
+
+func TestHighLatitude(t *testing.T) {
+	// the sun doesn't reach 18 degrees below the horizon in Oslo in June
+	if _, err := Calculate(59.9139, 10.7522, date("2024-06-21"), Options{HighLatitude: HighLatitudeNone}); err == nil {
+		t.Fatal("expected an error without a high latitude rule")
+	}
+
+	for _, rule := range []string{HighLatitudeAngle, HighLatitudeMiddle, HighLatitudeSeventh} {
+		times, err := Calculate(59.9139, 10.7522, date("2024-06-21"), Options{HighLatitude: rule})
+		if err != nil {
+			t.Fatalf("%s: %v", rule, err)
+		}
+		night := times.Sunrise.Add(24 * time.Hour).Sub(times.Maghrib)
+		if times.Fajr.After(times.Sunrise) || times.Sunrise.Sub(times.Fajr) > night/2+time.Minute {
+			t.Fatalf("%s: unexpected fajr %s", rule, times.Fajr)
+		}
+		if times.Isha.Before(times.Maghrib) || times.Isha.Sub(times.Maghrib) > night/2+time.Minute {
+			t.Fatalf("%s: unexpected isha %s", rule, times.Isha)
+		}
+	}
+
+	// the sun doesn't set in the arctic summer
+	if _, err := Calculate(78.2232, 15.6267, date("2024-06-21"), Options{}); err == nil {
+		t.Fatal("expected an error when the sun doesn't set")
+	}
+}
+
+// noaa returns the declination and the altitude of the sun in degrees at
+// the time, from NOAA's general solar position equations, as a reference
+// independent of the calculation
+func noaa(lat, lon float64, t time.Time) (decl, alt float64) {
+	t = t.UTC()
+	hour := float64(t.Hour()) + float64(t.Minute())/60 + float64(t.Second())/3600
+	g := 2 * math.Pi / 365 * (float64(t.YearDay()-1) + (hour-12)/24)
+	eqtime := 229.18 * (0.000075 + 0.001868*math.Cos(g) - 0.032077*math.Sin(g) - 0.014615*math.Cos(2*g) - 0.040849*math.Sin(2*g))
+	d := 0.006918 - 0.399912*math.Cos(g) + 0.070257*math.Sin(g) - 0.006758*math.Cos(2*g) + 0.000907*math.Sin(2*g) - 0.002697*math.Cos(3*g) + 0.00148*math.Sin(3*g)
+	ha := rad((hour*60+eqtime+4*lon)/4 - 180)
+	zenith := math.Acos(math.Sin(rad(lat))*math.Sin(d) + math.Cos(rad(lat))*math.Cos(d)*math.Cos(ha))
+	return deg(d), 90 - deg(zenith)
+}
+
+// reference returns the time within an hour of t at which the sun crosses
+// the altitude, rising or setting
+func reference(lat, lon float64, t time.Time, alt float64, rising bool) time.Time {
+	lo, hi := t.Add(-time.Hour), t.Add(time.Hour)
+	for hi.Sub(lo) > time.Second {
+		mid := lo.Add(hi.Sub(lo) / 2)
+		_, a := noaa(lat, lon, mid)
+		if (a < alt) == rising {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// angles of the methods as published by praytimes.org, with Isha in
+// minutes after Maghrib where the angle is 0
+var publishedMethods = map[string]struct{ fajr, isha, ishaMinutes float64 }{
+	"mwl":         {18, 17, 0},
+	"isna":        {15, 15, 0},
+	"egyptian":    {19.5, 17.5, 0},
+	"karachi":     {18, 18, 0},
+	"umm_al_qura": {18.5, 0, 90},
+}
+
+func TestMethodAngles(t *testing.T) {
+	if len(publishedMethods) != len(Methods) {
+		t.Fatalf("expected %d methods, got %d", len(publishedMethods), len(Methods))
+	}
+
+	makkah, _ := time.LoadLocation("Asia/Riyadh")
+	cairo, _ := time.LoadLocation("Africa/Cairo")
+	newYork, _ := time.LoadLocation("America/New_York")
+
+	for _, c := range []struct {
+		lat, lon float64
+		loc      *time.Location
+		date     string
+	}{
+		{21.4225, 39.8262, makkah, "2024-01-01"},
+		{30.0444, 31.2357, cairo, "2024-06-21"},
+		{40.7128, -74.0060, newYork, "2024-12-21"},
+	} {
+		for _, id := range MethodIDs() {
+			for _, asr := range []string{Shafii, Hanafi} {
+				times, err := Calculate(c.lat, c.lon, date(c.date), Options{Method: id, Asr: asr, Location: c.loc, HighLatitude: HighLatitudeNone})
+				if err != nil {
+					t.Fatal(err)
+				}
+				m, ok := publishedMethods[id]
+				if !ok {
+					t.Fatalf("unexpected method %s", id)
+				}
+
+				// shadows are the factor times the length of objects plus
+				// their length at noon
+				factor := 1.0
+				if asr == Hanafi {
+					factor = 2
+				}
+				decl, _ := noaa(c.lat, c.lon, times.Dhuhr)
+				asrAlt := deg(math.Atan(1 / (factor + math.Tan(rad(math.Abs(c.lat-decl))))))
+
+				isha := times.Maghrib.Add(time.Duration(m.ishaMinutes) * time.Minute)
+				if m.ishaMinutes == 0 {
+					isha = reference(c.lat, c.lon, times.Isha, -m.isha, false)
+				}
+
+				for _, check := range []struct {
+					name     string
+					got      time.Time
+					expected time.Time
+				}{
+					{"fajr", times.Fajr, reference(c.lat, c.lon, times.Fajr, -m.fajr, true)},
+					{"asr", times.Asr, reference(c.lat, c.lon, times.Asr, asrAlt, false)},
+					{"isha", times.Isha, isha},
+				} {
+					if d := check.got.Sub(check.expected).Abs(); d > 2*time.Minute {
+						t.Errorf("%s %s %s %s: expected %s, got %s", c.date, id, asr, check.name,
+							check.expected.In(c.loc).Format("15:04:05"), check.got.Format("15:04"))
+					}
+				}
+			}
+		}
+	}
+}
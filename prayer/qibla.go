@@ -0,0 +1,44 @@
+package prayer
+
+import (
+	"fmt"
+	"math"
+)
+
+// Location of the Kaaba in Makkah
+const (
+	KaabaLatitude  = 21.4225
+	KaabaLongitude = 39.8262
+)
+
+// mean radius of the earth in km
+const earthRadius = 6371.0088
+
+// Qibla is the direction of the Kaaba from a location
+type Qibla struct {
+	// Bearing in degrees clockwise from true north
+	Bearing float64 `json:"bearing"`
+	// Great circle distance in km
+	Distance float64 `json:"distance"`
+}
+
+// Direction returns the great circle bearing and distance to the Kaaba
+func Direction(lat, lon float64) (*Qibla, error) {
+	if !validCoordinates(lat, lon) {
+		return nil, fmt.Errorf("invalid coordinates %v,%v", lat, lon)
+	}
+
+	dlon := KaabaLongitude - lon
+	y := sin(dlon) * cos(KaabaLatitude)
+	x := cos(lat)*sin(KaabaLatitude) - sin(lat)*cos(KaabaLatitude)*cos(dlon)
+	bearing := fixAngle(arctan2(y, x))
+
+	// haversine
+	a := math.Pow(sin((KaabaLatitude-lat)/2), 2) + cos(lat)*cos(KaabaLatitude)*math.Pow(sin(dlon/2), 2)
+	distance := 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+
+	return &Qibla{
+		Bearing:  math.Round(bearing*100) / 100,
+		Distance: math.Round(distance*10) / 10,
+	}, nil
+}
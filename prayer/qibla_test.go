@@ -0,0 +1,36 @@
+package prayer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDirection(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		lat, lon float64
+		bearing  float64
+		distance float64
+	}{
+		{"London", 51.5074, -0.1278, 118.99, 4794},
+		{"New York", 40.7128, -74.0060, 58.48, 10301},
+		{"Jakarta", -6.2088, 106.8456, 295.15, 7917},
+	} {
+		q, err := Direction(c.lat, c.lon)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(q.Bearing-c.bearing) > 0.5 {
+			t.Errorf("%s: expected bearing %v, got %v", c.name, c.bearing, q.Bearing)
+		}
+		if math.Abs(q.Distance-c.distance) > 20 {
+			t.Errorf("%s: expected distance %v, got %v", c.name, c.distance, q.Distance)
+		}
+	}
+
+	for _, c := range [][2]float64{{0, 181}, {math.NaN(), 0}, {0, math.NaN()}, {math.Inf(1), 0}, {0, math.Inf(-1)}} {
+		if _, err := Direction(c[0], c[1]); err == nil {
+			t.Fatalf("%v,%v: expected invalid coordinates", c[0], c[1])
+		}
+	}
+}
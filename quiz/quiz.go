@@ -0,0 +1,290 @@
+// Package quiz generates multiple choice questions from the quran,
+// hadith and names of Allah
+package quiz
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/asim/reminder/hadith"
+	"github.com/asim/reminder/names"
+	"github.com/asim/reminder/quran"
+)
+
+// Types of quiz
+const (
+	// Meaning of a name of Allah
+	TypeNames = "names"
+	// Which surah a verse is from
+	TypeSurah = "surah"
+	// Meaning of a word of the Quran
+	TypeWords = "words"
+	// Who narrated a hadith
+	TypeNarrator = "narrator"
+)
+
+var Types = []string{TypeNames, TypeSurah, TypeWords, TypeNarrator}
+
+// Number of choices including the answer
+const Choices = 4
+
+// Maximum questions in a quiz
+const MaxCount = 50
+
+// Question is a multiple choice question. The answer isn't included
+// when encoded so it can only be checked by ID.
+type Question struct {
+	// ID of the form type-seed-index which regenerates the question
+	ID        string   `json:"id"`
+	Type      string   `json:"type"`
+	Prompt    string   `json:"prompt"`
+	Arabic    string   `json:"arabic,omitempty"`
+	Choices   []string `json:"choices"`
+	Reference string   `json:"reference"`
+	Answer    string   `json:"-"`
+}
+
+// Result of checking an answer
+type Result struct {
+	ID      string `json:"id"`
+	Correct bool   `json:"correct"`
+	Answer  string `json:"answer"`
+	// Link to the source of the question
+	Reference string `json:"reference"`
+}
+
+// Quiz generates questions from the loaded data
+type Quiz struct {
+	names  *names.Names
+	quran  *quran.Quran
+	hadith *hadith.Collection
+
+	// every hadith with a narrator
+	narrated  []*narration
+	narrators []string
+}
+
+type narration struct {
+	book     *hadith.Book
+	hadith   *hadith.Hadith
+	narrator string
+}
+
+// New creates a quiz over the data
+func New(n *names.Names, q *quran.Quran, b *hadith.Collection) *Quiz {
+	qz := &Quiz{names: n, quran: q, hadith: b}
+
+	seen := map[string]bool{}
+	for _, book := range b.Books {
+		for _, h := range book.Hadiths {
+			narrator := Narrator(h)
+			if len(narrator) == 0 {
+				continue
+			}
+			qz.narrated = append(qz.narrated, &narration{book, h, narrator})
+			if !seen[narrator] {
+				seen[narrator] = true
+				qz.narrators = append(qz.narrators, narrator)
+			}
+		}
+	}
+
+	return qz
+}
+
+// Narrator returns the name of the narrator e.g Narrated 'Umar: becomes 'Umar
+func Narrator(h *hadith.Hadith) string {
+	n := h.Narrator
+	if len(n) == 0 {
+		n = h.By
+	}
+	n = strings.TrimSpace(n)
+	n = strings.TrimPrefix(n, "Narrated")
+	n = strings.TrimSuffix(n, ":")
+	return strings.TrimSpace(n)
+}
+
+// Questions generates count questions of the type. The same seed always
+// gives the same questions.
+func (qz *Quiz) Questions(typ string, count int, seed int64) ([]*Question, error) {
+	if count < 1 || count > MaxCount {
+		return nil, fmt.Errorf("count must be between 1 and %d", MaxCount)
+	}
+
+	var questions []*Question
+	for i := 0; i < count; i++ {
+		question, err := qz.generate(typ, seed, i)
+		if err != nil {
+			return nil, err
+		}
+		questions = append(questions, question)
+	}
+	return questions, nil
+}
+
+// Question regenerates the question with the ID
+func (qz *Quiz) Question(id string) (*Question, error) {
+	// the seed may be negative so split from both ends
+	typ, rest, ok := strings.Cut(id, "-")
+	i := strings.LastIndex(rest, "-")
+	if !ok || i < 0 {
+		return nil, fmt.Errorf("invalid question id %q", id)
+	}
+	seed, err := strconv.ParseInt(rest[:i], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid question id %q", id)
+	}
+	index, err := strconv.Atoi(rest[i+1:])
+	if err != nil || index < 0 || index >= MaxCount {
+		return nil, fmt.Errorf("invalid question id %q", id)
+	}
+	return qz.generate(typ, seed, index)
+}
+
+// Check checks the answer to the question with the ID
+func (qz *Quiz) Check(id, answer string) (*Result, error) {
+	question, err := qz.Question(id)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{
+		ID:        id,
+		Correct:   strings.EqualFold(strings.TrimSpace(answer), question.Answer),
+		Answer:    question.Answer,
+		Reference: question.Reference,
+	}, nil
+}
+
+// generate creates the question at index in the quiz for the seed.
+// Each question has its own source so it can be checked alone.
+func (qz *Quiz) generate(typ string, seed int64, index int) (*Question, error) {
+	rnd := rand.New(rand.NewSource(seed*MaxCount + int64(index)))
+
+	var question *Question
+	switch typ {
+	case TypeNames:
+		question = qz.name(rnd)
+	case TypeSurah:
+		question = qz.surah(rnd)
+	case TypeWords:
+		question = qz.word(rnd)
+	case TypeNarrator:
+		if len(qz.narrators) < Choices {
+			return nil, fmt.Errorf("not enough narrators for a quiz")
+		}
+		question = qz.narrator(rnd)
+	default:
+		return nil, fmt.Errorf("invalid type %q", typ)
+	}
+
+	question.ID = fmt.Sprintf("%s-%d-%d", typ, seed, index)
+	question.Type = typ
+	return question, nil
+}
+
+// choices returns the answer with distractors picked from pick in random order
+func choices(rnd *rand.Rand, answer string, pick func() string) []string {
+	result := []string{answer}
+	seen := map[string]bool{answer: true}
+	for attempts := 0; len(result) < Choices && attempts < 100; attempts++ {
+		c := pick()
+		if len(c) == 0 || seen[c] {
+			continue
+		}
+		seen[c] = true
+		result = append(result, c)
+	}
+	rnd.Shuffle(len(result), func(i, j int) {
+		result[i], result[j] = result[j], result[i]
+	})
+	return result
+}
+
+func (qz *Quiz) name(rnd *rand.Rand) *Question {
+	list := *qz.names
+	name := list[rnd.Intn(len(list))]
+
+	return &Question{
+		Prompt: fmt.Sprintf("What is the meaning of %s?", name.English),
+		Arabic: name.Arabic,
+		Choices: choices(rnd, name.Meaning, func() string {
+			return list[rnd.Intn(len(list))].Meaning
+		}),
+		Reference: fmt.Sprintf("/names/%d", name.Number),
+		Answer:    name.Meaning,
+	}
+}
+
+func surahName(ch *quran.Chapter) string {
+	return fmt.Sprintf("%s (%s)", ch.Name, ch.English)
+}
+
+func (qz *Quiz) surah(rnd *rand.Rand) *Question {
+	chapters := qz.quran.Chapters
+	ch := chapters[rnd.Intn(len(chapters))]
+	v := ch.Verse(1 + rnd.Intn(len(ch.Verses)))
+	for v == nil || v.Number == 0 {
+		v = ch.Verse(1 + rnd.Intn(len(ch.Verses)))
+	}
+
+	return &Question{
+		Prompt: fmt.Sprintf("Which surah is this verse from? %s", v.Text),
+		Arabic: v.Arabic,
+		Choices: choices(rnd, surahName(ch), func() string {
+			return surahName(chapters[rnd.Intn(len(chapters))])
+		}),
+		Reference: fmt.Sprintf("/quran/%d#%d", ch.Number, v.Number),
+		Answer:    surahName(ch),
+	}
+}
+
+// randomWord returns a random word of the Quran with its verse
+func (qz *Quiz) randomWord(rnd *rand.Rand) (*quran.Verse, *quran.Word) {
+	chapters := qz.quran.Chapters
+	for {
+		ch := chapters[rnd.Intn(len(chapters))]
+		v := ch.Verses[rnd.Intn(len(ch.Verses))]
+		if len(v.Words) == 0 {
+			continue
+		}
+		return v, v.Words[rnd.Intn(len(v.Words))]
+	}
+}
+
+func (qz *Quiz) word(rnd *rand.Rand) *Question {
+	v, w := qz.randomWord(rnd)
+
+	return &Question{
+		Prompt: fmt.Sprintf("What does %s (%s) mean?", w.Arabic, w.Transliteration),
+		Arabic: w.Arabic,
+		Choices: choices(rnd, w.English, func() string {
+			_, other := qz.randomWord(rnd)
+			return other.English
+		}),
+		Reference: fmt.Sprintf("/quran/%d#%d", v.Chapter, v.Number),
+		Answer:    w.English,
+	}
+}
+
+func (qz *Quiz) narrator(rnd *rand.Rand) *Question {
+	n := qz.narrated[rnd.Intn(len(qz.narrated))]
+
+	text := n.hadith.English
+	if len(text) == 0 {
+		text = n.hadith.Text
+	}
+	if r := []rune(text); len(r) > 300 {
+		text = string(r[:300]) + "..."
+	}
+
+	return &Question{
+		Prompt: fmt.Sprintf("Who narrated this hadith? %s", text),
+		Choices: choices(rnd, n.narrator, func() string {
+			return qz.narrators[rnd.Intn(len(qz.narrators))]
+		}),
+		Reference: fmt.Sprintf("/hadith/%d#%d", n.book.Number, n.hadith.Number),
+		Answer:    n.narrator,
+	}
+}
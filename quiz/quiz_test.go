@@ -0,0 +1,64 @@
+package quiz
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/asim/reminder/hadith"
+	"github.com/asim/reminder/names"
+	"github.com/asim/reminder/quran"
+)
+
+func TestQuiz(t *testing.T) {
+	b := &hadith.Collection{Books: []*hadith.Book{{Number: 1, Hadiths: []*hadith.Hadith{
+		{Number: 1, Narrator: "Narrated 'Umar bin Al-Khattab:", English: "Actions are by intentions"},
+		{Number: 2, Narrator: "Narrated 'Aisha:", English: "The first revelation"},
+		{Number: 3, Narrator: "Narrated Ibn 'Abbas:", English: "The Prophet was the most generous"},
+		{Number: 4, Narrator: "Narrated Abu Huraira:", English: "Faith has over sixty branches"},
+	}}}}
+
+	qz := New(names.Load(), quran.Load(), b)
+
+	for _, typ := range Types {
+		questions, err := qz.Questions(typ, 10, -7)
+		if err != nil {
+			t.Fatalf("%s: %v", typ, err)
+		}
+		for _, question := range questions {
+			if len(question.Choices) != Choices {
+				t.Fatalf("%s: expected %d choices, got %v", typ, Choices, question.Choices)
+			}
+
+			// the question regenerates from its id
+			again, err := qz.Question(question.ID)
+			if err != nil || !reflect.DeepEqual(question, again) {
+				t.Fatalf("%s: expected %+v from id, got %+v %v", typ, question, again, err)
+			}
+
+			result, err := qz.Check(question.ID, question.Answer)
+			if err != nil || !result.Correct {
+				t.Fatalf("%s: expected answer to be correct, got %+v %v", typ, result, err)
+			}
+			for _, c := range question.Choices {
+				if c != question.Answer {
+					if result, _ := qz.Check(question.ID, c); result.Correct {
+						t.Fatalf("%s: expected %q to be wrong", typ, c)
+					}
+					break
+				}
+			}
+		}
+
+		same, _ := qz.Questions(typ, 10, -7)
+		if !reflect.DeepEqual(questions, same) {
+			t.Fatalf("%s: expected the same seed to give the same questions", typ)
+		}
+	}
+
+	if _, err := qz.Questions("tafsir", 1, 1); err == nil {
+		t.Fatal("expected invalid type to be rejected")
+	}
+	if _, err := qz.Check("names-1", "x"); err == nil {
+		t.Fatal("expected invalid id to be rejected")
+	}
+}
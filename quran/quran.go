@@ -4,6 +4,7 @@ import (
 	"embed"
 	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 //go:embed data/*.json
@@ -32,6 +33,10 @@ type Verse struct {
 	Comments     string  `json:"comments"`
 	AudioArabic  string  `json:"audio_arabic,omitempty"`
 	AudioEnglish string  `json:"audio_english,omitempty"`
+	// Hadith which cite the verse e.g 65:12
+	Hadith []string `json:"hadith,omitempty"`
+	// Names of Allah which appear in the verse
+	Names []int `json:"names,omitempty"`
 }
 
 type Word struct {
@@ -97,6 +102,17 @@ func (ch *Chapter) HTML() string {
 	return data
 }
 
+// Verse returns the verse with the given number, skipping the bismillah
+// which is prepended to most chapters
+func (ch *Chapter) Verse(number int) *Verse {
+	for _, v := range ch.Verses {
+		if v.Number == number {
+			return v
+		}
+	}
+	return nil
+}
+
 func (v *Verse) HTML() string {
 	var data string
 
@@ -109,6 +125,31 @@ func (v *Verse) HTML() string {
 		v.Chapter, v.Number, verseKey, verseLabel, verseURL)
 	data += `<div class="arabic text-right text-2xl mb-4 leading-relaxed">` + v.Arabic + `</div>`
 	data += `<div class="text-gray-700">` + v.Text + `</div>`
+
+	// Names of Allah in the verse
+	if len(v.Names) > 0 {
+		data += `<div class="mt-4 pt-4 border-t border-gray-100 text-sm text-gray-500">Names: `
+		for i, number := range v.Names {
+			if i > 0 {
+				data += `, `
+			}
+			data += fmt.Sprintf(`<a href="/names/%d" class="text-blue-600 hover:text-blue-800">%d</a>`, number, number)
+		}
+		data += `</div>`
+	}
+
+	// Hadith citing the verse
+	if len(v.Hadith) > 0 {
+		data += `<div class="mt-4 pt-4 border-t border-gray-100 text-sm text-gray-500">Hadith: `
+		for i, key := range v.Hadith {
+			if i > 0 {
+				data += `, `
+			}
+			data += fmt.Sprintf(`<a href="/hadith/%s" class="text-blue-600 hover:text-blue-800">%s</a>`, strings.Replace(key, ":", "#", 1), key)
+		}
+		data += `</div>`
+	}
+
 	data += `</div>`
 
 	return data
@@ -0,0 +1,248 @@
+// Package scheduler runs jobs on cron specs aligned to the wall clock. The
+// last run of each job is saved so runs missed while the server was down
+// can be caught up on start.
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asim/reminder/api"
+)
+
+// Job is a task run on a schedule
+type Job struct {
+	Name string
+	// Cron spec evaluated in UTC e.g "0 * * * *"
+	Spec string
+	// Run the job on start if a scheduled run was missed or failed while
+	// stopped
+	CatchUp bool
+	// Run the job on start whatever the last run
+	OnStart bool
+	// Run is passed the scheduled time, or the start time when run on start
+	Run func(time.Time) error
+}
+
+// Status of a job
+type Status struct {
+	Name    string    `json:"name"`
+	Spec    string    `json:"spec"`
+	Running bool      `json:"running"`
+	Next    time.Time `json:"next"`
+	// Start of the last run
+	LastRun time.Time `json:"last_run,omitempty"`
+	// Duration of the last run in milliseconds
+	LastDuration int64  `json:"last_duration_ms"`
+	LastError    string `json:"last_error,omitempty"`
+	// Time of the last successful run
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	Runs        int       `json:"runs"`
+	Failures    int       `json:"failures"`
+}
+
+type job struct {
+	Job
+	spec   *Spec
+	status *Status
+}
+
+// Scheduler runs jobs at the start of the minutes matching their specs
+type Scheduler struct {
+	file string
+
+	mtx  sync.RWMutex
+	jobs []*job
+	// saved status by job name
+	saved map[string]*Status
+}
+
+// New returns a scheduler saving the state of its jobs to the file
+func New(file string) *Scheduler {
+	return &Scheduler{file: file, saved: make(map[string]*Status)}
+}
+
+// Load loads the state of the jobs saved by a previous run
+func (s *Scheduler) Load() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	b, err := os.ReadFile(s.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &s.saved)
+}
+
+// save writes the state of the jobs, the caller holds the lock
+func (s *Scheduler) save() error {
+	state := make(map[string]*Status)
+	for name, st := range s.saved {
+		state[name] = st
+	}
+	for _, j := range s.jobs {
+		state[j.Name] = j.status
+	}
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return api.WriteFile(s.file, b, 0644)
+}
+
+// Add adds a job, restoring its saved state
+func (s *Scheduler) Add(j Job) error {
+	if len(j.Name) == 0 || j.Run == nil {
+		return fmt.Errorf("job requires a name and run func")
+	}
+	spec, err := Parse(j.Spec)
+	if err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, existing := range s.jobs {
+		if existing.Name == j.Name {
+			return fmt.Errorf("job %s already exists", j.Name)
+		}
+	}
+
+	status := &Status{}
+	if saved, ok := s.saved[j.Name]; ok {
+		*status = *saved
+	}
+	status.Name = j.Name
+	status.Spec = spec.String()
+	status.Running = false
+	status.Next = spec.Next(time.Now().UTC())
+
+	s.jobs = append(s.jobs, &job{Job: j, spec: spec, status: status})
+	return nil
+}
+
+// Start runs the jobs due on start then schedules them in the background
+func (s *Scheduler) Start() {
+	now := time.Now().UTC()
+	for _, j := range s.startJobs(now) {
+		go s.run(j, now)
+	}
+	go s.loop(now)
+}
+
+// startJobs returns the jobs to run on start
+func (s *Scheduler) startJobs(now time.Time) []*job {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var due []*job
+	for _, j := range s.jobs {
+		if j.OnStart {
+			due = append(due, j)
+			continue
+		}
+		if !j.CatchUp {
+			continue
+		}
+		// never succeeded or a run was due between the last success and now
+		if last := j.status.LastSuccess; last.IsZero() || !j.spec.Next(last).After(now) {
+			due = append(due, j)
+		}
+	}
+	return due
+}
+
+// loop wakes at the start of every minute to run the jobs due since the
+// last, so a late wake up doesn't miss a run
+func (s *Scheduler) loop(last time.Time) {
+	for {
+		now := time.Now().UTC()
+		time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+
+		now = time.Now().UTC()
+		for _, j := range s.dueJobs(last, now) {
+			go s.run(j, j.spec.Next(last))
+		}
+		last = now
+	}
+}
+
+// dueJobs returns the jobs with a scheduled time after last up to now
+func (s *Scheduler) dueJobs(last, now time.Time) []*job {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var due []*job
+	for _, j := range s.jobs {
+		if next := j.spec.Next(last); !next.IsZero() && !next.After(now) {
+			due = append(due, j)
+		}
+	}
+	return due
+}
+
+// run runs the job unless it's still running from the last time, isolating
+// the scheduler from any panic
+func (s *Scheduler) run(j *job, t time.Time) {
+	s.mtx.Lock()
+	if j.status.Running {
+		s.mtx.Unlock()
+		return
+	}
+	j.status.Running = true
+	s.mtx.Unlock()
+
+	start := time.Now()
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return j.Run(t)
+	}()
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	st := j.status
+	st.Running = false
+	st.LastRun = start.UTC()
+	st.LastDuration = time.Since(start).Milliseconds()
+	st.Next = j.spec.Next(time.Now().UTC())
+	st.Runs++
+	if err != nil {
+		st.LastError = err.Error()
+		st.Failures++
+		fmt.Printf("Job %s failed: %v\n", j.Name, err)
+	} else {
+		st.LastError = ""
+		st.LastSuccess = st.LastRun
+	}
+
+	if err := s.save(); err != nil {
+		fmt.Println("Failed to save job state:", err)
+	}
+}
+
+// Status returns the status of the jobs by name
+func (s *Scheduler) Status() []*Status {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var status []*Status
+	for _, j := range s.jobs {
+		st := *j.status
+		status = append(status, &st)
+	}
+	sort.Slice(status, func(i, k int) bool { return status[i].Name < status[k].Name })
+	return status
+}
@@ -0,0 +1,82 @@
+package scheduler
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "jobs.json")
+	s := New(file)
+
+	var runs int
+	s.Add(Job{Name: "ok", Spec: "@hourly", Run: func(time.Time) error { runs++; return nil }})
+	s.Add(Job{Name: "panic", Spec: "@hourly", Run: func(time.Time) error { panic("boom") }})
+	s.Add(Job{Name: "error", Spec: "@hourly", Run: func(time.Time) error { return errors.New("failed") }})
+	if err := s.Add(Job{Name: "ok", Spec: "@daily", Run: func(time.Time) error { return nil }}); err == nil {
+		t.Fatal("expected a duplicate job to be rejected")
+	}
+	if err := s.Add(Job{Name: "bad", Spec: "daily", Run: func(time.Time) error { return nil }}); err == nil {
+		t.Fatal("expected an invalid spec to be rejected")
+	}
+
+	now := time.Now().UTC()
+	for _, j := range s.dueJobs(now.Add(-time.Hour), now) {
+		s.run(j, now)
+	}
+
+	status := map[string]*Status{}
+	for _, st := range s.Status() {
+		status[st.Name] = st
+	}
+	if runs != 1 || status["ok"].Runs != 1 || len(status["ok"].LastError) > 0 || status["ok"].LastSuccess.IsZero() {
+		t.Fatalf("unexpected status %+v", status["ok"])
+	}
+	if status["panic"].Failures != 1 || status["panic"].LastError != "panic: boom" {
+		t.Fatalf("expected the panic to be recorded, got %+v", status["panic"])
+	}
+	if status["error"].Failures != 1 || !status["error"].LastSuccess.IsZero() {
+		t.Fatalf("expected the error to be recorded, got %+v", status["error"])
+	}
+
+	// state is restored after a restart
+	s = New(file)
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	s.Add(Job{Name: "ok", Spec: "@hourly", Run: func(time.Time) error { return nil }})
+	if st := s.Status()[0]; st.Runs != 1 || st.LastSuccess.IsZero() {
+		t.Fatalf("expected the saved status, got %+v", st)
+	}
+}
+
+func TestCatchUp(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "jobs.json"))
+	now := time.Date(2024, 1, 10, 12, 30, 0, 0, time.UTC)
+	noop := func(time.Time) error { return nil }
+
+	s.Add(Job{Name: "new", Spec: "@daily", CatchUp: true, Run: noop})
+	s.Add(Job{Name: "missed", Spec: "@daily", CatchUp: true, Run: noop})
+	s.Add(Job{Name: "done", Spec: "@daily", CatchUp: true, Run: noop})
+	s.Add(Job{Name: "skip", Spec: "@daily", Run: noop})
+	s.Add(Job{Name: "start", Spec: "@daily", OnStart: true, Run: noop})
+
+	for _, j := range s.jobs {
+		switch j.Name {
+		case "missed", "skip":
+			j.status.LastSuccess = now.AddDate(0, 0, -2)
+		case "done":
+			j.status.LastSuccess = time.Date(2024, 1, 10, 0, 0, 1, 0, time.UTC)
+		}
+	}
+
+	var names []string
+	for _, j := range s.startJobs(now) {
+		names = append(names, j.Name)
+	}
+	if len(names) != 3 || names[0] != "new" || names[1] != "missed" || names[2] != "start" {
+		t.Fatalf("expected new, missed and start to run, got %v", names)
+	}
+}
@@ -0,0 +1,166 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Spec is a cron schedule of minute, hour, day of month, month and day of
+// the week e.g "*/15 * * * *" or "0 0 * * *". Fields may be *, a number,
+// a range 1-5, a step */15 or 1-30/5, or a list of these separated by
+// commas. Days of the week are 0-6 from Sunday.
+type Spec struct {
+	source string
+
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	// the day of month or week is *, otherwise either matching is enough
+	domAll bool
+	dowAll bool
+}
+
+// shorthands for common specs
+var shorthands = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// maximum years searched for the next time, e.g for the 30th of February
+const maxYears = 5
+
+// Parse parses a cron spec or one of @hourly, @daily, @weekly or @monthly
+func Parse(spec string) (*Spec, error) {
+	source := strings.TrimSpace(spec)
+	if s, ok := shorthands[source]; ok {
+		spec = s
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid spec %q: expected 5 fields", source)
+	}
+
+	s := &Spec{source: source}
+	var err error
+	for i, f := range []struct {
+		bits     *uint64
+		min, max int
+	}{
+		{&s.minute, 0, 59},
+		{&s.hour, 0, 23},
+		{&s.dom, 1, 31},
+		{&s.month, 1, 12},
+		{&s.dow, 0, 6},
+	} {
+		if *f.bits, err = parseField(fields[i], f.min, f.max); err != nil {
+			return nil, fmt.Errorf("invalid spec %q: %v", source, err)
+		}
+	}
+	s.domAll = fields[2] == "*"
+	s.dowAll = fields[4] == "*"
+	return s, nil
+}
+
+func parseField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+			step = n
+			part = part[:i]
+		}
+
+		start, end := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			r := strings.SplitN(part, "-", 2)
+			var err1, err2 error
+			start, err1 = strconv.Atoi(r[0])
+			end, err2 = strconv.Atoi(r[1])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			start, end = n, n
+			if step > 1 {
+				end = max
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// String returns the spec as parsed
+func (s *Spec) String() string {
+	return s.source
+}
+
+// Matches reports whether the spec includes the minute of t
+func (s *Spec) Matches(t time.Time) bool {
+	return has(s.minute, t.Minute()) && has(s.hour, t.Hour()) && has(s.month, int(t.Month())) && s.day(t)
+}
+
+func (s *Spec) day(t time.Time) bool {
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+	if s.domAll || s.dowAll {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first time matching the spec after t, truncated to the
+// minute in the location of t. It returns the zero time if there is none.
+func (s *Spec) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxYears, 0, 0)
+
+	for t.Before(limit) {
+		if !has(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.day(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !has(s.hour, t.Hour()) {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if !has(s.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	for _, spec := range []string{"* * * * *", "0 * * * *", "*/15 9-17 * * 1-5", "0,30 0 1,15 * *", "@daily", "5/10 * * * *"} {
+		if _, err := Parse(spec); err != nil {
+			t.Fatalf("expected %q to be valid, got %v", spec, err)
+		}
+	}
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 7", "*/0 * * * *", "5-1 * * * *", "@yearly"} {
+		if _, err := Parse(spec); err == nil {
+			t.Fatalf("expected %q to be invalid", spec)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// Wednesday 10th January 2024 12:34:56
+	after := time.Date(2024, 1, 10, 12, 34, 56, 0, time.UTC)
+
+	for _, c := range []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 10, 12, 35, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 1, 10, 13, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 10, 12, 45, 0, 0, time.UTC)},
+		{"30 9 * * 5", time.Date(2024, 1, 12, 9, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// either the day of the month or week
+		{"0 0 20 * 5", time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	} {
+		s, _ := Parse(c.spec)
+		next := s.Next(after)
+		if !next.Equal(c.next) {
+			t.Fatalf("%s: expected %s, got %s", c.spec, c.next, next)
+		}
+		if !next.IsZero() && !s.Matches(next) {
+			t.Fatalf("%s: expected %s to match", c.spec, next)
+		}
+	}
+
+	// a time on the minute is excluded
+	s, _ := Parse("@hourly")
+	on := time.Date(2024, 1, 10, 13, 0, 0, 0, time.UTC)
+	if next := s.Next(on); !next.Equal(on.Add(time.Hour)) {
+		t.Fatalf("expected the next hour, got %s", next)
+	}
+}
@@ -15,6 +15,8 @@ export interface BookmarksData {
   quran: Record<string, Bookmark>;
   hadith: Record<string, Bookmark>;
   names: Record<string, Bookmark>;
+  // Removal times by type:key so removals sync like additions
+  deleted?: Record<string, { timestamp: string }>;
 }
 
 function initBookmarks(): BookmarksData {
@@ -73,6 +75,9 @@ export function useBookmarks() {
       timestamp: new Date().toISOString(),
       excerpt,
     };
+    if (newBookmarks.deleted) {
+      delete newBookmarks.deleted[`${type}:${key}`];
+    }
     setBookmarks(newBookmarks);
     saveBookmarks(newBookmarks);
   };
@@ -80,6 +85,10 @@ export function useBookmarks() {
   const removeBookmark = (type: BookmarkType, key: string) => {
     const newBookmarks = { ...bookmarks };
     delete newBookmarks[type][key];
+    newBookmarks.deleted = {
+      ...newBookmarks.deleted,
+      [`${type}:${key}`]: { timestamp: new Date().toISOString() },
+    };
     setBookmarks(newBookmarks);
     saveBookmarks(newBookmarks);
   };
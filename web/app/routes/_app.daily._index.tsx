@@ -1,5 +1,5 @@
 import React, { useState } from 'react';
-import { subscribeUserToPush } from '../utils/push';
+import { sendTestPush, subscribeUserToPush } from '../utils/push';
 import { unsubscribeUserFromPush } from '../utils/push-unsub';
 // VAPID public key endpoint
 const VAPID_PUBLIC_KEY_ENDPOINT = '/api/push/key';
@@ -7,6 +7,7 @@ function NotificationButton() {
   const [enabled, setEnabled] = useState(false);
   const [loading, setLoading] = useState(false);
   const [error, setError] = useState<string | null>(null);
+  const [status, setStatus] = useState<string | null>(null);
 
   // Check subscription status on mount
   React.useEffect(() => {
@@ -49,16 +50,39 @@ function NotificationButton() {
     }
   }
 
+  async function handleTest() {
+    setLoading(true);
+    setError(null);
+    setStatus(null);
+    try {
+      await sendTestPush();
+      setStatus('Test notification sent');
+    } catch (err: any) {
+      setError(err.message || 'Failed to send a test notification');
+    } finally {
+      setLoading(false);
+    }
+  }
+
   return (
     <div>
       {enabled ? (
-        <button
-          className="bg-gray-500 text-white px-4 py-2 rounded text-sm cursor-pointer shadow hover:bg-gray-600"
-          onClick={handleUnsubscribe}
-          disabled={loading}
-        >
-          Disable Notifications
-        </button>
+        <div className="flex gap-2">
+          <button
+            className="bg-gray-200 text-gray-800 px-4 py-2 rounded text-sm cursor-pointer shadow hover:bg-gray-300"
+            onClick={handleTest}
+            disabled={loading}
+          >
+            Send Test
+          </button>
+          <button
+            className="bg-gray-500 text-white px-4 py-2 rounded text-sm cursor-pointer shadow hover:bg-gray-600"
+            onClick={handleUnsubscribe}
+            disabled={loading}
+          >
+            Disable Notifications
+          </button>
+        </div>
       ) : (
         <button
           className="bg-gray-800 text-white px-4 py-2 rounded text-sm cursor-pointer shadow hover:bg-gray-700"
@@ -70,6 +94,7 @@ function NotificationButton() {
   )
 }
 { error && <div className="text-red-500 mt-2 text-sm">{error}</div> }
+{ status && <div className="text-gray-600 mt-2 text-sm">{status}</div> }
     </div >
   );
 }
@@ -35,7 +35,13 @@ export async function subscribeUserToPush(publicKey: string) {
     const resp = await fetch('/api/push/subscribe', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
-      body: JSON.stringify(subscription),
+      body: JSON.stringify({
+        ...subscription.toJSON(),
+        // deliver at the user's local time rather than midnight UTC
+        preferences: {
+          timezone: Intl.DateTimeFormat().resolvedOptions().timeZone,
+        },
+      }),
     });
     if (!resp.ok) {
       const text = await resp.text();
@@ -49,6 +55,23 @@ export async function subscribeUserToPush(publicKey: string) {
   return subscription;
 }
 
+// Ask the server to send a notification to this browser straight away
+export async function sendTestPush() {
+  const registration = await registerServiceWorker();
+  const subscription = await registration.pushManager.getSubscription();
+  if (!subscription) throw new Error('Not subscribed to push notifications');
+  const resp = await fetch('/api/push/test', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ endpoint: subscription.endpoint }),
+  });
+  if (!resp.ok) {
+    const text = await resp.text();
+    throw new Error('Backend /api/push/test failed: ' + text);
+  }
+  return resp.json();
+}
+
 export function urlBase64ToUint8Array(base64String: string) {
   const padding = '='.repeat((4 - (base64String.length % 4)) % 4);
   const base64 = (base64String + padding)